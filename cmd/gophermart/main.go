@@ -2,6 +2,7 @@ package main
 
 import (
 	"log"
+	"os"
 
 	"github.com/fuzzy-toozy/gophermart/internal/config"
 	"github.com/fuzzy-toozy/gophermart/internal/server"
@@ -20,6 +21,21 @@ func main() {
 
 	defer appLog.Logger.Sync()
 
+	if len(c.Args) > 0 {
+		if c.Args[0] != "migrate" {
+			appLog.Logger.Errorf("Unknown command '%v', %v", c.Args[0], migrateUsage)
+			appLog.Logger.Sync()
+			os.Exit(2)
+		}
+
+		if err := runMigrate(c, c.Args[1:], appLog.Logger); err != nil {
+			appLog.Logger.Errorf("Migration failed: %v", err)
+			appLog.Logger.Sync()
+			os.Exit(1)
+		}
+		return
+	}
+
 	server, err := server.NewServer(c, appLog)
 	if err != nil {
 		appLog.Logger.Errorf("Failed to create server: %v", err)
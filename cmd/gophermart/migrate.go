@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/fuzzy-toozy/gophermart/internal/config"
+	"github.com/fuzzy-toozy/gophermart/internal/database"
+	"go.uber.org/zap"
+)
+
+const migrateUsage = "usage: gophermart [flags] migrate up|down [N]|version|force VERSION"
+
+// runMigrate runs migrate subcommand with the given arguments
+func runMigrate(c *config.Config, args []string, logger *zap.SugaredLogger) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	if database.IsMemoryURI(c.DatabaseConfig.ConnURI) {
+		return errors.New("in-memory storage has no migrations")
+	}
+
+	m, err := database.NewMigrator(c.DatabaseConfig.ConnURI)
+	if err != nil {
+		return err
+	}
+
+	defer m.Close()
+
+	switch args[0] {
+	case "up":
+		if err := m.Up(); err != nil {
+			return fmt.Errorf("failed to apply migrations: %w", err)
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil {
+				return fmt.Errorf("invalid number of migrations '%v': %w", args[1], err)
+			}
+		}
+
+		if err := m.Down(steps); err != nil {
+			return fmt.Errorf("failed to revert migrations: %w", err)
+		}
+	case "version":
+	case "force":
+		if len(args) < 2 {
+			return errors.New(migrateUsage)
+		}
+
+		version, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid version '%v': %w", args[1], err)
+		}
+
+		if err := m.Force(version); err != nil {
+			return fmt.Errorf("failed to force version %v: %w", version, err)
+		}
+	default:
+		return errors.New(migrateUsage)
+	}
+
+	version, dirty, err := m.Version()
+	if err != nil {
+		return fmt.Errorf("failed to get migration version: %w", err)
+	}
+
+	logger.Infof("Migration version: %v, dirty: %v", version, dirty)
+
+	return nil
+}
@@ -0,0 +1,67 @@
+// ledgercheck verifies double-entry ledger invariants and stored user balances.
+// It exits with non-zero status if any violation is found.
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"github.com/fuzzy-toozy/gophermart/internal/config"
+	"github.com/fuzzy-toozy/gophermart/internal/database"
+	"github.com/fuzzy-toozy/gophermart/internal/database/repo"
+	"github.com/fuzzy-toozy/gophermart/internal/server"
+	"github.com/fuzzy-toozy/gophermart/internal/services"
+)
+
+func main() {
+	os.Exit(run())
+}
+
+func run() int {
+	c, err := config.BuildConfig()
+	if err != nil {
+		log.Printf("Failed to build app config: %v", err)
+		return 2
+	}
+
+	appLog, err := server.LogInit(c.LogLevel, c.LogPrefix, c.LogFile)
+	if err != nil {
+		log.Printf("Failed to create app logger: %v", err)
+		return 2
+	}
+
+	defer appLog.Logger.Sync()
+
+	storage, err := database.NewServiceStorage(c.DatabaseConfig)
+	if err != nil {
+		appLog.Logger.Errorf("Failed to create storage: %v", err)
+		return 2
+	}
+
+	defer storage.Close()
+
+	s := services.NewReconciliationService(repo.NewBalanceServiceRepo(storage), appLog.Logger)
+	ctx := database.WithQueryTimeout(context.Background(), c.BackgroundQueryTimeout)
+
+	violations, serr := s.CheckLedger(ctx)
+	if serr != nil {
+		appLog.Logger.Errorf("Failed to check ledger: %v", serr)
+		return 2
+	}
+
+	drifts, serr := s.Reconcile(ctx)
+	if serr != nil {
+		appLog.Logger.Errorf("Failed to reconcile balances: %v", serr)
+		return 2
+	}
+
+	if violations > 0 || drifts > 0 {
+		appLog.Logger.Errorf("Ledger check failed: %v unbalanced transactions, %v drifted balances", violations, drifts)
+		return 1
+	}
+
+	appLog.Logger.Infof("Ledger is consistent")
+
+	return 0
+}
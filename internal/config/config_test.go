@@ -0,0 +1,208 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// clearEnv makes the options of the test independent of the environment the tests are run in
+func clearEnv(t *testing.T) {
+	t.Helper()
+
+	for _, env := range []string{configFileEnv, "RUN_ADDRESS", "PROCESSING_INTERVAL", "WITHDRAW_MAX"} {
+		t.Setenv(env, "")
+	}
+}
+
+func writeConfigFile(t *testing.T, name string, content string) string {
+	t.Helper()
+
+	file := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	return file
+}
+
+func TestParseConfigPrecedence(t *testing.T) {
+	yamlFile := "address: localhost:9001\nprocessing_interval: 5s\nwithdraw_max: 500\n"
+	tomlFile := "address = \"localhost:9001\"\nprocessing_interval = \"5s\"\nwithdraw_max = 500\n"
+
+	tests := []struct {
+		name        string
+		file        string
+		fileName    string
+		useEnvFile  bool
+		args        []string
+		env         map[string]string
+		address     string
+		interval    time.Duration
+		withdrawMax float64
+	}{
+		{name: "defaults", address: "localhost:8080", interval: time.Second, withdrawMax: 0},
+		{name: "yaml file overrides defaults", file: yamlFile, fileName: "config.yaml",
+			address: "localhost:9001", interval: 5 * time.Second, withdrawMax: 500},
+		{name: "toml file overrides defaults", file: tomlFile, fileName: "config.toml",
+			address: "localhost:9001", interval: 5 * time.Second, withdrawMax: 500},
+		{name: "flags override file", file: yamlFile, fileName: "config.yaml",
+			args:    []string{"-a", "localhost:9002", "-processing-interval", "7s"},
+			address: "localhost:9002", interval: 7 * time.Second, withdrawMax: 500},
+		{name: "env overrides flags and file", file: yamlFile, fileName: "config.yaml",
+			args:    []string{"-a", "localhost:9002", "-processing-interval", "7s"},
+			env:     map[string]string{"RUN_ADDRESS": "localhost:9003", "WITHDRAW_MAX": "700"},
+			address: "localhost:9003", interval: 7 * time.Second, withdrawMax: 700},
+		{name: "env selects file", file: yamlFile, fileName: "config.yaml", useEnvFile: true,
+			address: "localhost:9001", interval: 5 * time.Second, withdrawMax: 500},
+		{name: "empty env is ignored", args: []string{"-a", "localhost:9002"},
+			env:     map[string]string{"RUN_ADDRESS": ""},
+			address: "localhost:9002", interval: time.Second, withdrawMax: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+
+			args := tt.args
+			if len(tt.file) > 0 {
+				file := writeConfigFile(t, tt.fileName, tt.file)
+				if tt.useEnvFile {
+					t.Setenv(configFileEnv, file)
+				} else {
+					args = append([]string{"-c", file}, args...)
+				}
+			}
+
+			for env, value := range tt.env {
+				t.Setenv(env, value)
+			}
+
+			c, err := ParseConfig("gophermart", args)
+			if err != nil {
+				t.Fatalf("Failed to parse config: %v", err)
+			}
+
+			if c.ServerAddress != tt.address || c.ProcessingInteval != tt.interval || c.WithdrawMax != tt.withdrawMax {
+				t.Errorf("Expected address %v, interval %v, withdraw max %v, got %v, %v, %v", tt.address, tt.interval,
+					tt.withdrawMax, c.ServerAddress, c.ProcessingInteval, c.WithdrawMax)
+			}
+		})
+	}
+}
+
+func TestParseConfigErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		fileName string
+		args     []string
+		env      map[string]string
+		errs     []string
+	}{
+		{name: "unknown flag", args: []string{"-unknown"}, errs: []string{"-unknown"}},
+		{name: "file errors are reported together", file: "unknown: 1\nprocessing_interval: soon\n", fileName: "config.yaml",
+			errs: []string{"unknown option 'unknown'", "invalid processing_interval 'soon'"}},
+		{name: "unsupported file format", file: "{}", fileName: "config.json", errs: []string{"unsupported config file format"}},
+		{name: "invalid env", env: map[string]string{"WITHDRAW_MAX": "a lot"}, errs: []string{"invalid WITHDRAW_MAX 'a lot'"}},
+		{name: "invalid options", args: []string{"-processing-interval", "0s", "-withdraw-min", "10", "-withdraw-max", "5"},
+			errs: []string{"processing_interval must be positive", "withdraw_min 10 is greater than withdraw_max 5"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+
+			args := tt.args
+			if len(tt.file) > 0 {
+				args = append([]string{"-c", writeConfigFile(t, tt.fileName, tt.file)}, args...)
+			}
+
+			for env, value := range tt.env {
+				t.Setenv(env, value)
+			}
+
+			_, err := ParseConfig("gophermart", args)
+			if err == nil {
+				t.Fatalf("Expected error")
+			}
+
+			for _, msg := range tt.errs {
+				if !strings.Contains(err.Error(), msg) {
+					t.Errorf("Expected error containing '%v', got %v", msg, err)
+				}
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(c *Config)
+		errs   []string
+	}{
+		{name: "valid", change: func(c *Config) {}},
+		{name: "invalid address", change: func(c *Config) { c.ServerAddress = "localhost" },
+			errs: []string{"invalid address 'localhost'"}},
+		{name: "invalid accrual address", change: func(c *Config) { c.AccrualAddress = "localhost:8081" },
+			errs: []string{"invalid accrual_address"}},
+		{name: "invalid cors origin", change: func(c *Config) { c.CORSAllowedOrigins = []string{"https://example.com/app"} },
+			errs: []string{"invalid cors_allowed_origins entry 'https://example.com/app'"}},
+		{name: "lease shorter than write timeout", change: func(c *Config) { c.IdempotencyLease = time.Second },
+			errs: []string{"idempotency_lease 1s must not be less than write_timeout 10s"}},
+		{name: "tier thresholds", change: func(c *Config) { c.TierSilverThreshold = c.TierGoldThreshold },
+			errs: []string{"tier thresholds must be positive"}},
+		{name: "all errors are reported", change: func(c *Config) {
+			c.LogLevel = "verbose"
+			c.SecretKey = nil
+			c.HoldTTL = 0
+			c.WithdrawDaily = -1
+			c.WebhookMaxAttempts = 0
+		}, errs: []string{
+			"invalid log_level 'verbose'",
+			"secret_key is not set",
+			"hold_ttl must be positive, got 0s",
+			"withdraw_daily_limit must not be negative, got -1",
+			"webhook_max_attempts must be positive, got 0",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+
+			c, err := ParseConfig("gophermart", nil)
+			if err != nil {
+				t.Fatalf("Failed to parse config: %v", err)
+			}
+
+			tt.change(c)
+
+			err = c.Validate()
+			if len(tt.errs) == 0 {
+				if err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatalf("Expected errors %v", tt.errs)
+			}
+
+			for _, msg := range tt.errs {
+				if !strings.Contains(err.Error(), msg) {
+					t.Errorf("Expected error containing '%v', got %v", msg, err)
+				}
+			}
+
+			// Every invalid option is reported on its own line
+			if lines := strings.Split(err.Error(), "\n"); len(lines) != len(tt.errs) {
+				t.Errorf("Expected %v errors, got %v", len(tt.errs), err)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// readConfigFile reads flat YAML or TOML config file. Values are returned in flag syntax.
+func readConfigFile(file string) (map[string]string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	values := make(map[string]any)
+
+	switch ext := strings.ToLower(filepath.Ext(file)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	case ".toml":
+		err = toml.Unmarshal(data, &values)
+	default:
+		return nil, fmt.Errorf("unsupported config file format '%v', expected .yaml, .yml or .toml", ext)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file '%v': %w", file, err)
+	}
+
+	result := make(map[string]string, len(values))
+	for key, value := range values {
+		s, err := formatValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid config file '%v': option '%v': %w", file, key, err)
+		}
+
+		result[key] = s
+	}
+
+	return result, nil
+}
+
+// formatValue converts the file value to flag syntax. Lists become comma separated.
+func formatValue(value any) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case []any:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			s, err := formatValue(item)
+			if err != nil {
+				return "", err
+			}
+
+			items = append(items, s)
+		}
+
+		return strings.Join(items, ","), nil
+	case map[string]any:
+		return "", errors.New("nested options are not supported")
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
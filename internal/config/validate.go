@@ -0,0 +1,112 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Validate checks the options are consistent and reports all invalid ones
+func (c *Config) Validate() error {
+	errs := make([]error, 0)
+
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	positive := func(name string, d time.Duration) {
+		check(d > 0, "%v must be positive, got %v", name, d)
+	}
+
+	notNegative := func(name string, v float64) {
+		check(v >= 0, "%v must not be negative, got %v", name, v)
+	}
+
+	_, _, err := net.SplitHostPort(strings.TrimPrefix(c.ServerAddress, "http://"))
+	check(err == nil, "invalid address '%v': %v", c.ServerAddress, err)
+
+	u, err := url.Parse(c.AccrualAddress)
+	check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && len(u.Host) > 0,
+		"invalid accrual_address '%v': expected http or https URL", c.AccrualAddress)
+
+	notNegative("accrual_rate_limit", c.AccrualRateLimit)
+
+	// Origins are compared with Origin header, which has no path
+	for _, origin := range c.CORSAllowedOrigins {
+		u, err := url.Parse(origin)
+		check(origin == "*" || err == nil && (u.Scheme == "http" || u.Scheme == "https") && len(u.Host) > 0 &&
+			len(u.Path) == 0, "invalid cors_allowed_origins entry '%v': expected * or origin like https://example.com",
+			origin)
+	}
+
+	_, err = zapcore.ParseLevel(c.LogLevel)
+	check(err == nil, "invalid log_level '%v': expected debug, info, warn or error", c.LogLevel)
+	check(len(c.LogFile) > 0, "log_file is not set")
+	check(len(c.SecretKey) > 0, "secret_key is not set")
+	check(c.MaxBodySize > 0, "max_body_size must be positive")
+
+	positive("token_lifetime", c.TokenLifetime)
+	positive("read_timeout", c.ReadTimeout)
+	positive("write_timeout", c.WriteTimeout)
+	positive("idle_timeout", c.IdleTimeout)
+
+	check(len(c.DatabaseConfig.ConnURI) > 0, "database_uri is not set")
+	check(c.DatabaseConfig.Timeout >= 0, "db_connect_timeout must not be negative")
+	check(c.DatabaseConfig.MaxOpenConns >= 0, "db_max_open_conns must not be negative")
+	check(c.DatabaseConfig.MaxIdleConns >= 0, "db_max_idle_conns must not be negative")
+	check(c.DatabaseConfig.ConnMaxLifetime >= 0, "db_conn_max_lifetime must not be negative")
+	check(c.DatabaseConfig.ConnMaxIdleTime >= 0, "db_conn_max_idle_time must not be negative")
+	check(c.DatabaseConfig.StatementTimeout >= 0, "db_statement_timeout must not be negative")
+	check(c.DatabaseConfig.QueryTimeout >= 0, "db_query_timeout must not be negative")
+	check(c.BackgroundQueryTimeout >= 0, "db_background_query_timeout must not be negative")
+
+	positive("processing_interval", c.ProcessingInteval)
+	positive("idempotency_key_ttl", c.IdempotencyKeyTTL)
+	positive("idempotency_lease", c.IdempotencyLease)
+	check(c.IdempotencyLease >= c.WriteTimeout, "idempotency_lease %v must not be less than write_timeout %v",
+		c.IdempotencyLease, c.WriteTimeout)
+	positive("hold_ttl", c.HoldTTL)
+	check(c.PointsLifetime >= 0, "points_lifetime must not be negative, got %v", c.PointsLifetime)
+	check(c.PointsExpiryWarning >= 0, "points_expiry_warning must not be negative")
+	positive("expiry_interval", c.ExpiryInterval)
+	positive("reconcile_interval", c.ReconcileInterval)
+
+	notNegative("transfer_daily_limit", c.TransferDailyLimit)
+	notNegative("withdraw_min", c.WithdrawMin)
+	notNegative("withdraw_max", c.WithdrawMax)
+	notNegative("withdraw_daily_limit", c.WithdrawDaily)
+	notNegative("withdraw_monthly_limit", c.WithdrawMonthly)
+	check(c.WithdrawMax == 0 || c.WithdrawMin <= c.WithdrawMax,
+		"withdraw_min %v is greater than withdraw_max %v", c.WithdrawMin, c.WithdrawMax)
+
+	check(c.TierSilverThreshold > 0 && c.TierSilverThreshold < c.TierGoldThreshold,
+		"tier thresholds must be positive and silver %v must be less than gold %v",
+		c.TierSilverThreshold, c.TierGoldThreshold)
+	check(c.TierSilverMultiplier > 0 && c.TierGoldMultiplier > 0,
+		"tier multipliers must be positive, got silver %v and gold %v", c.TierSilverMultiplier, c.TierGoldMultiplier)
+	positive("tier_interval", c.TierInterval)
+	notNegative("referral_bonus", c.ReferralBonus)
+	notNegative("referral_referee_bonus", c.ReferralRefereeBonus)
+
+	positive("webhook_interval", c.WebhookInterval)
+	positive("webhook_timeout", c.WebhookTimeout)
+	check(c.WebhookMaxAttempts > 0, "webhook_max_attempts must be positive, got %v", c.WebhookMaxAttempts)
+	positive("events_heartbeat", c.EventsHeartbeat)
+	check(c.EventBufferSize > 0, "event_buffer_size must be positive, got %v", c.EventBufferSize)
+	positive("outbox_interval", c.OutboxInterval)
+	positive("outbox_timeout", c.OutboxTimeout)
+	positive("outbox_retention", c.OutboxRetention)
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+
+	return nil
+}
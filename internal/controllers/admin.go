@@ -0,0 +1,52 @@
+package controllers
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	authHeader   = "Authorization"
+	bearerPrefix = "Bearer "
+)
+
+// AdminController guards API used by admins and partner systems
+type AdminController struct {
+	key    []byte
+	logger *zap.SugaredLogger
+}
+
+func NewAdminController(key []byte, logger *zap.SugaredLogger) *AdminController {
+	return &AdminController{
+		key:    key,
+		logger: logger,
+	}
+}
+
+func (c *AdminController) Authenticate(ctx *gin.Context) {
+	if len(c.key) == 0 {
+		c.logger.Debug("Admin API is disabled. No admin key configured")
+		ctx.AbortWithStatus(http.StatusForbidden)
+		return
+	}
+
+	header := ctx.GetHeader(authHeader)
+	if !strings.HasPrefix(header, bearerPrefix) {
+		c.logger.Debug("Admin authentication failed. No admin key provided")
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	key := []byte(strings.TrimPrefix(header, bearerPrefix))
+	if subtle.ConstantTimeCompare(key, c.key) != 1 {
+		c.logger.Debug("Admin authentication failed. Invalid admin key")
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	ctx.Next()
+}
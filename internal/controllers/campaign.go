@@ -0,0 +1,98 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/fuzzy-toozy/gophermart/internal/models"
+	"github.com/fuzzy-toozy/gophermart/internal/services"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type CampaignController struct {
+	service *services.CampaignService
+	logger  *zap.SugaredLogger
+}
+
+func NewCampaignController(service *services.CampaignService, logger *zap.SugaredLogger) *CampaignController {
+	return &CampaignController{
+		service: service,
+		logger:  logger,
+	}
+}
+
+func (c *CampaignController) AddCampaign(ctx *gin.Context) {
+	campaign := models.Campaign{}
+
+	if err := ctx.BindJSON(&campaign); err != nil {
+		c.logger.Debugf("Failed to bind request data: %v", err)
+		ctx.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	err := c.service.AddCampaign(ctx, &campaign)
+	if err != nil {
+		c.logger.Debugf("Failed to add campaign: %v", err)
+		ctx.AbortWithStatus(err.GetStatus())
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, campaign)
+}
+
+func (c *CampaignController) GetCampaign(ctx *gin.Context) {
+	id := ctx.Param("id")
+
+	campaign, err := c.service.GetCampaign(ctx, id)
+	if err != nil {
+		c.logger.Debugf("Failed to get campaign '%v': %v", id, err)
+		ctx.AbortWithStatus(err.GetStatus())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, campaign)
+}
+
+func (c *CampaignController) GetAllCampaigns(ctx *gin.Context) {
+	campaigns, err := c.service.GetAllCampaigns(ctx)
+	if err != nil {
+		c.logger.Debugf("Failed to get all campaigns: %v", err)
+		ctx.AbortWithStatus(err.GetStatus())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, campaigns)
+}
+
+func (c *CampaignController) UpdateCampaign(ctx *gin.Context) {
+	id := ctx.Param("id")
+	campaign := models.Campaign{}
+
+	if err := ctx.BindJSON(&campaign); err != nil {
+		c.logger.Debugf("Failed to bind request data: %v", err)
+		ctx.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	err := c.service.UpdateCampaign(ctx, id, &campaign)
+	if err != nil {
+		c.logger.Debugf("Failed to update campaign '%v': %v", id, err)
+		ctx.AbortWithStatus(err.GetStatus())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, campaign)
+}
+
+func (c *CampaignController) DeleteCampaign(ctx *gin.Context) {
+	id := ctx.Param("id")
+
+	err := c.service.DeleteCampaign(ctx, id)
+	if err != nil {
+		c.logger.Debugf("Failed to delete campaign '%v': %v", id, err)
+		ctx.AbortWithStatus(err.GetStatus())
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/fuzzy-toozy/gophermart/internal/common"
+	"github.com/fuzzy-toozy/gophermart/internal/events"
+	"github.com/fuzzy-toozy/gophermart/internal/models"
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type EventsController struct {
+	bus       *events.Bus
+	heartbeat time.Duration
+	logger    *zap.SugaredLogger
+}
+
+func NewEventsController(bus *events.Bus, heartbeat time.Duration, logger *zap.SugaredLogger) *EventsController {
+	return &EventsController{
+		bus:       bus,
+		heartbeat: heartbeat,
+		logger:    logger,
+	}
+}
+
+// Stream sends order and balance events of the user as server-sent events.
+// Events missed since Last-Event-ID are replayed while they are still buffered,
+// otherwise resync event is sent and the client has to fetch orders and balance again.
+func (c *EventsController) Stream(ctx *gin.Context) {
+	username := ctx.GetString(common.UsernameCtxKey)
+
+	lastEventID := ctx.GetHeader("Last-Event-ID")
+	if len(lastEventID) == 0 {
+		lastEventID = ctx.Query("lastEventId")
+	}
+
+	// The stream lives longer than the server write timeout
+	err := http.NewResponseController(ctx.Writer).SetWriteDeadline(time.Time{})
+	if err != nil {
+		c.logger.Debugf("Failed to reset write deadline of events stream: %v", err)
+	}
+
+	missed, sub := c.bus.Subscribe(username, lastEventID)
+	defer c.bus.Unsubscribe(sub)
+
+	ctx.Header("Content-Type", "text/event-stream")
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("Connection", "keep-alive")
+	ctx.Header("X-Accel-Buffering", "no")
+	ctx.Status(http.StatusOK)
+	ctx.Writer.Flush()
+
+	for i := range missed {
+		c.send(ctx, &missed[i])
+	}
+
+	heartbeat := time.NewTicker(c.heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Request.Context().Done():
+			return
+		case e, ok := <-sub.C:
+			if !ok {
+				// Subscriber fell behind, client reconnects with Last-Event-ID
+				c.logger.Debugf("Events stream of user '%v' is dropped", username)
+				return
+			}
+			c.send(ctx, &e)
+		case <-heartbeat.C:
+			if _, err := io.WriteString(ctx.Writer, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			ctx.Writer.Flush()
+		}
+	}
+}
+
+func (c *EventsController) send(ctx *gin.Context, e *models.Event) {
+	ctx.Render(-1, sse.Event{
+		Id:    e.ID,
+		Event: e.Type,
+		Data:  e,
+	})
+	ctx.Writer.Flush()
+}
@@ -0,0 +1,36 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/fuzzy-toozy/gophermart/internal/services"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type HealthController struct {
+	service *services.HealthService
+	logger  *zap.SugaredLogger
+}
+
+func NewHealthController(service *services.HealthService, logger *zap.SugaredLogger) *HealthController {
+	return &HealthController{
+		service: service,
+		logger:  logger,
+	}
+}
+
+func (c *HealthController) Check(ctx *gin.Context) {
+	err := c.service.Check(ctx)
+	if err != nil {
+		c.logger.Errorf("Health check failed: %v", err)
+		ctx.AbortWithStatus(err.GetStatus())
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+func (c *HealthController) GetPoolStats(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, c.service.GetPoolStats())
+}
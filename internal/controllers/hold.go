@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/fuzzy-toozy/gophermart/internal/common"
+	"github.com/fuzzy-toozy/gophermart/internal/models"
+	"github.com/fuzzy-toozy/gophermart/internal/services"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type HoldController struct {
+	service *services.HoldService
+	logger  *zap.SugaredLogger
+}
+
+func NewHoldController(service *services.HoldService, logger *zap.SugaredLogger) *HoldController {
+	return &HoldController{
+		service: service,
+		logger:  logger,
+	}
+}
+
+func (c *HoldController) PlaceHold(ctx *gin.Context) {
+	req := models.HoldRequest{}
+
+	if err := ctx.BindJSON(&req); err != nil {
+		c.logger.Debugf("Failed to bind request data: %v", err)
+		ctx.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	username := ctx.GetString(common.UsernameCtxKey)
+
+	hold, err := c.service.PlaceHold(ctx, &req, username)
+	if err != nil {
+		c.logger.Debugf("Failed to place hold for user '%v': %v", username, err)
+		ctx.AbortWithStatus(err.GetStatus())
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, hold)
+}
+
+func (c *HoldController) GetHold(ctx *gin.Context) {
+	id := ctx.Param("id")
+	username := ctx.GetString(common.UsernameCtxKey)
+
+	hold, err := c.service.GetHold(ctx, id, username)
+	if err != nil {
+		c.logger.Debugf("Failed to get hold '%v' for user '%v': %v", id, username, err)
+		ctx.AbortWithStatus(err.GetStatus())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, hold)
+}
+
+func (c *HoldController) GetAllHolds(ctx *gin.Context) {
+	username := ctx.GetString(common.UsernameCtxKey)
+
+	holds, err := c.service.GetAllHolds(ctx, username)
+	if err != nil {
+		c.logger.Debugf("Failed to get all holds for user '%v': %v", username, err)
+		ctx.AbortWithStatus(err.GetStatus())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, holds)
+}
+
+func (c *HoldController) CaptureHold(ctx *gin.Context) {
+	req := models.CaptureRequest{}
+
+	if err := ctx.BindJSON(&req); err != nil {
+		c.logger.Debugf("Failed to bind request data: %v", err)
+		ctx.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	id := ctx.Param("id")
+	username := ctx.GetString(common.UsernameCtxKey)
+
+	hold, err := c.service.CaptureHold(ctx, id, &req, username)
+	if err != nil {
+		c.logger.Debugf("Failed to capture hold '%v' for user '%v': %v", id, username, err)
+		ctx.AbortWithStatus(err.GetStatus())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, hold)
+}
+
+func (c *HoldController) ReleaseHold(ctx *gin.Context) {
+	id := ctx.Param("id")
+	username := ctx.GetString(common.UsernameCtxKey)
+
+	hold, err := c.service.ReleaseHold(ctx, id, username)
+	if err != nil {
+		c.logger.Debugf("Failed to release hold '%v' for user '%v': %v", id, username, err)
+		ctx.AbortWithStatus(err.GetStatus())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, hold)
+}
@@ -0,0 +1,125 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/fuzzy-toozy/gophermart/internal/common"
+	"github.com/fuzzy-toozy/gophermart/internal/services"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	idempotencyKeyHeader      = "Idempotency-Key"
+	idempotencyReplayedHeader = "Idempotent-Replayed"
+	// Body is kept in memory to fingerprint the request
+	maxIdempotentBodySize = 1 << 20
+)
+
+type IdempotencyController struct {
+	service *services.IdempotencyService
+	logger  *zap.SugaredLogger
+}
+
+func NewIdempotencyController(service *services.IdempotencyService, logger *zap.SugaredLogger) *IdempotencyController {
+	return &IdempotencyController{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// responseRecorder keeps a copy of the response body to store it with the idempotency key
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Handle makes the next handlers idempotent for requests having Idempotency-Key header.
+// Requests without the header are passed through as is.
+func (c *IdempotencyController) Handle(ctx *gin.Context) {
+	key := ctx.GetHeader(idempotencyKeyHeader)
+	if len(key) == 0 {
+		ctx.Next()
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxIdempotentBodySize))
+	if err != nil {
+		c.logger.Debugf("Failed to read request body: %v", err)
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			ctx.AbortWithStatus(http.StatusRequestEntityTooLarge)
+		} else {
+			ctx.AbortWithStatus(http.StatusBadRequest)
+		}
+		return
+	}
+
+	ctx.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	username := ctx.GetString(common.UsernameCtxKey)
+	fingerprint := services.Fingerprint(ctx.Request.Method, ctx.FullPath(), body)
+
+	stored, serr := c.service.Begin(ctx, username, key, fingerprint)
+	if serr != nil {
+		c.logger.Debugf("Idempotency key '%v' check failed for user '%v': %v", key, username, serr)
+		ctx.AbortWithStatus(serr.GetStatus())
+		return
+	}
+
+	if stored != nil {
+		c.logger.Debugf("Replaying response for idempotency key '%v' of user '%v'", key, username)
+		ctx.Header(idempotencyReplayedHeader, "true")
+		if len(stored.ResponseBody) == 0 {
+			ctx.AbortWithStatus(stored.ResponseStatus)
+		} else {
+			ctx.Data(stored.ResponseStatus, stored.ContentType, stored.ResponseBody)
+			ctx.Abort()
+		}
+		return
+	}
+
+	// Request context is cancelled if client disconnects, but the result must be stored anyway,
+	// otherwise the key stays pending and retries are rejected
+	saveCtx := context.WithoutCancel(ctx)
+
+	stopLease := c.service.KeepLease(saveCtx, username, key, func(err error) {
+		c.logger.Errorf("Failed to keep idempotency key: %v", err)
+	})
+
+	recorder := &responseRecorder{ResponseWriter: ctx.Writer}
+	ctx.Writer = recorder
+
+	ctx.Next()
+
+	stopLease()
+
+	status := recorder.Status()
+
+	// Server errors are not stored so the client is able to retry the request
+	if status >= http.StatusInternalServerError {
+		if serr := c.service.Release(saveCtx, username, key); serr != nil {
+			c.logger.Errorf("Failed to release idempotency key: %v", serr)
+		}
+		return
+	}
+
+	serr = c.service.Complete(saveCtx, username, key, status, recorder.Header().Get("Content-Type"), recorder.body.Bytes())
+	if serr != nil {
+		c.logger.Errorf("Failed to complete idempotent request: %v", serr)
+	}
+}
@@ -43,3 +43,50 @@ func (c *ProcessContoller) Withdraw(ctx *gin.Context) {
 
 	ctx.Status(http.StatusOK)
 }
+
+func (c *ProcessContoller) CompleteWithdrawal(ctx *gin.Context) {
+	number := ctx.Param("number")
+
+	wd, err := c.service.CompleteWithdrawal(ctx, number)
+	if err != nil {
+		c.logger.Debugf("Failed to complete withdrawal '%v': %v", number, err)
+		ctx.AbortWithStatus(err.GetStatus())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, wd)
+}
+
+func (c *ProcessContoller) CancelWithdrawal(ctx *gin.Context) {
+	number := ctx.Param("number")
+
+	wd, err := c.service.CancelWithdrawal(ctx, number)
+	if err != nil {
+		c.logger.Debugf("Failed to cancel withdrawal '%v': %v", number, err)
+		ctx.AbortWithStatus(err.GetStatus())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, wd)
+}
+
+func (c *ProcessContoller) AdjustBalance(ctx *gin.Context) {
+	adjustment := models.Adjustment{}
+
+	if err := ctx.BindJSON(&adjustment); err != nil {
+		c.logger.Debugf("Failed to bind request data: %v", err)
+		ctx.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	username := ctx.Param("login")
+
+	err := c.service.AdjustBalance(ctx, username, &adjustment)
+	if err != nil {
+		c.logger.Debugf("Failed to adjust balance of user '%v': %v", username, err)
+		ctx.AbortWithStatus(err.GetStatus())
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/fuzzy-toozy/gophermart/internal/common"
+	"github.com/fuzzy-toozy/gophermart/internal/models"
+	"github.com/fuzzy-toozy/gophermart/internal/services"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type PromoController struct {
+	service *services.PromoService
+	logger  *zap.SugaredLogger
+}
+
+func NewPromoController(service *services.PromoService, logger *zap.SugaredLogger) *PromoController {
+	return &PromoController{
+		service: service,
+		logger:  logger,
+	}
+}
+
+func (c *PromoController) GeneratePromoCodes(ctx *gin.Context) {
+	req := models.PromoCodeRequest{}
+
+	if err := ctx.BindJSON(&req); err != nil {
+		c.logger.Debugf("Failed to bind request data: %v", err)
+		ctx.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	codes, err := c.service.GeneratePromoCodes(ctx, &req)
+	if err != nil {
+		c.logger.Debugf("Failed to generate promo codes: %v", err)
+		ctx.AbortWithStatus(err.GetStatus())
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, codes)
+}
+
+func (c *PromoController) GetAllPromoCodes(ctx *gin.Context) {
+	codes, err := c.service.GetAllPromoCodes(ctx)
+	if err != nil {
+		c.logger.Debugf("Failed to get all promo codes: %v", err)
+		ctx.AbortWithStatus(err.GetStatus())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, codes)
+}
+
+func (c *PromoController) Redeem(ctx *gin.Context) {
+	req := models.PromoRedeemRequest{}
+
+	if err := ctx.BindJSON(&req); err != nil {
+		c.logger.Debugf("Failed to bind request data: %v", err)
+		ctx.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	username := ctx.GetString(common.UsernameCtxKey)
+
+	promo, err := c.service.Redeem(ctx, &req, username)
+	if err != nil {
+		c.logger.Debugf("Failed to redeem promo code for user '%v': %v", username, err)
+		ctx.AbortWithStatus(err.GetStatus())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, promo)
+}
@@ -0,0 +1,126 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/fuzzy-toozy/gophermart/internal/common"
+	"github.com/fuzzy-toozy/gophermart/internal/models"
+	"github.com/fuzzy-toozy/gophermart/internal/services"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type RewardController struct {
+	service *services.RewardService
+	logger  *zap.SugaredLogger
+}
+
+func NewRewardController(service *services.RewardService, logger *zap.SugaredLogger) *RewardController {
+	return &RewardController{
+		service: service,
+		logger:  logger,
+	}
+}
+
+func (c *RewardController) AddReward(ctx *gin.Context) {
+	reward := models.Reward{}
+
+	if err := ctx.BindJSON(&reward); err != nil {
+		c.logger.Debugf("Failed to bind request data: %v", err)
+		ctx.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	err := c.service.AddReward(ctx, &reward)
+	if err != nil {
+		c.logger.Debugf("Failed to add reward: %v", err)
+		ctx.AbortWithStatus(err.GetStatus())
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, reward)
+}
+
+func (c *RewardController) GetReward(ctx *gin.Context) {
+	id := ctx.Param("id")
+
+	reward, err := c.service.GetReward(ctx, id)
+	if err != nil {
+		c.logger.Debugf("Failed to get reward '%v': %v", id, err)
+		ctx.AbortWithStatus(err.GetStatus())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, reward)
+}
+
+func (c *RewardController) GetAllRewards(ctx *gin.Context) {
+	rewards, err := c.service.GetAllRewards(ctx)
+	if err != nil {
+		c.logger.Debugf("Failed to get all rewards: %v", err)
+		ctx.AbortWithStatus(err.GetStatus())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, rewards)
+}
+
+func (c *RewardController) UpdateReward(ctx *gin.Context) {
+	id := ctx.Param("id")
+	reward := models.Reward{}
+
+	if err := ctx.BindJSON(&reward); err != nil {
+		c.logger.Debugf("Failed to bind request data: %v", err)
+		ctx.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	err := c.service.UpdateReward(ctx, id, &reward)
+	if err != nil {
+		c.logger.Debugf("Failed to update reward '%v': %v", id, err)
+		ctx.AbortWithStatus(err.GetStatus())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, reward)
+}
+
+func (c *RewardController) DeleteReward(ctx *gin.Context) {
+	id := ctx.Param("id")
+
+	err := c.service.DeleteReward(ctx, id)
+	if err != nil {
+		c.logger.Debugf("Failed to delete reward '%v': %v", id, err)
+		ctx.AbortWithStatus(err.GetStatus())
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+func (c *RewardController) Redeem(ctx *gin.Context) {
+	id := ctx.Param("id")
+	username := ctx.GetString(common.UsernameCtxKey)
+
+	redemption, err := c.service.Redeem(ctx, id, username)
+	if err != nil {
+		c.logger.Debugf("Failed to redeem reward '%v' for user '%v': %v", id, username, err)
+		ctx.AbortWithStatus(err.GetStatus())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, redemption)
+}
+
+func (c *RewardController) GetAllRedemptions(ctx *gin.Context) {
+	username := ctx.GetString(common.UsernameCtxKey)
+
+	redemptions, err := c.service.GetAllRedemptions(ctx, username)
+	if err != nil {
+		c.logger.Debugf("Failed to get all redemptions for user '%v': %v", username, err)
+		ctx.AbortWithStatus(err.GetStatus())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, redemptions)
+}
@@ -0,0 +1,57 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/fuzzy-toozy/gophermart/internal/common"
+	"github.com/fuzzy-toozy/gophermart/internal/models"
+	"github.com/fuzzy-toozy/gophermart/internal/services"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type TransferController struct {
+	service *services.TransferService
+	logger  *zap.SugaredLogger
+}
+
+func NewTransferController(service *services.TransferService, logger *zap.SugaredLogger) *TransferController {
+	return &TransferController{
+		service: service,
+		logger:  logger,
+	}
+}
+
+func (c *TransferController) Transfer(ctx *gin.Context) {
+	req := models.TransferRequest{}
+
+	if err := ctx.BindJSON(&req); err != nil {
+		c.logger.Debugf("Failed to bind request data: %v", err)
+		ctx.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	username := ctx.GetString(common.UsernameCtxKey)
+
+	transfer, err := c.service.Transfer(ctx, &req, username)
+	if err != nil {
+		c.logger.Debugf("Failed to transfer points from user '%v': %v", username, err)
+		ctx.AbortWithStatus(err.GetStatus())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, transfer)
+}
+
+func (c *TransferController) GetAllTransfers(ctx *gin.Context) {
+	username := ctx.GetString(common.UsernameCtxKey)
+
+	transfers, err := c.service.GetAllTransfers(ctx, username)
+	if err != nil {
+		c.logger.Debugf("Failed to get all transfers for user '%v': %v", username, err)
+		ctx.AbortWithStatus(err.GetStatus())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, transfers)
+}
@@ -73,6 +73,19 @@ func (c *UserController) Login(ctx *gin.Context) {
 	ctx.Status(http.StatusOK)
 }
 
+func (c *UserController) GetReferralInfo(ctx *gin.Context) {
+	username := ctx.GetString(common.UsernameCtxKey)
+
+	info, err := c.service.GetReferralInfo(ctx, username)
+	if err != nil {
+		c.logger.Debugf("Failed to get referral info for user '%v': %v", username, err)
+		ctx.AbortWithStatus(err.GetStatus())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, info)
+}
+
 func (c *UserController) Authenticate(ctx *gin.Context) {
 	signedToken, err := ctx.Cookie(tokenCookieKey)
 	if err != nil {
@@ -0,0 +1,78 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/fuzzy-toozy/gophermart/internal/models"
+	"github.com/fuzzy-toozy/gophermart/internal/services"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type WebhookController struct {
+	service *services.WebhookService
+	logger  *zap.SugaredLogger
+}
+
+func NewWebhookController(service *services.WebhookService, logger *zap.SugaredLogger) *WebhookController {
+	return &WebhookController{
+		service: service,
+		logger:  logger,
+	}
+}
+
+func (c *WebhookController) AddSubscription(ctx *gin.Context) {
+	subscription := models.WebhookSubscription{}
+
+	if err := ctx.BindJSON(&subscription); err != nil {
+		c.logger.Debugf("Failed to bind request data: %v", err)
+		ctx.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	err := c.service.AddSubscription(ctx, &subscription)
+	if err != nil {
+		c.logger.Debugf("Failed to add webhook subscription: %v", err)
+		ctx.AbortWithStatus(err.GetStatus())
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, subscription)
+}
+
+func (c *WebhookController) GetAllSubscriptions(ctx *gin.Context) {
+	subscriptions, err := c.service.GetAllSubscriptions(ctx)
+	if err != nil {
+		c.logger.Debugf("Failed to get all webhook subscriptions: %v", err)
+		ctx.AbortWithStatus(err.GetStatus())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, subscriptions)
+}
+
+func (c *WebhookController) DeleteSubscription(ctx *gin.Context) {
+	id := ctx.Param("id")
+
+	err := c.service.DeleteSubscription(ctx, id)
+	if err != nil {
+		c.logger.Debugf("Failed to delete webhook subscription '%v': %v", id, err)
+		ctx.AbortWithStatus(err.GetStatus())
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+func (c *WebhookController) GetDeliveryLog(ctx *gin.Context) {
+	id := ctx.Param("id")
+
+	attempts, err := c.service.GetDeliveryLog(ctx, id)
+	if err != nil {
+		c.logger.Debugf("Failed to get delivery log of webhook subscription '%v': %v", id, err)
+		ctx.AbortWithStatus(err.GetStatus())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, attempts)
+}
@@ -1,6 +1,9 @@
 package database
 
 import (
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"go.uber.org/zap"
@@ -9,11 +12,48 @@ import (
 type DBConfig struct {
 	ConnURI    string
 	DriverName string
-	Timeout    time.Duration
+	// Timeout limits waiting for the database to become reachable on startup
+	Timeout time.Duration
+	// Pool settings. Zero value keeps database/sql default.
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+	// StatementTimeout aborts queries running longer on the server side. Zero disables it.
+	StatementTimeout time.Duration
+	// QueryTimeout limits every repository query on the client side. Zero disables it.
+	QueryTimeout time.Duration
 }
 
 func (c *DBConfig) Print(logger *zap.SugaredLogger) {
 	logger.Infof("Conn string: %v", c.ConnURI)
 	logger.Infof("Driver name: %v", c.DriverName)
 	logger.Infof("Ping timeout: %v", c.Timeout)
+	logger.Infof("Max open connections: %v", c.MaxOpenConns)
+	logger.Infof("Max idle connections: %v", c.MaxIdleConns)
+	logger.Infof("Connection max lifetime: %v", c.ConnMaxLifetime)
+	logger.Infof("Connection max idle time: %v", c.ConnMaxIdleTime)
+	logger.Infof("Statement timeout: %v", c.StatementTimeout)
+	logger.Infof("Query timeout: %v", c.QueryTimeout)
+}
+
+// poolConnURI adds session settings to the connection string used by the pool
+func (c *DBConfig) poolConnURI() string {
+	if c.StatementTimeout <= 0 {
+		return c.ConnURI
+	}
+
+	timeout := c.StatementTimeout.Milliseconds()
+
+	u, err := url.Parse(c.ConnURI)
+	if err != nil || (u.Scheme != "postgres" && u.Scheme != "postgresql") {
+		// Key-value connection string
+		return strings.TrimSpace(c.ConnURI) + " statement_timeout=" + strconv.FormatInt(timeout, 10)
+	}
+
+	q := u.Query()
+	q.Set("statement_timeout", strconv.FormatInt(timeout, 10))
+	u.RawQuery = q.Encode()
+
+	return u.String()
 }
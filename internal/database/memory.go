@@ -0,0 +1,126 @@
+package database
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// MemoryURIScheme selects the in-memory storage instead of a database
+const MemoryURIScheme = "memory://"
+
+// IsMemoryURI reports whether the connection string selects the in-memory storage
+func IsMemoryURI(uri string) bool {
+	return strings.HasPrefix(uri, MemoryURIScheme)
+}
+
+type memoryTxCtxKey struct{}
+
+type memoryTx struct {
+	undo          []func()
+	notifications []notification
+}
+
+func (t *memoryTx) rollback() {
+	for i := len(t.undo) - 1; i >= 0; i-- {
+		t.undo[i]()
+	}
+}
+
+// MemoryStorage serializes transactions of in-memory repositories with a single lock.
+// Changes made by a failed transaction are undone, so repositories keep the same
+// all-or-nothing semantics as with a database. Data is lost when the process exits.
+type MemoryStorage struct {
+	lock     sync.Mutex
+	notifier *localNotifier
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		notifier: newLocalNotifier(),
+	}
+}
+
+// RunInTransaction runs callback holding the storage lock. Nested calls join the outer transaction.
+func (s *MemoryStorage) RunInTransaction(ctx context.Context, callback func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(memoryTxCtxKey{}).(*memoryTx); ok {
+		return callback(ctx)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return classifyError(err)
+	}
+
+	tx := &memoryTx{}
+
+	err := s.run(context.WithValue(ctx, memoryTxCtxKey{}, tx), tx, callback)
+	if err != nil {
+		return err
+	}
+
+	for _, n := range tx.notifications {
+		s.notifier.notify(n.channel, n.payload)
+	}
+
+	return nil
+}
+
+func (s *MemoryStorage) run(ctx context.Context, tx *memoryTx, callback func(ctx context.Context) error) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	committed := false
+	defer func() {
+		if !committed {
+			tx.rollback()
+		}
+	}()
+
+	if err := callback(ctx); err != nil {
+		return err
+	}
+
+	committed = true
+
+	return nil
+}
+
+// OnRollback registers undo of a change made in the transaction of ctx
+func (s *MemoryStorage) OnRollback(ctx context.Context, undo func()) {
+	if tx, ok := ctx.Value(memoryTxCtxKey{}).(*memoryTx); ok {
+		tx.undo = append(tx.undo, undo)
+	}
+}
+
+// LockKey does nothing, because transactions are serialized by the storage lock already
+func (s *MemoryStorage) LockKey(ctx context.Context, key string) error {
+	return nil
+}
+
+// Notify wakes up the channel listeners. Inside a transaction they are woken up when it commits.
+func (s *MemoryStorage) Notify(ctx context.Context, channel string, payload string) error {
+	if tx, ok := ctx.Value(memoryTxCtxKey{}).(*memoryTx); ok {
+		tx.notifications = append(tx.notifications, notification{channel: channel, payload: payload})
+		return nil
+	}
+
+	s.notifier.notify(channel, payload)
+
+	return nil
+}
+
+// Listen returns a channel receiving payloads the channel is notified with. Notifications coming
+// faster than they are received are merged. Listening stops when ctx is done.
+func (s *MemoryStorage) Listen(ctx context.Context, channel string, logger *zap.SugaredLogger) (<-chan []string, error) {
+	return s.notifier.listen(ctx, channel), nil
+}
+
+func (s *MemoryStorage) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (s *MemoryStorage) Close() error {
+	return nil
+}
@@ -2,27 +2,113 @@ package database
 
 import (
 	"errors"
+	"fmt"
+	"io/fs"
+	"path"
 
+	"github.com/fuzzy-toozy/gophermart/migration"
 	"github.com/golang-migrate/migrate"
+	bindata "github.com/golang-migrate/migrate/source/go_bindata"
 
 	_ "github.com/golang-migrate/migrate/database/postgres"
-	_ "github.com/golang-migrate/migrate/source/file"
+	_ "github.com/golang-migrate/migrate/database/sqlite3"
 	_ "github.com/lib/pq"
 )
 
-func Migrate(dbURL string) error {
-	m, err := migrate.New("file://migration/dbscripts/", dbURL)
+// Migrator applies migrations embedded into the binary
+type Migrator struct {
+	m *migrate.Migrate
+}
+
+// NewMigrator creates migrator of the database. SQLite databases have their own scripts.
+func NewMigrator(dbURL string) (*Migrator, error) {
+	scripts, dir := fs.FS(migration.Scripts), migration.ScriptsDir
+	if IsSQLiteURI(dbURL) {
+		scripts, dir = migration.SQLiteScripts, migration.SQLiteScriptsDir
+	}
+
+	entries, err := fs.ReadDir(scripts, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read embedded migrations: %w", err)
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+
+	source, err := bindata.WithInstance(bindata.Resource(names, func(name string) ([]byte, error) {
+		return fs.ReadFile(scripts, path.Join(dir, name))
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open embedded migrations: %w", err)
+	}
 
+	m, err := migrate.NewWithSourceInstance("go-bindata", source, dbURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create migrator: %w", err)
+	}
+
+	return &Migrator{m: m}, nil
+}
+
+// Up applies all migrations not applied yet
+func (m *Migrator) Up() error {
+	err := m.m.Up()
 	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
 		return err
 	}
 
-	err = m.Up()
+	return nil
+}
+
+// Down reverts the given number of the latest migrations
+func (m *Migrator) Down(steps int) error {
+	if steps <= 0 {
+		return fmt.Errorf("invalid number of migrations to revert: %v", steps)
+	}
+
+	err := m.m.Steps(-steps)
 	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
 		return err
 	}
 
-	m.Close()
-
 	return nil
 }
+
+// Version returns the current migration version. Dirty version failed and has to be fixed
+// manually and forced.
+func (m *Migrator) Version() (uint, bool, error) {
+	version, dirty, err := m.m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return 0, false, nil
+	}
+
+	return version, dirty, err
+}
+
+// Force sets the migration version without running migrations and clears dirty state
+func (m *Migrator) Force(version int) error {
+	return m.m.Force(version)
+}
+
+func (m *Migrator) Close() error {
+	serr, derr := m.m.Close()
+	if serr != nil {
+		return serr
+	}
+
+	return derr
+}
+
+// Migrate applies all migrations not applied yet
+func Migrate(dbURL string) error {
+	m, err := NewMigrator(dbURL)
+	if err != nil {
+		return err
+	}
+
+	defer m.Close()
+
+	return m.Up()
+}
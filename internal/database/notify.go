@@ -0,0 +1,198 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+// NewOrdersChannel is notified with the order number when a new order is uploaded
+const NewOrdersChannel = "new_orders"
+
+const (
+	listenerMinReconnect = 100 * time.Millisecond
+	listenerMaxReconnect = 10 * time.Second
+)
+
+// maxMergedPayloads bounds payloads kept for a listener which doesn't keep up. When it's
+// exceeded, the payloads are replaced with LostNotifications.
+const maxMergedPayloads = 1000
+
+// LostNotifications is received by a listener instead of payloads which may be lost
+const LostNotifications = ""
+
+// notification is a payload sent to the channel
+type notification struct {
+	channel string
+	payload string
+}
+
+// Notify sends notification to the channel listeners. Inside a transaction
+// the notification is delivered only when the transaction commits.
+func (s *ServiceStorage) Notify(ctx context.Context, channel string, payload string) error {
+	if s.notifier != nil {
+		if pending, ok := ctx.Value(pendingNotifyCtxKey{}).(*[]notification); ok {
+			*pending = append(*pending, notification{channel: channel, payload: payload})
+		} else {
+			s.notifier.notify(channel, payload)
+		}
+
+		return nil
+	}
+
+	ctx, cancel := s.WithTimeout(ctx)
+	defer cancel()
+
+	_, err := s.Executor(ctx).ExecContext(ctx, s.queries.notifyQuery, channel, payload)
+	if err != nil {
+		return fmt.Errorf("failed to notify channel '%v': %w", channel, err)
+	}
+
+	return nil
+}
+
+// Listen returns a channel receiving payloads the database channel is notified with.
+// Notifications coming faster than they are received are merged, so each receive returns
+// all distinct payloads sent since the previous one. LostNotifications is received when
+// notifications may be lost, e.g. when the listener reconnects. Listening stops when ctx is done.
+func (s *ServiceStorage) Listen(ctx context.Context, channel string, logger *zap.SugaredLogger) (<-chan []string, error) {
+	// SQLite serves a single node, so notifications of the process are enough
+	if s.notifier != nil {
+		return s.notifier.listen(ctx, channel), nil
+	}
+
+	onEvent := func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			logger.Warnf("Listener of channel '%v' connection problem: %v", channel, err)
+		}
+	}
+
+	listener := pq.NewListener(s.dbConfig.ConnURI, listenerMinReconnect, listenerMaxReconnect, onEvent)
+
+	err := listener.Listen(channel)
+	if err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to listen channel '%v': %w", channel, err)
+	}
+
+	payloads := make(chan string)
+
+	go func() {
+		defer listener.Close()
+
+		for {
+			select {
+			case n := <-listener.Notify:
+				// Nil is sent after reconnect
+				payload := LostNotifications
+				if n != nil {
+					payload = n.Extra
+				}
+
+				select {
+				case payloads <- payload:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return mergePayloads(ctx, payloads), nil
+}
+
+// mergePayloads collects payloads until they are received, so a burst of notifications
+// is handled at once and repeated payloads are handled once.
+func mergePayloads(ctx context.Context, payloads <-chan string) <-chan []string {
+	merged := make(chan []string)
+
+	go func() {
+		var pending []string
+		seen := make(map[string]bool)
+
+		for {
+			var out chan []string
+			if len(pending) > 0 {
+				out = merged
+			}
+
+			select {
+			case payload := <-payloads:
+				switch {
+				case seen[LostNotifications] || seen[payload]:
+				case len(pending) >= maxMergedPayloads:
+					pending = []string{LostNotifications}
+					seen = map[string]bool{LostNotifications: true}
+				default:
+					pending = append(pending, payload)
+					seen[payload] = true
+				}
+			case out <- pending:
+				pending = nil
+				seen = make(map[string]bool)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return merged
+}
+
+// localListener receives notifications of the local notifier until ctx is done
+type localListener struct {
+	ctx      context.Context
+	payloads chan string
+}
+
+// localNotifier delivers notifications to listeners of the same process. It is used by
+// storages which have no notification mechanism of their own.
+type localNotifier struct {
+	mu        sync.Mutex
+	listeners map[string]map[*localListener]struct{}
+}
+
+func newLocalNotifier() *localNotifier {
+	return &localNotifier{
+		listeners: make(map[string]map[*localListener]struct{}),
+	}
+}
+
+func (n *localNotifier) notify(channel string, payload string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for l := range n.listeners[channel] {
+		select {
+		case l.payloads <- payload:
+		case <-l.ctx.Done():
+		}
+	}
+}
+
+func (n *localNotifier) listen(ctx context.Context, channel string) <-chan []string {
+	l := &localListener{ctx: ctx, payloads: make(chan string)}
+
+	n.mu.Lock()
+	if n.listeners[channel] == nil {
+		n.listeners[channel] = make(map[*localListener]struct{})
+	}
+	n.listeners[channel][l] = struct{}{}
+	n.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		n.mu.Lock()
+		delete(n.listeners[channel], l)
+		n.mu.Unlock()
+	}()
+
+	return mergePayloads(ctx, l.payloads)
+}
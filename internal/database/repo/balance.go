@@ -5,23 +5,55 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"math"
 	"time"
 
 	"github.com/fuzzy-toozy/gophermart/internal/database"
 	"github.com/fuzzy-toozy/gophermart/internal/models"
 )
 
+// BalanceServiceRepo keeps points movements in a double-entry ledger. Every movement is
+// a transaction of postings to ledger accounts which sum up to zero.
 type BalanceServiceRepo interface {
 	AddIncomeRecord(ctx context.Context, username string, orderNumber string, income float64) error
 	AddWithdrawRecord(ctx context.Context, username string, orderNumber string, outcome float64) error
+	AddRefundRecord(ctx context.Context, username string, orderNumber string, income float64) error
+	AddExpiryRecord(ctx context.Context, username string, outcome float64) error
+	AddTransferRecords(ctx context.Context, transfer *models.Transfer) error
+	AddRedemptionRecord(ctx context.Context, username string, redemptionID string, outcome float64) error
+	AddPromoRecord(ctx context.Context, username string, code string, income float64) error
+	AddBonusRecord(ctx context.Context, username string, orderNumber string, campaignID string, bonus float64) error
+	// AddReferralRecord credits the referral bonus. Referee is the user who was invited.
+	AddReferralRecord(ctx context.Context, username string, referee string, bonus float64) error
+	// AddAdjustmentRecord credits positive and debits negative amount to the user wallet
+	AddAdjustmentRecord(ctx context.Context, username string, amount float64, reason string) error
 	GetBanaceData(ctx context.Context, username string) (*models.Balance, error)
-	GetWithdrawals(ctx context.Context, username string) ([]models.Withdrawals, error)
+	// GetExpirablePoints returns points credited before cutoff and not consumed yet.
+	// Points are consumed in FIFO order, oldest first.
+	GetExpirablePoints(ctx context.Context, username string, cutoff time.Time) (float64, error)
+	GetUsersWithExpirablePoints(ctx context.Context, cutoff time.Time) ([]string, error)
+	// GetCampaignBonus returns bonus points the user got from the campaign
+	GetCampaignBonus(ctx context.Context, username string, campaignID string) (float64, error)
+	// GetBalanceDrifts compares stored user balances with balances calculated from the ledger
+	GetBalanceDrifts(ctx context.Context) ([]models.BalanceDrift, error)
+	// GetLedgerViolations returns ledger transactions breaking double-entry invariants
+	GetLedgerViolations(ctx context.Context) ([]models.LedgerViolation, error)
+	// LockUserBalance serializes balance changes of the user until the end of the current transaction
+	LockUserBalance(ctx context.Context, username string) error
 }
 
 type balanceQueryConfig struct {
-	getBalanceData string
-	addNewRecord   string
-	getWithdrawals string
+	getBalanceData              string
+	addWallet                   string
+	addTransaction              string
+	addPosting                  string
+	updateUserBalance           string
+	getBalanceDrifts            string
+	getLedgerViolations         string
+	lockUserBalance             string
+	getExpirablePoints          string
+	getUsersWithExpirablePoints string
+	getCampaignBonus            string
 }
 
 type balanceServiceRepo struct {
@@ -29,123 +61,448 @@ type balanceServiceRepo struct {
 	queries balanceQueryConfig
 }
 
-type balanceRecord struct {
-	username    string
-	processedAt time.Time
-	income      float64
-	outcome     float64
+const (
+	operationAccrual    = "ACCRUAL"
+	operationWithdrawal = "WITHDRAWAL"
+	operationRefund     = "REFUND"
+	operationExpiry     = "EXPIRY"
+	operationTransfer   = "TRANSFER"
+	operationAdjustment = "ADJUSTMENT"
+	operationBonus      = "BONUS"
+	operationReferral   = "REFERRAL"
+	operationReward     = "REWARD"
+	operationPromo      = "PROMO"
+)
+
+// System ledger accounts. Points come to user wallets from sources and leave them to sinks.
+const (
+	accountAccrual    = "accrual"
+	accountRedemption = "redemption"
+	accountExpiry     = "expiry"
+	accountAdjustment = "adjustment"
+	accountCampaigns  = "campaigns"
+	accountReferrals  = "referrals"
+	accountPromotions = "promotions"
+)
+
+// Sums of floats calculated in different order may differ slightly
+const balanceTolerance = 0.001
+
+func walletAccount(username string) string {
+	return "wallet:" + username
+}
+
+type posting struct {
+	account string
+	// username is set for user wallet postings only
+	username string
+	amount   float64
+}
+
+func walletPosting(username string, amount float64) posting {
+	return posting{account: walletAccount(username), username: username, amount: amount}
+}
+
+func accountPosting(account string, amount float64) posting {
+	return posting{account: account, amount: amount}
+}
+
+type ledgerTransaction struct {
+	operation   string
 	orderNumber string
+	reference   string
+	createdAt   time.Time
+	postings    []posting
 }
 
-func newBalanceRecord(username string, ordNumber string, income, outcome float64) *balanceRecord {
-	return &balanceRecord{
-		username:    username,
-		orderNumber: ordNumber,
-		processedAt: time.Now(),
-		income:      income,
-		outcome:     outcome,
+// newLedgerTransaction moves amount from one account to another
+func newLedgerTransaction(operation string, orderNumber string, from posting, to posting) *ledgerTransaction {
+	from.amount = -from.amount
+	return &ledgerTransaction{
+		operation:   operation,
+		orderNumber: orderNumber,
+		createdAt:   time.Now(),
+		postings:    []posting{from, to},
 	}
 }
 
-func newIncomeRecord(username string, ordNumber string, income float64) *balanceRecord {
-	record := newBalanceRecord(username, ordNumber, income, 0)
-	return record
+func (t *ledgerTransaction) validate() error {
+	if len(t.postings) < 2 {
+		return fmt.Errorf("ledger transaction must have at least 2 postings, got %v", len(t.postings))
+	}
+
+	sum := 0.0
+	for _, p := range t.postings {
+		sum += p.amount
+	}
+
+	if math.Abs(sum) > balanceTolerance {
+		return fmt.Errorf("ledger transaction postings sum up to %v instead of zero", sum)
+	}
+
+	return nil
 }
 
-func newOutcomeRecord(username string, ordNumber string, outcome float64) *balanceRecord {
-	record := newBalanceRecord(username, ordNumber, 0, outcome)
-	return record
+// withdrawn returns the change of withdrawn points made by the wallet posting
+func (t *ledgerTransaction) withdrawn(p posting) float64 {
+	switch t.operation {
+	case operationWithdrawal, operationRefund, operationReward:
+		// Refunds compensate cancelled withdrawals
+		return -p.amount
+	default:
+		return 0
+	}
 }
 
+// Expirable points of user wallet postings. Refunds return points consumed by
+// cancelled withdrawals, so they reduce the consumed amount instead of being new points.
+const expirablePoints = "coalesce(sum(p.amount) FILTER (WHERE p.amount > 0 AND t.operation != 'REFUND' AND p.created_at <= $1), 0) + " +
+	"coalesce(sum(p.amount) FILTER (WHERE p.amount < 0), 0) + " +
+	"coalesce(sum(p.amount) FILTER (WHERE t.operation = 'REFUND'), 0)"
+
+const walletPostings = "FROM ledger_postings p JOIN ledger_transactions t ON t.id = p.transaction_id " +
+	"JOIN ledger_accounts a ON a.code = p.account WHERE a.type = 'USER_WALLET'"
+
 func getBalanceQueries() balanceQueryConfig {
 	c := balanceQueryConfig{}
 
-	c.getBalanceData = "SELECT sum(income)-sum(outcome) as current, sum(outcome) as withdraw FROM balances WHERE username=$1"
+	// Active holds are reserved and not available for spending
+	c.getBalanceData = "SELECT " +
+		"coalesce((SELECT current FROM user_balances WHERE username = $1), 0) as current, " +
+		"coalesce((SELECT withdrawn FROM user_balances WHERE username = $1), 0) as withdraw, " +
+		"(SELECT coalesce(sum(sum), 0) FROM holds WHERE username = $1 AND status = 'ACTIVE' AND expires_at > $2) as held"
+
+	c.addWallet = "INSERT INTO ledger_accounts(code, type, username) VALUES ($1, 'USER_WALLET', $2) " +
+		"ON CONFLICT (code) DO NOTHING"
+
+	c.addTransaction = "INSERT INTO ledger_transactions(operation, order_number, reference, created_at) " +
+		"VALUES ($1, $2, $3, $4) RETURNING id"
+
+	c.addPosting = "INSERT INTO ledger_postings(transaction_id, account, amount, created_at) VALUES ($1, $2, $3, $4)"
 
-	c.addNewRecord = "INSERT INTO balances(username, order_number, income, outcome, processed_at) " +
-		"VALUES ($1, $2, $3, $4, $5)"
+	c.updateUserBalance = "INSERT INTO user_balances(username, current, withdrawn, updated_at) VALUES ($1, $2, $3, $4) " +
+		"ON CONFLICT (username) DO UPDATE SET current = user_balances.current + EXCLUDED.current, " +
+		"withdrawn = user_balances.withdrawn + EXCLUDED.withdrawn, updated_at = EXCLUDED.updated_at"
 
-	c.getWithdrawals = "SELECT order_number, outcome, processed_at " +
-		"FROM balances WHERE outcome != 0.0 AND username = $1;"
+	c.getBalanceDrifts = "SELECT coalesce(u.username, l.username), coalesce(u.current, 0), coalesce(l.current, 0), " +
+		"coalesce(u.withdrawn, 0), coalesce(l.withdrawn, 0) FROM user_balances u FULL OUTER JOIN (" +
+		"SELECT a.username, sum(p.amount) as current, " +
+		"-coalesce(sum(p.amount) FILTER (WHERE t.operation IN ('WITHDRAWAL', 'REFUND', 'REWARD')), 0) as withdrawn " +
+		walletPostings + " GROUP BY a.username) l ON u.username = l.username " +
+		"WHERE abs(coalesce(u.current, 0) - coalesce(l.current, 0)) > $1 " +
+		"OR abs(coalesce(u.withdrawn, 0) - coalesce(l.withdrawn, 0)) > $1"
+
+	c.getLedgerViolations = "SELECT t.id, t.operation, count(p.id), coalesce(sum(p.amount), 0) " +
+		"FROM ledger_transactions t LEFT JOIN ledger_postings p ON p.transaction_id = t.id " +
+		"GROUP BY t.id, t.operation HAVING count(p.id) < 2 OR abs(coalesce(sum(p.amount), 0)) > $1"
+
+	c.lockUserBalance = "SELECT username FROM users WHERE username = $1 FOR UPDATE"
+
+	c.getExpirablePoints = "SELECT " + expirablePoints + " " + walletPostings + " AND p.account = $2"
+
+	c.getUsersWithExpirablePoints = "SELECT a.username " + walletPostings +
+		" GROUP BY a.username HAVING " + expirablePoints + " > 0"
+
+	c.getCampaignBonus = "SELECT coalesce(sum(p.amount), 0) " + walletPostings +
+		" AND p.account = $1 AND t.operation = 'BONUS' AND t.reference = $2"
 
 	return c
 }
 
 func (r *balanceServiceRepo) AddIncomeRecord(ctx context.Context, username string, orderNumber string, income float64) error {
-	balance := newIncomeRecord(username, orderNumber, income)
+	return r.post(ctx, newLedgerTransaction(operationAccrual, orderNumber,
+		accountPosting(accountAccrual, income), walletPosting(username, income)))
+}
 
-	if balance.outcome != 0 {
-		return fmt.Errorf("income balance record can't have non zero outcome: %v", balance.outcome)
-	}
+func (r *balanceServiceRepo) AddWithdrawRecord(ctx context.Context, username string, orderNumber string, outcome float64) error {
+	return r.post(ctx, newLedgerTransaction(operationWithdrawal, orderNumber,
+		walletPosting(username, outcome), accountPosting(accountRedemption, outcome)))
+}
 
-	return r.addBalanceRecord(ctx, balance)
+func (r *balanceServiceRepo) AddRefundRecord(ctx context.Context, username string, orderNumber string, income float64) error {
+	return r.post(ctx, newLedgerTransaction(operationRefund, orderNumber,
+		accountPosting(accountRedemption, income), walletPosting(username, income)))
 }
 
-func (r *balanceServiceRepo) AddWithdrawRecord(ctx context.Context, username string, orderNumber string, outcome float64) error {
-	balance := newOutcomeRecord(username, orderNumber, outcome)
+func (r *balanceServiceRepo) AddExpiryRecord(ctx context.Context, username string, outcome float64) error {
+	return r.post(ctx, newLedgerTransaction(operationExpiry, "",
+		walletPosting(username, outcome), accountPosting(accountExpiry, outcome)))
+}
 
-	if balance.income != 0 {
-		return fmt.Errorf("outcome balance record can't have non zero income: %v", balance.outcome)
-	}
+func (r *balanceServiceRepo) AddTransferRecords(ctx context.Context, transfer *models.Transfer) error {
+	t := newLedgerTransaction(operationTransfer, "",
+		walletPosting(transfer.Sender, transfer.Sum), walletPosting(transfer.Recipient, transfer.Sum))
+	t.reference = transfer.ID
+	t.createdAt = transfer.CreatedAt
 
-	return r.addBalanceRecord(ctx, balance)
+	return r.post(ctx, t)
 }
 
-func (r *balanceServiceRepo) addBalanceRecord(ctx context.Context, record *balanceRecord) error {
-	_, err := r.storage.DB.ExecContext(ctx,
-		r.queries.addNewRecord, record.username, record.orderNumber, record.income, record.outcome, record.processedAt)
-	return err
+func (r *balanceServiceRepo) AddRedemptionRecord(ctx context.Context, username string, redemptionID string, outcome float64) error {
+	t := newLedgerTransaction(operationReward, "",
+		walletPosting(username, outcome), accountPosting(accountRedemption, outcome))
+	t.reference = redemptionID
+
+	return r.post(ctx, t)
+}
+
+func (r *balanceServiceRepo) AddPromoRecord(ctx context.Context, username string, code string, income float64) error {
+	t := newLedgerTransaction(operationPromo, "",
+		accountPosting(accountPromotions, income), walletPosting(username, income))
+	t.reference = code
+
+	return r.post(ctx, t)
+}
+
+func (r *balanceServiceRepo) AddBonusRecord(ctx context.Context, username string, orderNumber string,
+	campaignID string, bonus float64) error {
+	t := newLedgerTransaction(operationBonus, orderNumber,
+		accountPosting(accountCampaigns, bonus), walletPosting(username, bonus))
+	t.reference = campaignID
+
+	return r.post(ctx, t)
+}
+
+func (r *balanceServiceRepo) AddReferralRecord(ctx context.Context, username string, referee string, bonus float64) error {
+	t := newLedgerTransaction(operationReferral, "",
+		accountPosting(accountReferrals, bonus), walletPosting(username, bonus))
+	t.reference = referee
+
+	return r.post(ctx, t)
+}
+
+func (r *balanceServiceRepo) AddAdjustmentRecord(ctx context.Context, username string, amount float64, reason string) error {
+	t := newLedgerTransaction(operationAdjustment, "",
+		accountPosting(accountAdjustment, amount), walletPosting(username, amount))
+	t.reference = reason
+
+	return r.post(ctx, t)
+}
+
+// post writes the transaction to the ledger and updates stored balances of the affected users
+func (r *balanceServiceRepo) post(ctx context.Context, t *ledgerTransaction) error {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	if err := t.validate(); err != nil {
+		return err
+	}
+
+	orderNumber := sql.NullString{String: t.orderNumber, Valid: len(t.orderNumber) > 0}
+	reference := sql.NullString{String: t.reference, Valid: len(t.reference) > 0}
+
+	callback := func(ctx context.Context) error {
+		var id string
+
+		err := r.storage.Executor(ctx).QueryRowContext(ctx, r.queries.addTransaction,
+			t.operation, orderNumber, reference, t.createdAt).Scan(&id)
+		if err != nil {
+			return fmt.Errorf("failed to add ledger transaction: %w", err)
+		}
+
+		for _, p := range t.postings {
+			if len(p.username) > 0 {
+				_, err = r.storage.Executor(ctx).ExecContext(ctx, r.queries.addWallet, p.account, p.username)
+				if err != nil {
+					return fmt.Errorf("failed to add wallet of user '%v': %w", p.username, err)
+				}
+			}
+
+			_, err = r.storage.Executor(ctx).ExecContext(ctx, r.queries.addPosting, id, p.account, p.amount, t.createdAt)
+			if err != nil {
+				return fmt.Errorf("failed to add posting to account '%v': %w", p.account, err)
+			}
+
+			if len(p.username) == 0 {
+				continue
+			}
+
+			// The stored user balance must always match the ledger
+			_, err = r.storage.Executor(ctx).ExecContext(ctx, r.queries.updateUserBalance,
+				p.username, p.amount, t.withdrawn(p), t.createdAt)
+			if err != nil {
+				return fmt.Errorf("failed to update balance of user '%v': %w", p.username, err)
+			}
+		}
+
+		return nil
+	}
+
+	return r.storage.RunInTransaction(ctx, callback)
 }
 
 func (r *balanceServiceRepo) GetBanaceData(ctx context.Context, username string) (*models.Balance, error) {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
 	balance := models.Balance{}
 
-	row := r.storage.DB.QueryRowContext(ctx, r.queries.getBalanceData, username)
+	row := r.storage.Executor(ctx).QueryRowContext(ctx, r.queries.getBalanceData, username, time.Now())
 
-	var c, w sql.NullFloat64
-	err := row.Scan(&c, &w)
+	var c, w, h sql.NullFloat64
+	err := row.Scan(&c, &w, &h)
 	if err != nil && !errors.Is(err, sql.ErrNoRows) {
 		return &balance, err
 	}
 
-	balance.Current = c.Float64
+	balance.Held = h.Float64
+	balance.Current = c.Float64 - balance.Held
 	balance.Withdrawn = w.Float64
 
 	return &balance, nil
 }
 
-func (r *balanceServiceRepo) GetWithdrawals(ctx context.Context, username string) ([]models.Withdrawals, error) {
-	row, err := r.storage.DB.QueryContext(ctx, r.queries.getWithdrawals, username)
+func (r *balanceServiceRepo) LockUserBalance(ctx context.Context, username string) error {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	var locked string
+	err := r.storage.Executor(ctx).QueryRowContext(ctx, r.queries.lockUserBalance, username).Scan(&locked)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrUserNotFound
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to lock balance of user '%v': %w", username, err)
+	}
+
+	return nil
+}
+
+func (r *balanceServiceRepo) GetExpirablePoints(ctx context.Context, username string, cutoff time.Time) (float64, error) {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	var points sql.NullFloat64
+
+	err := r.storage.Executor(ctx).QueryRowContext(ctx, r.queries.getExpirablePoints,
+		cutoff, walletAccount(username)).Scan(&points)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+
+	// Sums of floats may leave insignificant remainders
+	expirable := math.Round(points.Float64*100) / 100
+	if expirable < 0 {
+		return 0, nil
+	}
+
+	return expirable, nil
+}
+
+func (r *balanceServiceRepo) GetUsersWithExpirablePoints(ctx context.Context, cutoff time.Time) ([]string, error) {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	row, err := r.storage.Executor(ctx).QueryContext(ctx, r.queries.getUsersWithExpirablePoints, cutoff)
+	if err != nil {
+		return nil, err
+	}
+
+	defer row.Close()
+
+	result := make([]string, 0)
+
+	for row.Next() {
+		var username string
+		if err := row.Scan(&username); err != nil {
+			return nil, fmt.Errorf("row scan error: %w", err)
+		}
+
+		result = append(result, username)
+	}
+
+	if err = row.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate all users: %w", err)
+	}
+
+	return result, nil
+}
+
+func (r *balanceServiceRepo) GetCampaignBonus(ctx context.Context, username string, campaignID string) (float64, error) {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	var bonus float64
+
+	err := r.storage.Executor(ctx).QueryRowContext(ctx, r.queries.getCampaignBonus,
+		walletAccount(username), campaignID).Scan(&bonus)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+
+	return bonus, nil
+}
 
+func (r *balanceServiceRepo) GetBalanceDrifts(ctx context.Context) ([]models.BalanceDrift, error) {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	row, err := r.storage.Executor(ctx).QueryContext(ctx, r.queries.getBalanceDrifts, balanceTolerance)
 	if err != nil {
 		return nil, err
 	}
 
 	defer row.Close()
 
-	result := make([]models.Withdrawals, 0)
+	result := make([]models.BalanceDrift, 0)
 
 	for row.Next() {
-		wd := models.Withdrawals{}
-		err := row.Scan(&wd.Order, &wd.Sum, &wd.ProcessedAt)
+		d := models.BalanceDrift{}
+		err := row.Scan(&d.Username, &d.Current, &d.LedgerCurrent, &d.Withdrawn, &d.LedgerWithdrawn)
+		if err != nil {
+			return nil, fmt.Errorf("row scan error: %w", err)
+		}
 
+		result = append(result, d)
+	}
+
+	if err = row.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate all balance drifts: %w", err)
+	}
+
+	return result, nil
+}
+
+func (r *balanceServiceRepo) GetLedgerViolations(ctx context.Context) ([]models.LedgerViolation, error) {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	row, err := r.storage.Executor(ctx).QueryContext(ctx, r.queries.getLedgerViolations, balanceTolerance)
+	if err != nil {
+		return nil, err
+	}
+
+	defer row.Close()
+
+	result := make([]models.LedgerViolation, 0)
+
+	for row.Next() {
+		v := models.LedgerViolation{}
+		err := row.Scan(&v.TransactionID, &v.Operation, &v.Postings, &v.Sum)
 		if err != nil {
 			return nil, fmt.Errorf("row scan error: %w", err)
 		}
 
-		result = append(result, wd)
+		result = append(result, v)
 	}
 
 	if err = row.Err(); err != nil {
-		return nil, fmt.Errorf("failed to iterate all withdrawals: %w", err)
+		return nil, fmt.Errorf("failed to iterate all ledger violations: %w", err)
 	}
 
 	return result, nil
 }
 
 func NewBalanceServiceRepo(storage *database.ServiceStorage) BalanceServiceRepo {
-	return &balanceServiceRepo{
+	r := balanceServiceRepo{
 		storage: storage,
 		queries: getBalanceQueries(),
 	}
+
+	if storage.Dialect() == database.DialectSQLite {
+		r.queries = getSQLiteBalanceQueries()
+	}
+
+	return &r
 }
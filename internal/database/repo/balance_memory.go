@@ -0,0 +1,322 @@
+package repo
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/fuzzy-toozy/gophermart/internal/models"
+)
+
+type memoryBalanceServiceRepo struct {
+	store *MemoryStore
+}
+
+func (r *memoryBalanceServiceRepo) AddIncomeRecord(ctx context.Context, username string, orderNumber string, income float64) error {
+	return r.post(ctx, newLedgerTransaction(operationAccrual, orderNumber,
+		accountPosting(accountAccrual, income), walletPosting(username, income)))
+}
+
+func (r *memoryBalanceServiceRepo) AddWithdrawRecord(ctx context.Context, username string, orderNumber string, outcome float64) error {
+	return r.post(ctx, newLedgerTransaction(operationWithdrawal, orderNumber,
+		walletPosting(username, outcome), accountPosting(accountRedemption, outcome)))
+}
+
+func (r *memoryBalanceServiceRepo) AddRefundRecord(ctx context.Context, username string, orderNumber string, income float64) error {
+	return r.post(ctx, newLedgerTransaction(operationRefund, orderNumber,
+		accountPosting(accountRedemption, income), walletPosting(username, income)))
+}
+
+func (r *memoryBalanceServiceRepo) AddExpiryRecord(ctx context.Context, username string, outcome float64) error {
+	return r.post(ctx, newLedgerTransaction(operationExpiry, "",
+		walletPosting(username, outcome), accountPosting(accountExpiry, outcome)))
+}
+
+func (r *memoryBalanceServiceRepo) AddTransferRecords(ctx context.Context, transfer *models.Transfer) error {
+	t := newLedgerTransaction(operationTransfer, "",
+		walletPosting(transfer.Sender, transfer.Sum), walletPosting(transfer.Recipient, transfer.Sum))
+	t.reference = transfer.ID
+	t.createdAt = transfer.CreatedAt
+
+	return r.post(ctx, t)
+}
+
+func (r *memoryBalanceServiceRepo) AddRedemptionRecord(ctx context.Context, username string, redemptionID string, outcome float64) error {
+	t := newLedgerTransaction(operationReward, "",
+		walletPosting(username, outcome), accountPosting(accountRedemption, outcome))
+	t.reference = redemptionID
+
+	return r.post(ctx, t)
+}
+
+func (r *memoryBalanceServiceRepo) AddPromoRecord(ctx context.Context, username string, code string, income float64) error {
+	t := newLedgerTransaction(operationPromo, "",
+		accountPosting(accountPromotions, income), walletPosting(username, income))
+	t.reference = code
+
+	return r.post(ctx, t)
+}
+
+func (r *memoryBalanceServiceRepo) AddBonusRecord(ctx context.Context, username string, orderNumber string,
+	campaignID string, bonus float64) error {
+	t := newLedgerTransaction(operationBonus, orderNumber,
+		accountPosting(accountCampaigns, bonus), walletPosting(username, bonus))
+	t.reference = campaignID
+
+	return r.post(ctx, t)
+}
+
+func (r *memoryBalanceServiceRepo) AddReferralRecord(ctx context.Context, username string, referee string, bonus float64) error {
+	t := newLedgerTransaction(operationReferral, "",
+		accountPosting(accountReferrals, bonus), walletPosting(username, bonus))
+	t.reference = referee
+
+	return r.post(ctx, t)
+}
+
+func (r *memoryBalanceServiceRepo) AddAdjustmentRecord(ctx context.Context, username string, amount float64, reason string) error {
+	t := newLedgerTransaction(operationAdjustment, "",
+		accountPosting(accountAdjustment, amount), walletPosting(username, amount))
+	t.reference = reason
+
+	return r.post(ctx, t)
+}
+
+// post writes the transaction to the ledger and updates stored balances of the affected users
+func (r *memoryBalanceServiceRepo) post(ctx context.Context, t *ledgerTransaction) error {
+	if err := t.validate(); err != nil {
+		return err
+	}
+
+	return r.store.run(ctx, func(ctx context.Context) error {
+		for _, p := range t.postings {
+			if len(p.username) == 0 {
+				continue
+			}
+
+			if err := r.store.userExists(p.username); err != nil {
+				return err
+			}
+		}
+
+		id, err := newMemoryID()
+		if err != nil {
+			return err
+		}
+
+		memoryAppend(ctx, r.store, &r.store.ledger, memoryLedgerTransaction{id: id, ledgerTransaction: *t})
+
+		for _, p := range t.postings {
+			if len(p.username) == 0 {
+				continue
+			}
+
+			// The stored user balance must always match the ledger
+			balance := r.store.balances[p.username]
+			balance.current += p.amount
+			balance.withdrawn += t.withdrawn(p)
+			memorySet(ctx, r.store, r.store.balances, p.username, balance)
+		}
+
+		return nil
+	})
+}
+
+func (r *memoryBalanceServiceRepo) GetBanaceData(ctx context.Context, username string) (*models.Balance, error) {
+	balance := models.Balance{}
+	now := time.Now()
+
+	err := r.store.run(ctx, func(ctx context.Context) error {
+		// Active holds are reserved and not available for spending
+		for _, hold := range r.store.holds {
+			if hold.Username == username && hold.Active(now) {
+				balance.Held += hold.Sum
+			}
+		}
+
+		stored := r.store.balances[username]
+		balance.Current = stored.current - balance.Held
+		balance.Withdrawn = stored.withdrawn
+
+		return nil
+	})
+
+	return &balance, err
+}
+
+func (r *memoryBalanceServiceRepo) LockUserBalance(ctx context.Context, username string) error {
+	return r.store.run(ctx, func(ctx context.Context) error {
+		// Transactions are serialized by the storage, so only the user is checked
+		if _, ok := r.store.users[username]; !ok {
+			return ErrUserNotFound
+		}
+
+		return nil
+	})
+}
+
+// expirablePoints sums wallet postings of every user the same way as the expirablePoints query
+func (r *memoryBalanceServiceRepo) expirablePoints(cutoff time.Time) map[string]float64 {
+	points := make(map[string]float64)
+
+	for _, t := range r.store.ledger {
+		for _, p := range t.postings {
+			if len(p.username) == 0 {
+				continue
+			}
+
+			// Refunds return points consumed by cancelled withdrawals
+			if p.amount < 0 || t.operation == operationRefund || !t.createdAt.After(cutoff) {
+				points[p.username] += p.amount
+			}
+		}
+	}
+
+	return points
+}
+
+func (r *memoryBalanceServiceRepo) GetExpirablePoints(ctx context.Context, username string, cutoff time.Time) (float64, error) {
+	var points float64
+
+	err := r.store.run(ctx, func(ctx context.Context) error {
+		points = r.expirablePoints(cutoff)[username]
+		return nil
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	// Sums of floats may leave insignificant remainders
+	expirable := math.Round(points*100) / 100
+	if expirable < 0 {
+		return 0, nil
+	}
+
+	return expirable, nil
+}
+
+func (r *memoryBalanceServiceRepo) GetUsersWithExpirablePoints(ctx context.Context, cutoff time.Time) ([]string, error) {
+	result := make([]string, 0)
+
+	err := r.store.run(ctx, func(ctx context.Context) error {
+		for username, points := range r.expirablePoints(cutoff) {
+			if points > 0 {
+				result = append(result, username)
+			}
+		}
+
+		return nil
+	})
+
+	sort.Strings(result)
+
+	return result, err
+}
+
+func (r *memoryBalanceServiceRepo) GetCampaignBonus(ctx context.Context, username string, campaignID string) (float64, error) {
+	var bonus float64
+
+	err := r.store.run(ctx, func(ctx context.Context) error {
+		for _, t := range r.store.ledger {
+			if t.operation != operationBonus || t.reference != campaignID {
+				continue
+			}
+
+			for _, p := range t.postings {
+				if p.username == username {
+					bonus += p.amount
+				}
+			}
+		}
+
+		return nil
+	})
+
+	return bonus, err
+}
+
+func (r *memoryBalanceServiceRepo) GetBalanceDrifts(ctx context.Context) ([]models.BalanceDrift, error) {
+	result := make([]models.BalanceDrift, 0)
+
+	err := r.store.run(ctx, func(ctx context.Context) error {
+		ledger := make(map[string]memoryBalance)
+
+		for _, t := range r.store.ledger {
+			for _, p := range t.postings {
+				if len(p.username) == 0 {
+					continue
+				}
+
+				balance := ledger[p.username]
+				balance.current += p.amount
+				balance.withdrawn += t.withdrawn(p)
+				ledger[p.username] = balance
+			}
+		}
+
+		usernames := make(map[string]struct{})
+		for username := range ledger {
+			usernames[username] = struct{}{}
+		}
+		for username := range r.store.balances {
+			usernames[username] = struct{}{}
+		}
+
+		for username := range usernames {
+			d := models.BalanceDrift{
+				Username:        username,
+				Current:         r.store.balances[username].current,
+				LedgerCurrent:   ledger[username].current,
+				Withdrawn:       r.store.balances[username].withdrawn,
+				LedgerWithdrawn: ledger[username].withdrawn,
+			}
+
+			if math.Abs(d.Current-d.LedgerCurrent) > balanceTolerance ||
+				math.Abs(d.Withdrawn-d.LedgerWithdrawn) > balanceTolerance {
+				result = append(result, d)
+			}
+		}
+
+		return nil
+	})
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Username < result[j].Username
+	})
+
+	return result, err
+}
+
+func (r *memoryBalanceServiceRepo) GetLedgerViolations(ctx context.Context) ([]models.LedgerViolation, error) {
+	result := make([]models.LedgerViolation, 0)
+
+	err := r.store.run(ctx, func(ctx context.Context) error {
+		for _, t := range r.store.ledger {
+			v := models.LedgerViolation{
+				TransactionID: t.id,
+				Operation:     t.operation,
+				Postings:      len(t.postings),
+			}
+
+			for _, p := range t.postings {
+				v.Sum += p.amount
+			}
+
+			if v.Postings < 2 || math.Abs(v.Sum) > balanceTolerance {
+				result = append(result, v)
+			}
+		}
+
+		return nil
+	})
+
+	return result, err
+}
+
+func NewMemoryBalanceServiceRepo(store *MemoryStore) BalanceServiceRepo {
+	return &memoryBalanceServiceRepo{
+		store: store,
+	}
+}
@@ -0,0 +1,10 @@
+package repo
+
+func getSQLiteBalanceQueries() balanceQueryConfig {
+	c := getBalanceQueries()
+
+	// Transactions hold the database write lock, so the row lock isn't needed
+	c.lockUserBalance = "SELECT username FROM users WHERE username = $1"
+
+	return c
+}
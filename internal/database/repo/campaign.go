@@ -0,0 +1,176 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fuzzy-toozy/gophermart/internal/database"
+	"github.com/fuzzy-toozy/gophermart/internal/models"
+)
+
+type CampaignServiceRepo interface {
+	GetCampaignByID(ctx context.Context, id string) (*models.Campaign, error)
+	GetAllCampaigns(ctx context.Context) ([]models.Campaign, error)
+	// GetActiveCampaigns returns campaigns which time window contains now
+	GetActiveCampaigns(ctx context.Context, now time.Time) ([]models.Campaign, error)
+
+	AddNewCampaign(ctx context.Context, campaign *models.Campaign) error
+
+	UpdateCampaign(ctx context.Context, campaign *models.Campaign) error
+
+	DeleteCampaign(ctx context.Context, id string) error
+}
+
+type campaignQueryConfig struct {
+	getCampaignByID    string
+	getAllCampaigns    string
+	getActiveCampaigns string
+	addNewCampaign     string
+	updateCampaign     string
+	deleteCampaign     string
+}
+
+type campaignServiceRepo struct {
+	storage *database.ServiceStorage
+	queries campaignQueryConfig
+}
+
+const campaignColumns = "id, name, starts_at, ends_at, multiplier, fixed_bonus, first_orders, user_cap, created_at, updated_at"
+
+func getCampaignQueries() campaignQueryConfig {
+	c := campaignQueryConfig{}
+
+	c.getCampaignByID = "SELECT " + campaignColumns + " FROM campaigns WHERE id = $1"
+
+	c.getAllCampaigns = "SELECT " + campaignColumns + " FROM campaigns ORDER BY starts_at DESC"
+
+	c.getActiveCampaigns = "SELECT " + campaignColumns + " FROM campaigns " +
+		"WHERE starts_at <= $1 AND ends_at > $1 ORDER BY starts_at"
+
+	c.addNewCampaign = "INSERT INTO campaigns(name, starts_at, ends_at, multiplier, fixed_bonus, first_orders, user_cap, " +
+		"created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id"
+
+	c.updateCampaign = "UPDATE campaigns SET name = $1, starts_at = $2, ends_at = $3, multiplier = $4, fixed_bonus = $5, " +
+		"first_orders = $6, user_cap = $7, updated_at = $8 WHERE id = $9 RETURNING created_at"
+
+	c.deleteCampaign = "DELETE FROM campaigns WHERE id = $1"
+
+	return c
+}
+
+func scanCampaign(row rowScanner, campaign *models.Campaign) error {
+	return row.Scan(&campaign.ID, &campaign.Name, &campaign.StartsAt, &campaign.EndsAt, &campaign.Multiplier,
+		&campaign.FixedBonus, &campaign.FirstOrders, &campaign.UserCap, &campaign.CreatedAt, &campaign.UpdatedAt)
+}
+
+func (r *campaignServiceRepo) GetCampaignByID(ctx context.Context, id string) (*models.Campaign, error) {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	campaign := models.Campaign{}
+
+	row := r.storage.Executor(ctx).QueryRowContext(ctx, r.queries.getCampaignByID, id)
+
+	err := scanCampaign(row, &campaign)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return &campaign, err
+	}
+
+	return &campaign, nil
+}
+
+func (r *campaignServiceRepo) getCampaigns(ctx context.Context, query string, args ...any) ([]models.Campaign, error) {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	row, err := r.storage.Executor(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer row.Close()
+
+	result := make([]models.Campaign, 0)
+
+	for row.Next() {
+		campaign := models.Campaign{}
+		err := scanCampaign(row, &campaign)
+		if err != nil {
+			return nil, fmt.Errorf("row scan error: %w", err)
+		}
+
+		result = append(result, campaign)
+	}
+
+	if err = row.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate all campaigns: %w", err)
+	}
+
+	return result, nil
+}
+
+func (r *campaignServiceRepo) GetAllCampaigns(ctx context.Context) ([]models.Campaign, error) {
+	return r.getCampaigns(ctx, r.queries.getAllCampaigns)
+}
+
+func (r *campaignServiceRepo) GetActiveCampaigns(ctx context.Context, now time.Time) ([]models.Campaign, error) {
+	return r.getCampaigns(ctx, r.queries.getActiveCampaigns, now)
+}
+
+func (r *campaignServiceRepo) AddNewCampaign(ctx context.Context, campaign *models.Campaign) error {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	row := r.storage.Executor(ctx).QueryRowContext(ctx, r.queries.addNewCampaign,
+		campaign.Name, campaign.StartsAt, campaign.EndsAt, campaign.Multiplier, campaign.FixedBonus,
+		campaign.FirstOrders, campaign.UserCap, campaign.CreatedAt, campaign.UpdatedAt)
+
+	return row.Scan(&campaign.ID)
+}
+
+func (r *campaignServiceRepo) UpdateCampaign(ctx context.Context, campaign *models.Campaign) error {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	row := r.storage.Executor(ctx).QueryRowContext(ctx, r.queries.updateCampaign,
+		campaign.Name, campaign.StartsAt, campaign.EndsAt, campaign.Multiplier, campaign.FixedBonus,
+		campaign.FirstOrders, campaign.UserCap, campaign.UpdatedAt, campaign.ID)
+
+	err := row.Scan(&campaign.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrCampaignNotFound
+	}
+
+	return err
+}
+
+func (r *campaignServiceRepo) DeleteCampaign(ctx context.Context, id string) error {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	res, err := r.storage.Executor(ctx).ExecContext(ctx, r.queries.deleteCampaign, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected < 1 {
+		return ErrCampaignNotFound
+	}
+
+	return nil
+}
+
+func NewCampaignServiceRepo(storage *database.ServiceStorage) CampaignServiceRepo {
+	return &campaignServiceRepo{
+		storage: storage,
+		queries: getCampaignQueries(),
+	}
+}
@@ -0,0 +1,114 @@
+package repo
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/fuzzy-toozy/gophermart/internal/models"
+)
+
+type memoryCampaignServiceRepo struct {
+	store *MemoryStore
+}
+
+func (r *memoryCampaignServiceRepo) GetCampaignByID(ctx context.Context, id string) (*models.Campaign, error) {
+	campaign := models.Campaign{}
+
+	err := r.store.run(ctx, func(ctx context.Context) error {
+		campaign = r.store.campaigns[id]
+		return nil
+	})
+
+	return &campaign, err
+}
+
+func (r *memoryCampaignServiceRepo) getCampaigns(ctx context.Context, match func(campaign *models.Campaign) bool) ([]models.Campaign, error) {
+	result := make([]models.Campaign, 0)
+
+	err := r.store.run(ctx, func(ctx context.Context) error {
+		for _, campaign := range r.store.campaigns {
+			if match(&campaign) {
+				result = append(result, campaign)
+			}
+		}
+
+		return nil
+	})
+
+	return result, err
+}
+
+func (r *memoryCampaignServiceRepo) GetAllCampaigns(ctx context.Context) ([]models.Campaign, error) {
+	result, err := r.getCampaigns(ctx, func(campaign *models.Campaign) bool {
+		return true
+	})
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].StartsAt.After(result[j].StartsAt)
+	})
+
+	return result, err
+}
+
+func (r *memoryCampaignServiceRepo) GetActiveCampaigns(ctx context.Context, now time.Time) ([]models.Campaign, error) {
+	result, err := r.getCampaigns(ctx, func(campaign *models.Campaign) bool {
+		return !campaign.StartsAt.After(now) && campaign.EndsAt.After(now)
+	})
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].StartsAt.Before(result[j].StartsAt)
+	})
+
+	return result, err
+}
+
+func (r *memoryCampaignServiceRepo) AddNewCampaign(ctx context.Context, campaign *models.Campaign) error {
+	return r.store.run(ctx, func(ctx context.Context) error {
+		id, err := newMemoryID()
+		if err != nil {
+			return err
+		}
+
+		stored := *campaign
+		stored.ID = id
+		memorySet(ctx, r.store, r.store.campaigns, id, stored)
+
+		campaign.ID = id
+
+		return nil
+	})
+}
+
+func (r *memoryCampaignServiceRepo) UpdateCampaign(ctx context.Context, campaign *models.Campaign) error {
+	return r.store.run(ctx, func(ctx context.Context) error {
+		stored, ok := r.store.campaigns[campaign.ID]
+		if !ok {
+			return ErrCampaignNotFound
+		}
+
+		updated := *campaign
+		updated.CreatedAt = stored.CreatedAt
+		memorySet(ctx, r.store, r.store.campaigns, campaign.ID, updated)
+
+		campaign.CreatedAt = stored.CreatedAt
+
+		return nil
+	})
+}
+
+func (r *memoryCampaignServiceRepo) DeleteCampaign(ctx context.Context, id string) error {
+	return r.store.run(ctx, func(ctx context.Context) error {
+		if !memoryDelete(ctx, r.store, r.store.campaigns, id) {
+			return ErrCampaignNotFound
+		}
+
+		return nil
+	})
+}
+
+func NewMemoryCampaignServiceRepo(store *MemoryStore) CampaignServiceRepo {
+	return &memoryCampaignServiceRepo{
+		store: store,
+	}
+}
@@ -0,0 +1,1123 @@
+package repo_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fuzzy-toozy/gophermart/internal/common"
+	"github.com/fuzzy-toozy/gophermart/internal/database/repo"
+	"github.com/fuzzy-toozy/gophermart/internal/models"
+)
+
+var checks = []check{
+	{name: "users", run: checkUsers},
+	{name: "orders", run: checkOrders},
+	{name: "accrual", run: checkAccrual},
+	{name: "withdrawals", run: checkWithdrawals},
+	{name: "withdrawal completion", run: checkWithdrawalCompletion},
+	{name: "holds", run: checkHolds},
+	{name: "transfers", run: checkTransfers},
+	{name: "adjustments", run: checkAdjustments},
+	{name: "expiry", run: checkExpiry},
+	{name: "promo codes", run: checkPromoCodes},
+	{name: "rewards", run: checkRewards},
+	{name: "referrals", run: checkReferrals},
+	{name: "tiers", run: checkTiers},
+	{name: "idempotency", run: checkIdempotency},
+	{name: "webhook outbox", run: checkWebhookOutbox},
+	{name: "outbox leases", run: checkOutboxLeases},
+	{name: "rollback", run: checkRollback},
+	{name: "ledger", run: checkLedger},
+}
+
+func (s *suite) newUser(ctx context.Context) (string, error) {
+	user := models.User{Username: s.username(), Password: "secret", ReferralCode: s.code()}
+
+	err := s.repos.User.AddUser(ctx, &user)
+	if err != nil {
+		return "", fmt.Errorf("failed to add user: %w", err)
+	}
+
+	return user.Username, nil
+}
+
+// newUserWithPoints adds a user with points accrued for a processed order
+func (s *suite) newUserWithPoints(ctx context.Context, points float64) (string, error) {
+	username, err := s.newUser(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = s.processNewOrder(ctx, username, points, repo.ReferralBonuses{})
+	if err != nil {
+		return "", err
+	}
+
+	return username, nil
+}
+
+// processNewOrder adds an order of the user and credits its accrual
+func (s *suite) processNewOrder(ctx context.Context, username string, points float64,
+	bonuses repo.ReferralBonuses) (repo.ReferralReward, error) {
+	order := models.NewOrder(username, s.orderNumber())
+
+	err := s.repos.Order.AddNewOrder(ctx, order)
+	if err != nil {
+		return repo.ReferralReward{}, fmt.Errorf("failed to add order: %w", err)
+	}
+
+	order.Status = models.OrderPROCESSED
+
+	reward, err := s.process.ProcessOrder(ctx, order, points, bonuses)
+	if err != nil {
+		return repo.ReferralReward{}, fmt.Errorf("failed to process order: %w", err)
+	}
+
+	return reward, nil
+}
+
+func (s *suite) expectBalance(ctx context.Context, username string, current float64, withdrawn float64) error {
+	balance, err := s.repos.Balance.GetBanaceData(ctx, username)
+	if err != nil {
+		return fmt.Errorf("failed to get balance: %w", err)
+	}
+
+	if err := expectSum(balance.Current, current, "current balance"); err != nil {
+		return err
+	}
+
+	return expectSum(balance.Withdrawn, withdrawn, "withdrawn balance")
+}
+
+func checkUsers(ctx context.Context, s *suite) error {
+	user := models.User{Username: s.username(), Password: "secret", ReferralCode: s.code()}
+
+	err := s.repos.User.AddUser(ctx, &user)
+	if err != nil {
+		return expectNoErr(err, "add user")
+	}
+
+	duplicate := user
+	err = s.repos.User.AddUser(ctx, &duplicate)
+	if err := expectErr(err, repo.ErrUserExists, "add duplicate user"); err != nil {
+		return err
+	}
+
+	sameCode := models.User{Username: s.username(), Password: "secret", ReferralCode: user.ReferralCode}
+	err = s.repos.User.AddUser(ctx, &sameCode)
+	if err := expectErr(err, repo.ErrReferralCodeExists, "add user with used referral code"); err != nil {
+		return err
+	}
+
+	stored, err := s.repos.User.GetUserByName(ctx, user.Username)
+	if err != nil {
+		return expectNoErr(err, "get user")
+	}
+
+	if stored.Username != user.Username || stored.Password != common.EncryptStringMD5(user.Password) {
+		return fmt.Errorf("stored user '%v' doesn't match added one", stored.Username)
+	}
+
+	byCode, err := s.repos.User.GetUserByReferralCode(ctx, user.ReferralCode)
+	if err != nil {
+		return expectNoErr(err, "get user by referral code")
+	}
+
+	if byCode.Username != user.Username {
+		return fmt.Errorf("expected user '%v' by referral code, got '%v'", user.Username, byCode.Username)
+	}
+
+	missing, err := s.repos.User.GetUserByName(ctx, s.username())
+	if err != nil {
+		return expectNoErr(err, "get missing user")
+	}
+
+	if len(missing.Username) > 0 {
+		return fmt.Errorf("expected no user, got '%v'", missing.Username)
+	}
+
+	referee := models.User{Username: s.username(), Password: "secret", ReferralCode: s.code(), Referrer: user.Username}
+
+	err = s.repos.User.AddUser(ctx, &referee)
+	if err != nil {
+		return expectNoErr(err, "add referee")
+	}
+
+	info, err := s.repos.User.GetReferralInfo(ctx, user.Username)
+	if err != nil {
+		return expectNoErr(err, "get referral info")
+	}
+
+	if info.Code != user.ReferralCode || info.Invited != 1 || info.Rewarded != 0 {
+		return fmt.Errorf("unexpected referral info %+v", *info)
+	}
+
+	return nil
+}
+
+func checkOrders(ctx context.Context, s *suite) error {
+	username, err := s.newUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	order := models.NewOrder(username, s.orderNumber())
+
+	err = s.repos.Order.AddNewOrder(ctx, order)
+	if err != nil {
+		return expectNoErr(err, "add order")
+	}
+
+	err = s.repos.Order.AddNewOrder(ctx, models.NewOrder(username, order.Number))
+	if err := expectErr(err, repo.ErrOrderNumberUsed, "add duplicate order"); err != nil {
+		return err
+	}
+
+	stored, err := s.repos.Order.GetOrderByNumber(ctx, order.Number)
+	if err != nil {
+		return expectNoErr(err, "get order")
+	}
+
+	if stored.Username != username || stored.Status != models.OrderNEW {
+		return fmt.Errorf("unexpected order %+v", *stored)
+	}
+
+	unprocessed, err := s.repos.Order.GetAllUnprocessedOrders(ctx)
+	if err != nil {
+		return expectNoErr(err, "get unprocessed orders")
+	}
+
+	found := false
+	for _, o := range unprocessed {
+		found = found || o.Number == order.Number
+	}
+
+	if !found {
+		return fmt.Errorf("new order '%v' is not among unprocessed orders", order.Number)
+	}
+
+	err = s.repos.Order.UpdateStatus(ctx, models.NewOrder(username, s.orderNumber()))
+	if err == nil {
+		return errors.New("expected error updating status of missing order")
+	}
+
+	return nil
+}
+
+func checkAccrual(ctx context.Context, s *suite) error {
+	username, err := s.newUserWithPoints(ctx, 100)
+	if err != nil {
+		return err
+	}
+
+	if err := s.expectBalance(ctx, username, 100, 0); err != nil {
+		return err
+	}
+
+	count, err := s.repos.Order.GetProcessedOrdersCount(ctx, username)
+	if err != nil {
+		return expectNoErr(err, "get processed orders count")
+	}
+
+	if count != 1 {
+		return fmt.Errorf("expected 1 processed order, got %v", count)
+	}
+
+	orders, err := s.repos.Order.GetAllUserOrders(ctx, username)
+	if err != nil {
+		return expectNoErr(err, "get user orders")
+	}
+
+	if len(orders) != 1 || orders[0].Status != models.OrderPROCESSED {
+		return fmt.Errorf("expected 1 processed order, got %+v", orders)
+	}
+
+	if err := expectSum(orders[0].Accrual, 100, "order accrual"); err != nil {
+		return err
+	}
+
+	// Another instance processing the same order must not credit it again
+	_, err = s.process.ProcessOrder(ctx, &orders[0], 100, repo.ReferralBonuses{})
+	if err := expectErr(err, repo.ErrOrderFinalized, "process processed order"); err != nil {
+		return err
+	}
+
+	invalid := orders[0]
+	invalid.Status = models.OrderINVALID
+
+	err = s.process.UpdateOrderStatus(ctx, &invalid)
+	if err := expectErr(err, repo.ErrOrderFinalized, "invalidate processed order"); err != nil {
+		return err
+	}
+
+	return s.expectBalance(ctx, username, 100, 0)
+}
+
+func checkWithdrawals(ctx context.Context, s *suite) error {
+	username, err := s.newUserWithPoints(ctx, 100)
+	if err != nil {
+		return err
+	}
+
+	number := s.orderNumber()
+
+	err = s.process.WithdrawBalance(ctx, &models.Withdraw{Order: number, Sum: 150}, username)
+	if err := expectErr(err, repo.ErrWithdrawUnavailable, "withdraw more than balance"); err != nil {
+		return err
+	}
+
+	err = s.process.WithdrawBalance(ctx, &models.Withdraw{Order: number, Sum: 40}, username)
+	if err != nil {
+		return expectNoErr(err, "withdraw")
+	}
+
+	if err := s.expectBalance(ctx, username, 60, 40); err != nil {
+		return err
+	}
+
+	err = s.process.WithdrawBalance(ctx, &models.Withdraw{Order: number, Sum: 10}, username)
+	if err := expectErr(err, repo.ErrWithdrawExists, "withdraw twice for the order"); err != nil {
+		return err
+	}
+
+	err = s.repos.Order.AddNewOrder(ctx, models.NewOrder(username, number))
+	if err := expectErr(err, repo.ErrOrderNumberUsed, "add order with withdrawal number"); err != nil {
+		return err
+	}
+
+	err = s.process.WithdrawBalance(ctx, &models.Withdraw{Order: s.orderNumber(), Sum: 10}, username,
+		repo.Limit{Since: time.Now().Add(-time.Hour), Max: 45})
+	if err := expectErr(err, repo.ErrLimitExceeded, "withdraw over limit"); err != nil {
+		return err
+	}
+
+	withdrawn, err := s.repos.Withdrawal.GetWithdrawnSum(ctx, username, time.Now().Add(-time.Hour))
+	if err != nil {
+		return expectNoErr(err, "get withdrawn sum")
+	}
+
+	if err := expectSum(withdrawn, 40, "withdrawn sum"); err != nil {
+		return err
+	}
+
+	withdrawals, err := s.repos.Withdrawal.GetAllUserWithdrawals(ctx, username)
+	if err != nil {
+		return expectNoErr(err, "get user withdrawals")
+	}
+
+	if len(withdrawals) != 1 || withdrawals[0].Status != models.WithdrawalPENDING {
+		return fmt.Errorf("expected 1 pending withdrawal, got %+v", withdrawals)
+	}
+
+	return s.expectBalance(ctx, username, 60, 40)
+}
+
+func checkWithdrawalCompletion(ctx context.Context, s *suite) error {
+	username, err := s.newUserWithPoints(ctx, 100)
+	if err != nil {
+		return err
+	}
+
+	cancelled := s.orderNumber()
+
+	err = s.process.WithdrawBalance(ctx, &models.Withdraw{Order: cancelled, Sum: 40}, username)
+	if err != nil {
+		return expectNoErr(err, "withdraw")
+	}
+
+	wd, err := s.process.CancelWithdrawal(ctx, cancelled)
+	if err != nil {
+		return expectNoErr(err, "cancel withdrawal")
+	}
+
+	if wd.Status != models.WithdrawalCANCELLED {
+		return fmt.Errorf("expected cancelled withdrawal, got '%v'", wd.Status)
+	}
+
+	if err := s.expectBalance(ctx, username, 100, 0); err != nil {
+		return err
+	}
+
+	_, err = s.process.CancelWithdrawal(ctx, cancelled)
+	if err := expectErr(err, repo.ErrWithdrawNotPending, "cancel withdrawal twice"); err != nil {
+		return err
+	}
+
+	_, err = s.process.CompleteWithdrawal(ctx, s.orderNumber())
+	if err := expectErr(err, repo.ErrWithdrawNotFound, "complete missing withdrawal"); err != nil {
+		return err
+	}
+
+	completed := s.orderNumber()
+
+	err = s.process.WithdrawBalance(ctx, &models.Withdraw{Order: completed, Sum: 10}, username)
+	if err != nil {
+		return expectNoErr(err, "withdraw")
+	}
+
+	_, err = s.process.CompleteWithdrawal(ctx, completed)
+	if err != nil {
+		return expectNoErr(err, "complete withdrawal")
+	}
+
+	return s.expectBalance(ctx, username, 90, 10)
+}
+
+func checkHolds(ctx context.Context, s *suite) error {
+	username, err := s.newUserWithPoints(ctx, 100)
+	if err != nil {
+		return err
+	}
+
+	err = s.process.HoldBalance(ctx, models.NewHold(username, 150, time.Hour))
+	if err := expectErr(err, repo.ErrWithdrawUnavailable, "hold more than balance"); err != nil {
+		return err
+	}
+
+	released := models.NewHold(username, 70, time.Hour)
+
+	err = s.process.HoldBalance(ctx, released)
+	if err != nil {
+		return expectNoErr(err, "hold")
+	}
+
+	if err := s.expectBalance(ctx, username, 30, 0); err != nil {
+		return err
+	}
+
+	err = s.process.WithdrawBalance(ctx, &models.Withdraw{Order: s.orderNumber(), Sum: 50}, username)
+	if err := expectErr(err, repo.ErrWithdrawUnavailable, "withdraw held points"); err != nil {
+		return err
+	}
+
+	other, err := s.newUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.process.ReleaseHold(ctx, released.ID, other)
+	if err := expectErr(err, repo.ErrHoldNotFound, "release hold of another user"); err != nil {
+		return err
+	}
+
+	_, err = s.process.ReleaseHold(ctx, released.ID, username)
+	if err != nil {
+		return expectNoErr(err, "release hold")
+	}
+
+	_, err = s.process.ReleaseHold(ctx, released.ID, username)
+	if err := expectErr(err, repo.ErrHoldNotActive, "release hold twice"); err != nil {
+		return err
+	}
+
+	captured := models.NewHold(username, 30, time.Hour)
+
+	err = s.process.HoldBalance(ctx, captured)
+	if err != nil {
+		return expectNoErr(err, "hold")
+	}
+
+	hold, err := s.process.CaptureHold(ctx, captured.ID, username, s.orderNumber())
+	if err != nil {
+		return expectNoErr(err, "capture hold")
+	}
+
+	if hold.Status != models.HoldCAPTURED {
+		return fmt.Errorf("expected captured hold, got '%v'", hold.Status)
+	}
+
+	holds, err := s.repos.Hold.GetAllUserHolds(ctx, username)
+	if err != nil {
+		return expectNoErr(err, "get user holds")
+	}
+
+	if len(holds) != 2 {
+		return fmt.Errorf("expected 2 holds, got %v", len(holds))
+	}
+
+	return s.expectBalance(ctx, username, 70, 30)
+}
+
+func checkTransfers(ctx context.Context, s *suite) error {
+	sender, err := s.newUserWithPoints(ctx, 100)
+	if err != nil {
+		return err
+	}
+
+	recipient, err := s.newUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = s.process.TransferBalance(ctx, models.NewTransfer(sender, recipient, 150))
+	if err := expectErr(err, repo.ErrWithdrawUnavailable, "transfer more than balance"); err != nil {
+		return err
+	}
+
+	transfer := models.NewTransfer(sender, recipient, 40)
+
+	err = s.process.TransferBalance(ctx, transfer)
+	if err != nil {
+		return expectNoErr(err, "transfer")
+	}
+
+	if len(transfer.ID) == 0 {
+		return errors.New("transfer id is not set")
+	}
+
+	if err := s.expectBalance(ctx, sender, 60, 0); err != nil {
+		return err
+	}
+
+	if err := s.expectBalance(ctx, recipient, 40, 0); err != nil {
+		return err
+	}
+
+	sent, err := s.repos.Transfer.GetSentSum(ctx, sender, time.Now().Add(-time.Hour))
+	if err != nil {
+		return expectNoErr(err, "get sent sum")
+	}
+
+	if err := expectSum(sent, 40, "sent sum"); err != nil {
+		return err
+	}
+
+	transfers, err := s.repos.Transfer.GetAllUserTransfers(ctx, recipient)
+	if err != nil {
+		return expectNoErr(err, "get user transfers")
+	}
+
+	if len(transfers) != 1 || transfers[0].Direction != models.TransferIN || transfers[0].ID != transfer.ID {
+		return fmt.Errorf("expected 1 incoming transfer, got %+v", transfers)
+	}
+
+	return nil
+}
+
+func checkAdjustments(ctx context.Context, s *suite) error {
+	username, err := s.newUserWithPoints(ctx, 100)
+	if err != nil {
+		return err
+	}
+
+	err = s.process.AdjustBalance(ctx, username, &models.Adjustment{Amount: 20, Reason: "goodwill"})
+	if err != nil {
+		return expectNoErr(err, "credit adjustment")
+	}
+
+	err = s.process.AdjustBalance(ctx, username, &models.Adjustment{Amount: -150, Reason: "correction"})
+	if err := expectErr(err, repo.ErrWithdrawUnavailable, "debit more than balance"); err != nil {
+		return err
+	}
+
+	err = s.process.AdjustBalance(ctx, username, &models.Adjustment{Amount: -50, Reason: "correction"})
+	if err != nil {
+		return expectNoErr(err, "debit adjustment")
+	}
+
+	err = s.process.AdjustBalance(ctx, s.username(), &models.Adjustment{Amount: 10, Reason: "missing"})
+	if err := expectErr(err, repo.ErrUserNotFound, "adjust balance of missing user"); err != nil {
+		return err
+	}
+
+	return s.expectBalance(ctx, username, 70, 0)
+}
+
+func checkExpiry(ctx context.Context, s *suite) error {
+	username, err := s.newUserWithPoints(ctx, 100)
+	if err != nil {
+		return err
+	}
+
+	err = s.process.WithdrawBalance(ctx, &models.Withdraw{Order: s.orderNumber(), Sum: 30}, username)
+	if err != nil {
+		return expectNoErr(err, "withdraw")
+	}
+
+	expirable, err := s.repos.Balance.GetExpirablePoints(ctx, username, time.Now().Add(-time.Hour))
+	if err != nil {
+		return expectNoErr(err, "get expirable points")
+	}
+
+	if err := expectSum(expirable, 0, "points expirable before accrual"); err != nil {
+		return err
+	}
+
+	cutoff := time.Now().Add(time.Minute)
+
+	users, err := s.process.GetUsersWithExpirablePoints(ctx, cutoff)
+	if err != nil {
+		return expectNoErr(err, "get users with expirable points")
+	}
+
+	found := false
+	for _, u := range users {
+		found = found || u == username
+	}
+
+	if !found {
+		return fmt.Errorf("user '%v' is not among users with expirable points", username)
+	}
+
+	hold := models.NewHold(username, 20, time.Hour)
+
+	err = s.process.HoldBalance(ctx, hold)
+	if err != nil {
+		return expectNoErr(err, "hold")
+	}
+
+	expired, err := s.process.ExpirePoints(ctx, username, cutoff)
+	if err != nil {
+		return expectNoErr(err, "expire points")
+	}
+
+	if err := expectSum(expired, 50, "expired points"); err != nil {
+		return err
+	}
+
+	if err := s.expectBalance(ctx, username, 0, 30); err != nil {
+		return err
+	}
+
+	// Held points are not expired, so the hold is captured
+	_, err = s.process.CaptureHold(ctx, hold.ID, username, s.orderNumber())
+	if err != nil {
+		return expectNoErr(err, "capture hold after expiry")
+	}
+
+	return s.expectBalance(ctx, username, 0, 50)
+}
+
+func checkPromoCodes(ctx context.Context, s *suite) error {
+	promo := models.PromoCode{Code: s.code(), Value: 25, MaxRedemptions: 2, CreatedAt: time.Now()}
+
+	err := s.repos.Promo.AddNewPromoCode(ctx, &promo)
+	if err != nil {
+		return expectNoErr(err, "add promo code")
+	}
+
+	err = s.repos.Promo.AddNewPromoCode(ctx, &promo)
+	if err := expectErr(err, repo.ErrPromoExists, "add duplicate promo code"); err != nil {
+		return err
+	}
+
+	// Batch with a duplicate code is not stored partially
+	batch := []models.PromoCode{{Code: s.code(), Value: 5, MaxRedemptions: 1, CreatedAt: time.Now()}, promo}
+
+	err = s.process.AddPromoCodes(ctx, batch)
+	if err := expectErr(err, repo.ErrPromoExists, "add promo codes batch with duplicate"); err != nil {
+		return err
+	}
+
+	stored, err := s.repos.Promo.GetPromoCode(ctx, batch[0].Code)
+	if err != nil {
+		return expectNoErr(err, "get promo code of failed batch")
+	}
+
+	if len(stored.Code) > 0 {
+		return fmt.Errorf("promo code '%v' of failed batch is stored", stored.Code)
+	}
+
+	users := make([]string, 3)
+	for i := range users {
+		if users[i], err = s.newUser(ctx); err != nil {
+			return err
+		}
+	}
+
+	_, err = s.process.RedeemPromoCode(ctx, promo.Code, users[0])
+	if err != nil {
+		return expectNoErr(err, "redeem promo code")
+	}
+
+	_, err = s.process.RedeemPromoCode(ctx, promo.Code, users[0])
+	if err := expectErr(err, repo.ErrPromoRedeemed, "redeem promo code twice"); err != nil {
+		return err
+	}
+
+	_, err = s.process.RedeemPromoCode(ctx, promo.Code, users[1])
+	if err != nil {
+		return expectNoErr(err, "redeem promo code")
+	}
+
+	_, err = s.process.RedeemPromoCode(ctx, promo.Code, users[2])
+	if err := expectErr(err, repo.ErrPromoExhausted, "redeem exhausted promo code"); err != nil {
+		return err
+	}
+
+	_, err = s.process.RedeemPromoCode(ctx, s.code(), users[2])
+	if err := expectErr(err, repo.ErrPromoNotFound, "redeem missing promo code"); err != nil {
+		return err
+	}
+
+	stored, err = s.repos.Promo.GetPromoCode(ctx, promo.Code)
+	if err != nil {
+		return expectNoErr(err, "get promo code")
+	}
+
+	// Failed redemptions must not be counted
+	if stored.Redemptions != 2 {
+		return fmt.Errorf("expected 2 redemptions, got %v", stored.Redemptions)
+	}
+
+	return s.expectBalance(ctx, users[0], 25, 0)
+}
+
+func checkRewards(ctx context.Context, s *suite) error {
+	now := time.Now()
+	reward := models.Reward{Name: "conformance", Cost: 30, Stock: 1, CreatedAt: now, UpdatedAt: now}
+
+	err := s.repos.Reward.AddNewReward(ctx, &reward)
+	if err != nil {
+		return expectNoErr(err, "add reward")
+	}
+
+	poor, err := s.newUserWithPoints(ctx, 10)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.process.RedeemReward(ctx, reward.ID, poor)
+	if err := expectErr(err, repo.ErrWithdrawUnavailable, "redeem reward costing more than balance"); err != nil {
+		return err
+	}
+
+	username, err := s.newUserWithPoints(ctx, 100)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.process.RedeemReward(ctx, reward.ID, username)
+	if err != nil {
+		return expectNoErr(err, "redeem reward")
+	}
+
+	if err := s.expectBalance(ctx, username, 70, 30); err != nil {
+		return err
+	}
+
+	_, err = s.process.RedeemReward(ctx, reward.ID, username)
+	if err := expectErr(err, repo.ErrRewardOutOfStock, "redeem reward out of stock"); err != nil {
+		return err
+	}
+
+	redemptions, err := s.repos.Reward.GetAllUserRedemptions(ctx, username)
+	if err != nil {
+		return expectNoErr(err, "get user redemptions")
+	}
+
+	if len(redemptions) != 1 || redemptions[0].RewardID != reward.ID {
+		return fmt.Errorf("expected 1 redemption of reward '%v', got %+v", reward.ID, redemptions)
+	}
+
+	err = s.repos.Reward.DeleteReward(ctx, reward.ID)
+	if err != nil {
+		return expectNoErr(err, "delete reward")
+	}
+
+	_, err = s.repos.Reward.TakeFromStock(ctx, reward.ID, now)
+	return expectErr(err, repo.ErrRewardNotFound, "take deleted reward from stock")
+}
+
+func checkReferrals(ctx context.Context, s *suite) error {
+	referrer, err := s.newUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	referee := models.User{Username: s.username(), Password: "secret", ReferralCode: s.code(), Referrer: referrer}
+
+	err = s.repos.User.AddUser(ctx, &referee)
+	if err != nil {
+		return expectNoErr(err, "add referee")
+	}
+
+	loop, err := s.repos.Referral.IsReferralLoop(ctx, referee.Username)
+	if err != nil {
+		return expectNoErr(err, "check referral loop")
+	}
+
+	if loop {
+		return errors.New("unexpected referral loop")
+	}
+
+	bonuses := repo.ReferralBonuses{Referee: 5, Referrer: 10}
+
+	reward, err := s.processNewOrder(ctx, referee.Username, 20, bonuses)
+	if err != nil {
+		return err
+	}
+
+	if reward.Referrer != referrer || reward.Fraud {
+		return fmt.Errorf("expected referrer '%v' rewarded, got %+v", referrer, reward)
+	}
+
+	reward, err = s.processNewOrder(ctx, referee.Username, 20, bonuses)
+	if err != nil {
+		return err
+	}
+
+	if len(reward.Referrer) > 0 {
+		return fmt.Errorf("referral is rewarded twice")
+	}
+
+	if err := s.expectBalance(ctx, referee.Username, 45, 0); err != nil {
+		return err
+	}
+
+	return s.expectBalance(ctx, referrer, 10, 0)
+}
+
+func checkTiers(ctx context.Context, s *suite) error {
+	username, err := s.newUserWithPoints(ctx, 100)
+	if err != nil {
+		return err
+	}
+
+	tier, err := s.repos.Tier.GetUserTier(ctx, username)
+	if err != nil {
+		return expectNoErr(err, "get user tier")
+	}
+
+	if tier.Tier != models.TierBRONZE {
+		return fmt.Errorf("expected tier '%v', got '%v'", models.TierBRONZE, tier.Tier)
+	}
+
+	if err := expectSum(tier.LifetimeAccrued, 100, "lifetime accrued"); err != nil {
+		return err
+	}
+
+	change := models.TierChange{Username: username, From: models.TierBRONZE, To: models.TierSILVER,
+		LifetimeAccrued: tier.LifetimeAccrued, ChangedAt: time.Now()}
+
+	err = s.repos.Tier.ChangeTier(ctx, &change)
+	if err != nil {
+		return expectNoErr(err, "change tier")
+	}
+
+	// The change made from the outdated tier is skipped
+	stale := change
+	stale.To = models.TierGOLD
+
+	err = s.repos.Tier.ChangeTier(ctx, &stale)
+	if err != nil {
+		return expectNoErr(err, "change outdated tier")
+	}
+
+	tier, err = s.repos.Tier.GetUserTier(ctx, username)
+	if err != nil {
+		return expectNoErr(err, "get user tier")
+	}
+
+	if tier.Tier != models.TierSILVER {
+		return fmt.Errorf("expected tier '%v', got '%v'", models.TierSILVER, tier.Tier)
+	}
+
+	return nil
+}
+
+func checkIdempotency(ctx context.Context, s *suite) error {
+	username, err := s.newUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	record := models.NewIdempotencyRecord(username, s.code(), "fingerprint", time.Hour)
+
+	claimed, err := s.repos.Idempotency.ClaimKey(ctx, record)
+	if err != nil || !claimed {
+		return fmt.Errorf("expected the key claimed, got %v, %v", claimed, err)
+	}
+
+	claimed, err = s.repos.Idempotency.ClaimKey(ctx, record)
+	if err != nil || claimed {
+		return fmt.Errorf("expected the claimed key not claimed twice, got %v, %v", claimed, err)
+	}
+
+	record.ResponseStatus = 200
+	record.ResponseBody = []byte("{}")
+	record.ContentType = "application/json"
+	record.ExpiresAt = time.Now().Add(time.Hour).Truncate(time.Millisecond)
+
+	err = s.repos.Idempotency.SaveResponse(ctx, record)
+	if err != nil {
+		return expectNoErr(err, "save response")
+	}
+
+	stored, err := s.repos.Idempotency.GetRecord(ctx, username, record.Key)
+	if err != nil {
+		return expectNoErr(err, "get record")
+	}
+
+	if !stored.Completed() || string(stored.ResponseBody) != "{}" || stored.Fingerprint != record.Fingerprint ||
+		!stored.ExpiresAt.Equal(record.ExpiresAt) {
+		return fmt.Errorf("unexpected record %+v", *stored)
+	}
+
+	err = s.repos.Idempotency.ReleaseKey(ctx, username, record.Key)
+	if err != nil {
+		return expectNoErr(err, "release key")
+	}
+
+	claimed, err = s.repos.Idempotency.ClaimKey(ctx, record)
+	if err != nil || !claimed {
+		return fmt.Errorf("expected the released key claimed, got %v, %v", claimed, err)
+	}
+
+	// Pending key with expired lease is taken over
+	expired := models.NewIdempotencyRecord(username, s.code(), "fingerprint", -time.Second)
+
+	claimed, err = s.repos.Idempotency.ClaimKey(ctx, expired)
+	if err != nil || !claimed {
+		return fmt.Errorf("expected the key claimed, got %v, %v", claimed, err)
+	}
+
+	retry := models.NewIdempotencyRecord(username, expired.Key, "fingerprint", time.Hour)
+
+	claimed, err = s.repos.Idempotency.ClaimKey(ctx, retry)
+	if err != nil || !claimed {
+		return fmt.Errorf("expected the key with expired lease claimed, got %v, %v", claimed, err)
+	}
+
+	// Lease extended by the running request isn't taken over
+	extended := models.NewIdempotencyRecord(username, s.code(), "fingerprint", -time.Second)
+
+	claimed, err = s.repos.Idempotency.ClaimKey(ctx, extended)
+	if err != nil || !claimed {
+		return fmt.Errorf("expected the key claimed, got %v, %v", claimed, err)
+	}
+
+	err = s.repos.Idempotency.ExtendLease(ctx, username, extended.Key, time.Now().Add(time.Hour))
+	if err != nil {
+		return expectNoErr(err, "extend lease")
+	}
+
+	retry = models.NewIdempotencyRecord(username, extended.Key, "fingerprint", time.Hour)
+
+	claimed, err = s.repos.Idempotency.ClaimKey(ctx, retry)
+	if err != nil || claimed {
+		return fmt.Errorf("expected the key with extended lease not claimed, got %v, %v", claimed, err)
+	}
+
+	// Completed record keeps its lifetime
+	extended.ResponseStatus = 200
+	extended.ExpiresAt = time.Now().Add(time.Hour).Truncate(time.Millisecond)
+
+	err = s.repos.Idempotency.SaveResponse(ctx, extended)
+	if err != nil {
+		return expectNoErr(err, "save response")
+	}
+
+	err = s.repos.Idempotency.ExtendLease(ctx, username, extended.Key, time.Now().Add(-time.Second))
+	if err != nil {
+		return expectNoErr(err, "extend lease")
+	}
+
+	stored, err = s.repos.Idempotency.GetRecord(ctx, username, extended.Key)
+	if err != nil {
+		return expectNoErr(err, "get record")
+	}
+
+	if !stored.Completed() || !stored.ExpiresAt.Equal(extended.ExpiresAt) {
+		return fmt.Errorf("expected the completed record not extended, got %+v", *stored)
+	}
+
+	return nil
+}
+
+// checkWebhookOutbox checks webhook deliveries are queued once for committed outbox events
+func checkWebhookOutbox(ctx context.Context, s *suite) error {
+	subscription := models.WebhookSubscription{URL: "http://localhost/hook", Secret: "secret",
+		Events: []string{models.EventWithdrawalCancelled}, CreatedAt: time.Now()}
+
+	err := s.repos.Webhook.AddNewSubscription(ctx, &subscription)
+	if err != nil {
+		return expectNoErr(err, "add subscription")
+	}
+
+	defer s.repos.Webhook.DeleteSubscription(ctx, subscription.ID)
+
+	username, err := s.newUserWithPoints(ctx, 100)
+	if err != nil {
+		return err
+	}
+
+	order := s.orderNumber()
+
+	err = s.process.WithdrawBalance(ctx, &models.Withdraw{Order: order, Sum: 10}, username)
+	if err != nil {
+		return expectNoErr(err, "withdraw")
+	}
+
+	_, err = s.process.CancelWithdrawal(ctx, order)
+	if err != nil {
+		return expectNoErr(err, "cancel withdrawal")
+	}
+
+	// Events are passed again after a failure, so they are queued twice
+	enqueue := func(ctx context.Context, event *models.Event) error {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+
+		for i := 0; i < 2; i++ {
+			if _, err := s.repos.Webhook.EnqueueEvent(ctx, event, payload); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	for {
+		now := time.Now()
+
+		n, err := s.repos.Outbox.PublishPending(ctx, now, now.Add(time.Minute), 100, enqueue)
+		if err != nil {
+			return expectNoErr(err, "publish pending outbox events")
+		}
+
+		if n == 0 {
+			break
+		}
+	}
+
+	now := time.Now()
+
+	deliveries, err := s.repos.Webhook.ClaimDueDeliveries(ctx, now, now.Add(time.Minute), 1000)
+	if err != nil {
+		return expectNoErr(err, "claim deliveries")
+	}
+
+	queued := 0
+	for _, d := range deliveries {
+		if d.SubscriptionID == subscription.ID && strings.Contains(string(d.Payload), order) {
+			queued++
+		}
+	}
+
+	if queued != 1 {
+		return fmt.Errorf("expected 1 delivery of cancelled withdrawal, got %v", queued)
+	}
+
+	return nil
+}
+
+// checkOutboxLeases checks failed events are released and leased events aren't passed to other relays
+func checkOutboxLeases(ctx context.Context, s *suite) error {
+	ok := func(ctx context.Context, event *models.Event) error { return nil }
+
+	publishAll := func(publish func(ctx context.Context, event *models.Event) error) (int, error) {
+		now := time.Now()
+		return s.repos.Outbox.PublishPending(ctx, now, now.Add(time.Minute), 100, publish)
+	}
+
+	if _, err := publishAll(ok); err != nil {
+		return expectNoErr(err, "publish events of previous checks")
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := s.repos.Outbox.AddEvent(ctx, "conformance", []byte("{}"), time.Now()); err != nil {
+			return expectNoErr(err, "add event")
+		}
+	}
+
+	errSink := errors.New("sink failed")
+	n, err := publishAll(func(ctx context.Context, event *models.Event) error { return errSink })
+	if err := expectErr(err, errSink, "publish to failing sink"); err != nil {
+		return err
+	}
+
+	if n != 0 {
+		return fmt.Errorf("expected no events published to failing sink, got %v", n)
+	}
+
+	// Failed events are released, so they are passed again without waiting for the lease
+	concurrent := -1
+	n, err = publishAll(func(ctx context.Context, event *models.Event) error {
+		if concurrent < 0 {
+			concurrent, err = publishAll(ok)
+		}
+
+		return err
+	})
+	if err != nil {
+		return expectNoErr(err, "publish released events")
+	}
+
+	if n != 2 {
+		return fmt.Errorf("expected 2 released events published, got %v", n)
+	}
+
+	if concurrent != 0 {
+		return fmt.Errorf("expected leased events not passed to concurrent relay, got %v", concurrent)
+	}
+
+	return nil
+}
+
+var errRollback = errors.New("rollback")
+
+func checkRollback(ctx context.Context, s *suite) error {
+	username, err := s.newUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	order := models.NewOrder(username, s.orderNumber())
+
+	err = s.repos.Storage.RunInTransaction(ctx, func(ctx context.Context) error {
+		if err := s.repos.Order.AddNewOrder(ctx, order); err != nil {
+			return err
+		}
+
+		if err := s.repos.Balance.AddIncomeRecord(ctx, username, order.Number, 50); err != nil {
+			return err
+		}
+
+		return errRollback
+	})
+	if err := expectErr(err, errRollback, "run failing transaction"); err != nil {
+		return err
+	}
+
+	stored, err := s.repos.Order.GetOrderByNumber(ctx, order.Number)
+	if err != nil {
+		return expectNoErr(err, "get order")
+	}
+
+	if len(stored.Number) > 0 {
+		return fmt.Errorf("order '%v' of rolled back transaction exists", order.Number)
+	}
+
+	return s.expectBalance(ctx, username, 0, 0)
+}
+
+func checkLedger(ctx context.Context, s *suite) error {
+	violations, err := s.repos.Balance.GetLedgerViolations(ctx)
+	if err != nil {
+		return expectNoErr(err, "get ledger violations")
+	}
+
+	if len(violations) > 0 {
+		return fmt.Errorf("found %v unbalanced ledger transactions", len(violations))
+	}
+
+	drifts, err := s.repos.Balance.GetBalanceDrifts(ctx)
+	if err != nil {
+		return expectNoErr(err, "get balance drifts")
+	}
+
+	for _, d := range drifts {
+		if strings.HasPrefix(d.Username, "conformance-"+s.prefix) {
+			return fmt.Errorf("balance of user '%v' drifted from ledger: %+v", d.Username, d)
+		}
+	}
+
+	return nil
+}
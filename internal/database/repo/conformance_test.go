@@ -0,0 +1,145 @@
+package repo_test
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fuzzy-toozy/gophermart/internal/database"
+	"github.com/fuzzy-toozy/gophermart/internal/database/repo"
+	"go.uber.org/zap/zaptest"
+)
+
+// testDatabaseURIEnv is the Postgres database the conformance checks are run against.
+// Checks use unique names, so the database may be in use, but it is migrated.
+const testDatabaseURIEnv = "TEST_DATABASE_URI"
+
+func TestMemoryConformance(t *testing.T) {
+	testConformance(t, openRepositories(t, database.MemoryURIScheme))
+}
+
+func TestSQLiteConformance(t *testing.T) {
+	uri := database.SQLiteURIScheme + filepath.Join(t.TempDir(), "gophermart.db")
+	testConformance(t, openRepositories(t, uri))
+}
+
+func TestPostgresConformance(t *testing.T) {
+	uri := os.Getenv(testDatabaseURIEnv)
+	if len(uri) == 0 {
+		t.Skipf("%v is not set", testDatabaseURIEnv)
+	}
+
+	testConformance(t, openRepositories(t, uri))
+}
+
+func openRepositories(t *testing.T, uri string) *repo.Repositories {
+	t.Helper()
+
+	repos, err := repo.OpenRepositories(context.Background(), database.DBConfig{ConnURI: uri, DriverName: "pgx"},
+		true, zaptest.NewLogger(t).Sugar())
+	if err != nil {
+		t.Fatalf("Failed to open repositories: %v", err)
+	}
+
+	t.Cleanup(func() {
+		repos.Storage.Close()
+	})
+
+	return repos
+}
+
+type check struct {
+	name string
+	run  func(ctx context.Context, s *suite) error
+}
+
+type suite struct {
+	repos   *repo.Repositories
+	process repo.ProcessServiceRepo
+	// Names and numbers of the run are unique, so checks can run against a database in use
+	prefix string
+	seq    int
+}
+
+func (s *suite) next() int {
+	s.seq++
+	return s.seq
+}
+
+func (s *suite) username() string {
+	return fmt.Sprintf("conformance-%v-%v", s.prefix, s.next())
+}
+
+func (s *suite) orderNumber() string {
+	return fmt.Sprintf("%v%04d", s.prefix, s.next())
+}
+
+func (s *suite) code() string {
+	return fmt.Sprintf("CONFORMANCE%v%v", s.prefix, s.next())
+}
+
+func newPrefix() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1e12))
+	if err != nil {
+		return "", fmt.Errorf("failed to generate run prefix: %w", err)
+	}
+
+	return fmt.Sprintf("%012d", n), nil
+}
+
+// testConformance checks that the backend of the repositories implements them with
+// the same semantics as the others. Every backend must pass all checks.
+func testConformance(t *testing.T, repos *repo.Repositories) {
+	prefix, err := newPrefix()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &suite{
+		repos: repos,
+		process: repo.NewProcessRepo(repos.Storage, repos.Balance, repos.Order, repos.Withdrawal, repos.Hold,
+			repos.Transfer, repos.Campaign, repos.Referral, repos.Reward, repos.Promo, repos.Outbox, nil),
+		prefix: prefix,
+	}
+
+	ctx := context.Background()
+
+	// Checks depend on the data of the previous ones, so they run in order
+	for _, c := range checks {
+		t.Run(c.name, func(t *testing.T) {
+			if err := c.run(ctx, s); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func expectErr(err error, target error, action string) error {
+	if !errors.Is(err, target) {
+		return fmt.Errorf("%v: expected error '%v', got '%v'", action, target, err)
+	}
+
+	return nil
+}
+
+func expectNoErr(err error, action string) error {
+	if err != nil {
+		return fmt.Errorf("%v: %w", action, err)
+	}
+
+	return nil
+}
+
+func expectSum(got float64, want float64, what string) error {
+	if math.Abs(got-want) > 0.001 {
+		return fmt.Errorf("expected %v to be %v, got %v", what, want, got)
+	}
+
+	return nil
+}
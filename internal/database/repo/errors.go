@@ -3,5 +3,26 @@ package repo
 import "errors"
 
 var (
-	ErrWithdrawUnavailable = errors.New("not enough funds")
+	ErrWithdrawUnavailable  = errors.New("not enough funds")
+	ErrWithdrawExists       = errors.New("withdrawal with this order number already exists")
+	ErrOrderNumberUsed      = errors.New("order number is already used")
+	ErrOrderFinalized       = errors.New("order is already processed")
+	ErrWithdrawNotFound     = errors.New("withdrawal not found")
+	ErrWithdrawNotPending   = errors.New("withdrawal is not pending")
+	ErrHoldNotFound         = errors.New("hold not found")
+	ErrHoldNotActive        = errors.New("hold is not active")
+	ErrUserNotFound         = errors.New("user not found")
+	ErrUserExists           = errors.New("user already exists")
+	ErrReferralCodeExists   = errors.New("referral code is already used")
+	ErrLimitExceeded        = errors.New("limit exceeded")
+	ErrCampaignNotFound     = errors.New("campaign not found")
+	ErrReferralFraud        = errors.New("referral is self-referral or referral loop")
+	ErrRewardNotFound       = errors.New("reward not found")
+	ErrRewardOutOfStock     = errors.New("reward is out of stock")
+	ErrPromoNotFound        = errors.New("promo code not found")
+	ErrPromoExpired         = errors.New("promo code is expired")
+	ErrPromoExhausted       = errors.New("promo code redemptions are exhausted")
+	ErrPromoRedeemed        = errors.New("promo code is already redeemed by the user")
+	ErrPromoExists          = errors.New("promo code already exists")
+	ErrSubscriptionNotFound = errors.New("webhook subscription not found")
 )
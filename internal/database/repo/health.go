@@ -0,0 +1,43 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/fuzzy-toozy/gophermart/internal/database"
+	"github.com/fuzzy-toozy/gophermart/internal/models"
+)
+
+type HealthServiceRepo interface {
+	Ping(ctx context.Context) error
+	GetPoolStats() *models.PoolStats
+}
+
+type healthServiceRepo struct {
+	storage *database.ServiceStorage
+}
+
+func (r *healthServiceRepo) Ping(ctx context.Context) error {
+	return r.storage.Ping(ctx)
+}
+
+func (r *healthServiceRepo) GetPoolStats() *models.PoolStats {
+	stats := r.storage.Stats()
+
+	return &models.PoolStats{
+		MaxOpenConnections: stats.MaxOpenConnections,
+		OpenConnections:    stats.OpenConnections,
+		InUse:              stats.InUse,
+		Idle:               stats.Idle,
+		WaitCount:          stats.WaitCount,
+		WaitDurationMs:     stats.WaitDuration.Milliseconds(),
+		MaxIdleClosed:      stats.MaxIdleClosed,
+		MaxIdleTimeClosed:  stats.MaxIdleTimeClosed,
+		MaxLifetimeClosed:  stats.MaxLifetimeClosed,
+	}
+}
+
+func NewHealthServiceRepo(storage *database.ServiceStorage) HealthServiceRepo {
+	return &healthServiceRepo{
+		storage: storage,
+	}
+}
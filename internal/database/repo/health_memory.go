@@ -0,0 +1,26 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/fuzzy-toozy/gophermart/internal/models"
+)
+
+type memoryHealthServiceRepo struct {
+	store *MemoryStore
+}
+
+func (r *memoryHealthServiceRepo) Ping(ctx context.Context) error {
+	return r.store.storage.Ping(ctx)
+}
+
+// GetPoolStats returns zero stats, because the in-memory storage has no connections
+func (r *memoryHealthServiceRepo) GetPoolStats() *models.PoolStats {
+	return &models.PoolStats{}
+}
+
+func NewMemoryHealthServiceRepo(store *MemoryStore) HealthServiceRepo {
+	return &memoryHealthServiceRepo{
+		store: store,
+	}
+}
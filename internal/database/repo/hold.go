@@ -0,0 +1,168 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fuzzy-toozy/gophermart/internal/database"
+	"github.com/fuzzy-toozy/gophermart/internal/models"
+)
+
+type HoldServiceRepo interface {
+	GetHoldByID(ctx context.Context, id string) (*models.Hold, error)
+	GetAllUserHolds(ctx context.Context, username string) ([]models.Hold, error)
+
+	AddNewHold(ctx context.Context, hold *models.Hold) error
+
+	UpdateHold(ctx context.Context, hold *models.Hold) error
+	// ExpireHolds marks all active holds expired at the moment as EXPIRED
+	ExpireHolds(ctx context.Context, now time.Time) (int64, error)
+}
+
+type holdQueryConfig struct {
+	getHoldByID     string
+	getAllUserHolds string
+	addNewHold      string
+	updateHold      string
+	expireHolds     string
+}
+
+type holdServiceRepo struct {
+	storage *database.ServiceStorage
+	queries holdQueryConfig
+}
+
+func getHoldQueries() holdQueryConfig {
+	c := holdQueryConfig{}
+
+	c.getHoldByID = "SELECT id, username, sum, status, order_number, created_at, expires_at, updated_at " +
+		"FROM holds WHERE id = $1"
+
+	c.getAllUserHolds = "SELECT id, username, sum, status, order_number, created_at, expires_at, updated_at " +
+		"FROM holds WHERE username = $1 ORDER BY created_at DESC"
+
+	c.addNewHold = "INSERT INTO holds(username, sum, status, created_at, expires_at, updated_at) " +
+		"VALUES ($1, $2, $3, $4, $5, $6) RETURNING id"
+
+	c.updateHold = "UPDATE holds SET status = $1, order_number = $2, updated_at = $3 WHERE id = $4"
+
+	c.expireHolds = "UPDATE holds SET status = 'EXPIRED', updated_at = $1 WHERE status = 'ACTIVE' AND expires_at <= $1"
+
+	return c
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanHold(row rowScanner, hold *models.Hold) error {
+	var order sql.NullString
+	err := row.Scan(&hold.ID, &hold.Username, &hold.Sum, &hold.Status, &order,
+		&hold.CreatedAt, &hold.ExpiresAt, &hold.UpdatedAt)
+	hold.Order = order.String
+	return err
+}
+
+func (r *holdServiceRepo) GetHoldByID(ctx context.Context, id string) (*models.Hold, error) {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	hold := models.Hold{}
+
+	row := r.storage.Executor(ctx).QueryRowContext(ctx, r.queries.getHoldByID, id)
+
+	err := scanHold(row, &hold)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return &hold, err
+	}
+
+	return &hold, nil
+}
+
+func (r *holdServiceRepo) GetAllUserHolds(ctx context.Context, username string) ([]models.Hold, error) {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	row, err := r.storage.Executor(ctx).QueryContext(ctx, r.queries.getAllUserHolds, username)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer row.Close()
+
+	result := make([]models.Hold, 0)
+
+	for row.Next() {
+		hold := models.Hold{}
+		err := scanHold(row, &hold)
+
+		if err != nil {
+			return nil, fmt.Errorf("row scan error: %w", err)
+		}
+
+		result = append(result, hold)
+	}
+
+	if err = row.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate all holds: %w", err)
+	}
+
+	return result, nil
+}
+
+func (r *holdServiceRepo) AddNewHold(ctx context.Context, hold *models.Hold) error {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	row := r.storage.Executor(ctx).QueryRowContext(ctx, r.queries.addNewHold,
+		hold.Username, hold.Sum, hold.Status, hold.CreatedAt, hold.ExpiresAt, hold.UpdatedAt)
+
+	return row.Scan(&hold.ID)
+}
+
+func (r *holdServiceRepo) UpdateHold(ctx context.Context, hold *models.Hold) error {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	order := sql.NullString{String: hold.Order, Valid: len(hold.Order) > 0}
+
+	res, err := r.storage.Executor(ctx).ExecContext(ctx, r.queries.updateHold,
+		hold.Status, order, hold.UpdatedAt, hold.ID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected < 1 {
+		return ErrHoldNotFound
+	}
+
+	return nil
+}
+
+func (r *holdServiceRepo) ExpireHolds(ctx context.Context, now time.Time) (int64, error) {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	res, err := r.storage.Executor(ctx).ExecContext(ctx, r.queries.expireHolds, now)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+func NewHoldServiceRepo(storage *database.ServiceStorage) HoldServiceRepo {
+	return &holdServiceRepo{
+		storage: storage,
+		queries: getHoldQueries(),
+	}
+}
@@ -0,0 +1,110 @@
+package repo
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/fuzzy-toozy/gophermart/internal/models"
+)
+
+type memoryHoldServiceRepo struct {
+	store *MemoryStore
+}
+
+func (r *memoryHoldServiceRepo) GetHoldByID(ctx context.Context, id string) (*models.Hold, error) {
+	hold := models.Hold{}
+
+	err := r.store.run(ctx, func(ctx context.Context) error {
+		hold = r.store.holds[id]
+		return nil
+	})
+
+	return &hold, err
+}
+
+func (r *memoryHoldServiceRepo) GetAllUserHolds(ctx context.Context, username string) ([]models.Hold, error) {
+	result := make([]models.Hold, 0)
+
+	err := r.store.run(ctx, func(ctx context.Context) error {
+		for _, hold := range r.store.holds {
+			if hold.Username == username {
+				result = append(result, hold)
+			}
+		}
+
+		return nil
+	})
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.After(result[j].CreatedAt)
+	})
+
+	return result, err
+}
+
+func (r *memoryHoldServiceRepo) AddNewHold(ctx context.Context, hold *models.Hold) error {
+	return r.store.run(ctx, func(ctx context.Context) error {
+		if err := r.store.userExists(hold.Username); err != nil {
+			return err
+		}
+
+		id, err := newMemoryID()
+		if err != nil {
+			return err
+		}
+
+		stored := *hold
+		stored.ID = id
+		stored.Order = ""
+		memorySet(ctx, r.store, r.store.holds, id, stored)
+
+		hold.ID = id
+
+		return nil
+	})
+}
+
+func (r *memoryHoldServiceRepo) UpdateHold(ctx context.Context, hold *models.Hold) error {
+	return r.store.run(ctx, func(ctx context.Context) error {
+		stored, ok := r.store.holds[hold.ID]
+		if !ok {
+			return ErrHoldNotFound
+		}
+
+		stored.Status = hold.Status
+		stored.Order = hold.Order
+		stored.UpdatedAt = hold.UpdatedAt
+		memorySet(ctx, r.store, r.store.holds, hold.ID, stored)
+
+		return nil
+	})
+}
+
+func (r *memoryHoldServiceRepo) ExpireHolds(ctx context.Context, now time.Time) (int64, error) {
+	var expired int64
+
+	err := r.store.run(ctx, func(ctx context.Context) error {
+		for id, hold := range r.store.holds {
+			if hold.Status != models.HoldACTIVE || hold.ExpiresAt.After(now) {
+				continue
+			}
+
+			hold.Status = models.HoldEXPIRED
+			hold.UpdatedAt = now
+			memorySet(ctx, r.store, r.store.holds, id, hold)
+
+			expired++
+		}
+
+		return nil
+	})
+
+	return expired, err
+}
+
+func NewMemoryHoldServiceRepo(store *MemoryStore) HoldServiceRepo {
+	return &memoryHoldServiceRepo{
+		store: store,
+	}
+}
@@ -0,0 +1,132 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/fuzzy-toozy/gophermart/internal/database"
+	"github.com/fuzzy-toozy/gophermart/internal/models"
+)
+
+type IdempotencyServiceRepo interface {
+	// ClaimKey stores a new pending record for the key. It returns false if
+	// a non expired record with the same key already exists.
+	ClaimKey(ctx context.Context, record *models.IdempotencyRecord) (bool, error)
+	GetRecord(ctx context.Context, username string, key string) (*models.IdempotencyRecord, error)
+	// SaveResponse completes the pending record, it is kept until the record ExpiresAt
+	SaveResponse(ctx context.Context, record *models.IdempotencyRecord) error
+	// ExtendLease moves ExpiresAt of the pending record, completed records are left as is
+	ExtendLease(ctx context.Context, username string, key string, expiresAt time.Time) error
+	ReleaseKey(ctx context.Context, username string, key string) error
+}
+
+type idempotencyQueryConfig struct {
+	claimKey     string
+	getRecord    string
+	saveResponse string
+	extendLease  string
+	releaseKey   string
+}
+
+type idempotencyServiceRepo struct {
+	storage *database.ServiceStorage
+	queries idempotencyQueryConfig
+}
+
+func getIdempotencyQueries() idempotencyQueryConfig {
+	c := idempotencyQueryConfig{}
+
+	// Expired records are taken over by the new request
+	c.claimKey = "INSERT INTO idempotency_keys(username, idempotency_key, fingerprint, created_at, expires_at) " +
+		"VALUES ($1, $2, $3, $4, $5) ON CONFLICT (username, idempotency_key) DO UPDATE " +
+		"SET fingerprint = EXCLUDED.fingerprint, created_at = EXCLUDED.created_at, expires_at = EXCLUDED.expires_at, " +
+		"response_status = NULL, response_body = NULL, content_type = NULL " +
+		"WHERE idempotency_keys.expires_at < EXCLUDED.created_at"
+
+	c.getRecord = "SELECT username, idempotency_key, fingerprint, response_status, response_body, content_type, " +
+		"created_at, expires_at FROM idempotency_keys WHERE username = $1 AND idempotency_key = $2"
+
+	c.saveResponse = "UPDATE idempotency_keys SET response_status = $1, response_body = $2, content_type = $3, " +
+		"expires_at = $4 WHERE username = $5 AND idempotency_key = $6"
+
+	c.extendLease = "UPDATE idempotency_keys SET expires_at = $1 " +
+		"WHERE username = $2 AND idempotency_key = $3 AND response_status IS NULL"
+
+	c.releaseKey = "DELETE FROM idempotency_keys WHERE username = $1 AND idempotency_key = $2"
+
+	return c
+}
+
+func (r *idempotencyServiceRepo) ClaimKey(ctx context.Context, record *models.IdempotencyRecord) (bool, error) {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	res, err := r.storage.Executor(ctx).ExecContext(ctx, r.queries.claimKey,
+		record.Username, record.Key, record.Fingerprint, record.CreatedAt, record.ExpiresAt)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected > 0, nil
+}
+
+func (r *idempotencyServiceRepo) GetRecord(ctx context.Context, username string, key string) (*models.IdempotencyRecord, error) {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	record := models.IdempotencyRecord{}
+
+	row := r.storage.Executor(ctx).QueryRowContext(ctx, r.queries.getRecord, username, key)
+
+	var status sql.NullInt64
+	var contentType sql.NullString
+	err := row.Scan(&record.Username, &record.Key, &record.Fingerprint, &status, &record.ResponseBody,
+		&contentType, &record.CreatedAt, &record.ExpiresAt)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return &record, err
+	}
+
+	record.ResponseStatus = int(status.Int64)
+	record.ContentType = contentType.String
+
+	return &record, nil
+}
+
+func (r *idempotencyServiceRepo) SaveResponse(ctx context.Context, record *models.IdempotencyRecord) error {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	_, err := r.storage.Executor(ctx).ExecContext(ctx, r.queries.saveResponse,
+		record.ResponseStatus, record.ResponseBody, record.ContentType, record.ExpiresAt, record.Username, record.Key)
+	return err
+}
+
+func (r *idempotencyServiceRepo) ExtendLease(ctx context.Context, username string, key string, expiresAt time.Time) error {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	_, err := r.storage.Executor(ctx).ExecContext(ctx, r.queries.extendLease, expiresAt, username, key)
+	return err
+}
+
+func (r *idempotencyServiceRepo) ReleaseKey(ctx context.Context, username string, key string) error {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	_, err := r.storage.Executor(ctx).ExecContext(ctx, r.queries.releaseKey, username, key)
+	return err
+}
+
+func NewIdempotencyServiceRepo(storage *database.ServiceStorage) IdempotencyServiceRepo {
+	return &idempotencyServiceRepo{
+		storage: storage,
+		queries: getIdempotencyQueries(),
+	}
+}
@@ -0,0 +1,103 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/fuzzy-toozy/gophermart/internal/models"
+)
+
+type memoryIdempotencyServiceRepo struct {
+	store *MemoryStore
+}
+
+func (r *memoryIdempotencyServiceRepo) ClaimKey(ctx context.Context, record *models.IdempotencyRecord) (bool, error) {
+	claimed := false
+
+	err := r.store.run(ctx, func(ctx context.Context) error {
+		if err := r.store.userExists(record.Username); err != nil {
+			return err
+		}
+
+		key := memoryIdempotencyKey{username: record.Username, key: record.Key}
+
+		// Expired records are taken over by the new request
+		existing, ok := r.store.idempotencyKeys[key]
+		if ok && !existing.ExpiresAt.Before(record.CreatedAt) {
+			return nil
+		}
+
+		memorySet(ctx, r.store, r.store.idempotencyKeys, key, models.IdempotencyRecord{
+			Username:    record.Username,
+			Key:         record.Key,
+			Fingerprint: record.Fingerprint,
+			CreatedAt:   record.CreatedAt,
+			ExpiresAt:   record.ExpiresAt,
+		})
+
+		claimed = true
+
+		return nil
+	})
+
+	return claimed, err
+}
+
+func (r *memoryIdempotencyServiceRepo) GetRecord(ctx context.Context, username string, key string) (*models.IdempotencyRecord, error) {
+	record := models.IdempotencyRecord{}
+
+	err := r.store.run(ctx, func(ctx context.Context) error {
+		record = r.store.idempotencyKeys[memoryIdempotencyKey{username: username, key: key}]
+		return nil
+	})
+
+	return &record, err
+}
+
+func (r *memoryIdempotencyServiceRepo) SaveResponse(ctx context.Context, record *models.IdempotencyRecord) error {
+	return r.store.run(ctx, func(ctx context.Context) error {
+		key := memoryIdempotencyKey{username: record.Username, key: record.Key}
+
+		stored, ok := r.store.idempotencyKeys[key]
+		if !ok {
+			return nil
+		}
+
+		stored.ResponseStatus = record.ResponseStatus
+		stored.ResponseBody = append([]byte(nil), record.ResponseBody...)
+		stored.ContentType = record.ContentType
+		stored.ExpiresAt = record.ExpiresAt
+		memorySet(ctx, r.store, r.store.idempotencyKeys, key, stored)
+
+		return nil
+	})
+}
+
+func (r *memoryIdempotencyServiceRepo) ExtendLease(ctx context.Context, username string, key string, expiresAt time.Time) error {
+	return r.store.run(ctx, func(ctx context.Context) error {
+		memoryKey := memoryIdempotencyKey{username: username, key: key}
+
+		stored, ok := r.store.idempotencyKeys[memoryKey]
+		if !ok || stored.Completed() {
+			return nil
+		}
+
+		stored.ExpiresAt = expiresAt
+		memorySet(ctx, r.store, r.store.idempotencyKeys, memoryKey, stored)
+
+		return nil
+	})
+}
+
+func (r *memoryIdempotencyServiceRepo) ReleaseKey(ctx context.Context, username string, key string) error {
+	return r.store.run(ctx, func(ctx context.Context) error {
+		memoryDelete(ctx, r.store, r.store.idempotencyKeys, memoryIdempotencyKey{username: username, key: key})
+		return nil
+	})
+}
+
+func NewMemoryIdempotencyServiceRepo(store *MemoryStore) IdempotencyServiceRepo {
+	return &memoryIdempotencyServiceRepo{
+		store: store,
+	}
+}
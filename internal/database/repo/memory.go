@@ -0,0 +1,165 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/fuzzy-toozy/gophermart/internal/common"
+	"github.com/fuzzy-toozy/gophermart/internal/database"
+	"github.com/fuzzy-toozy/gophermart/internal/models"
+)
+
+type memoryUser struct {
+	user               models.User
+	tier               string
+	referralRewardedAt *time.Time
+}
+
+type memoryBalance struct {
+	current   float64
+	withdrawn float64
+}
+
+type memoryLedgerTransaction struct {
+	id string
+	ledgerTransaction
+}
+
+type memoryIdempotencyKey struct {
+	username string
+	key      string
+}
+
+type memoryPromoRedemption struct {
+	code     string
+	username string
+}
+
+type memoryDelivery struct {
+	delivery      models.WebhookDelivery
+	status        string
+	nextAttemptAt time.Time
+	createdAt     time.Time
+}
+
+type memoryOutboxEvent struct {
+	seq         int64
+	event       models.Event
+	payload     []byte
+	publishedAt *time.Time
+	lockedUntil *time.Time
+}
+
+// MemoryStore keeps the tables of in-memory repositories. Repositories created
+// with the same store share data like repositories of the same database do.
+type MemoryStore struct {
+	storage *database.MemoryStorage
+
+	users            map[string]memoryUser
+	orders           map[string]models.Order
+	withdrawals      map[string]models.Withdrawal
+	holds            map[string]models.Hold
+	transfers        []models.Transfer
+	balances         map[string]memoryBalance
+	ledger           []memoryLedgerTransaction
+	idempotencyKeys  map[memoryIdempotencyKey]models.IdempotencyRecord
+	tierChanges      []models.TierChange
+	campaigns        map[string]models.Campaign
+	rewards          map[string]models.Reward
+	redemptions      []models.Redemption
+	promoCodes       map[string]models.PromoCode
+	promoRedemptions map[memoryPromoRedemption]time.Time
+	subscriptions    map[string]models.WebhookSubscription
+	deliveries       map[string]memoryDelivery
+	deliveryLog      []models.WebhookAttempt
+	outbox           []memoryOutboxEvent
+	outboxSeq        int64
+}
+
+func NewMemoryStore(storage *database.MemoryStorage) *MemoryStore {
+	return &MemoryStore{
+		storage:          storage,
+		users:            make(map[string]memoryUser),
+		orders:           make(map[string]models.Order),
+		withdrawals:      make(map[string]models.Withdrawal),
+		holds:            make(map[string]models.Hold),
+		balances:         make(map[string]memoryBalance),
+		idempotencyKeys:  make(map[memoryIdempotencyKey]models.IdempotencyRecord),
+		campaigns:        make(map[string]models.Campaign),
+		rewards:          make(map[string]models.Reward),
+		promoCodes:       make(map[string]models.PromoCode),
+		promoRedemptions: make(map[memoryPromoRedemption]time.Time),
+		subscriptions:    make(map[string]models.WebhookSubscription),
+		deliveries:       make(map[string]memoryDelivery),
+	}
+}
+
+// run runs the operation in the storage transaction, so it sees data of the outer transaction
+// and its changes are undone if the transaction fails
+func (s *MemoryStore) run(ctx context.Context, callback func(ctx context.Context) error) error {
+	return s.storage.RunInTransaction(ctx, callback)
+}
+
+// userExists replaces foreign key checks of the database
+func (s *MemoryStore) userExists(username string) error {
+	if _, ok := s.users[username]; !ok {
+		return fmt.Errorf("user '%v' doesn't exist", username)
+	}
+
+	return nil
+}
+
+func newMemoryID() (string, error) {
+	return common.NewUUID()
+}
+
+// memorySet stores the value and restores the previous one on rollback
+func memorySet[K comparable, V any](ctx context.Context, s *MemoryStore, table map[K]V, key K, value V) {
+	old, existed := table[key]
+	table[key] = value
+
+	s.storage.OnRollback(ctx, func() {
+		if existed {
+			table[key] = old
+		} else {
+			delete(table, key)
+		}
+	})
+}
+
+// memoryDelete deletes the value and restores it on rollback. It returns false if there is no value.
+func memoryDelete[K comparable, V any](ctx context.Context, s *MemoryStore, table map[K]V, key K) bool {
+	old, existed := table[key]
+	if !existed {
+		return false
+	}
+
+	delete(table, key)
+
+	s.storage.OnRollback(ctx, func() {
+		table[key] = old
+	})
+
+	return true
+}
+
+// memoryAppend appends the value and removes it on rollback
+func memoryAppend[V any](ctx context.Context, s *MemoryStore, table *[]V, value V) {
+	n := len(*table)
+	*table = append(*table, value)
+
+	s.storage.OnRollback(ctx, func() {
+		*table = (*table)[:n]
+	})
+}
+
+// memoryReplace replaces all values and restores the old ones on rollback
+func memoryReplace[V any](ctx context.Context, s *MemoryStore, table *[]V, values []V) {
+	old := *table
+	*table = values
+
+	s.storage.OnRollback(ctx, func() {
+		*table = old
+	})
+}
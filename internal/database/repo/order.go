@@ -14,9 +14,12 @@ type OrderServiceRepo interface {
 	GetOrderByNumber(ctx context.Context, number string) (*models.Order, error)
 	GetAllUserOrders(ctx context.Context, username string) ([]models.Order, error)
 	GetAllUnprocessedOrders(ctx context.Context) ([]models.Order, error)
+	GetProcessedOrdersCount(ctx context.Context, username string) (int, error)
 
 	AddNewOrder(ctx context.Context, order *models.Order) error
 
+	// UpdateStatus changes status of the order unless it's final already, which is reported by ErrOrderFinalized.
+	// So the order is credited once when several instances process it.
 	UpdateStatus(ctx context.Context, order *models.Order) error
 	UpdateAccural(ctx context.Context, order *models.Order, accural float64) error
 }
@@ -28,6 +31,7 @@ type orderQueryConfig struct {
 	updateAccural          string
 	getAllUserOrders       string
 	getAllUnprocessedOders string
+	getProcessedCount      string
 }
 
 type orderServiceRepo struct {
@@ -41,23 +45,31 @@ func getOrderQueries() orderQueryConfig {
 	c.getOrderByUsername = "SELECT number, username, uploaded_at, status, accrual FROM orders WHERE number = $1"
 
 	c.addNewOrder = "INSERT INTO orders(number, username, uploaded_at, status) " +
-		"VALUES ($1, $2, $3, $4) ON CONFLICT (number) DO NOTHING"
+		"SELECT $1::VARCHAR, $2::VARCHAR, $3::TIMESTAMP WITH TIME ZONE, $4::ORDER_STATUS " +
+		"WHERE NOT EXISTS (SELECT 1 FROM withdrawals WHERE number = $1) " +
+		"ON CONFLICT (number) DO NOTHING"
 
-	c.updateStatus = "UPDATE orders SET status = $1 WHERE number = $2"
+	c.updateStatus = "UPDATE orders SET status = $1 WHERE number = $2 AND status NOT IN ('PROCESSED', 'INVALID')"
 
 	c.updateAccural = "UPDATE orders SET accrual = $1 WHERE number = $2"
 
 	c.getAllUserOrders = "SELECT number, username, uploaded_at, status, accrual FROM orders WHERE username = $1"
 
-	c.getAllUnprocessedOders = "SELECT * FROM orders WHERE status in ('NEW', 'PROCESSING')"
+	c.getAllUnprocessedOders = "SELECT number, username, uploaded_at, status, accrual FROM orders " +
+		"WHERE status in ('NEW', 'PROCESSING')"
+
+	c.getProcessedCount = "SELECT count(*) FROM orders WHERE username = $1 AND status = 'PROCESSED'"
 
 	return c
 }
 
 func (r *orderServiceRepo) GetOrderByNumber(ctx context.Context, number string) (*models.Order, error) {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
 	order := models.Order{}
 
-	row := r.storage.DB.QueryRowContext(ctx, r.queries.getOrderByUsername, number)
+	row := r.storage.Executor(ctx).QueryRowContext(ctx, r.queries.getOrderByUsername, number)
 
 	err := row.Scan(&order.Number, &order.Username, &order.UploadedAt, &order.Status, &order.Accrual)
 	if err != nil && !errors.Is(err, sql.ErrNoRows) {
@@ -68,12 +80,46 @@ func (r *orderServiceRepo) GetOrderByNumber(ctx context.Context, number string)
 }
 
 func (r *orderServiceRepo) AddNewOrder(ctx context.Context, order *models.Order) error {
-	_, err := r.storage.DB.ExecContext(ctx, r.queries.addNewOrder, order.Number, order.Username, order.UploadedAt, order.Status)
-	return err
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	callback := func(ctx context.Context) error {
+		// Withdrawals take the same lock, so the number can't be used by both
+		err := r.storage.LockKey(ctx, order.Number)
+		if err != nil {
+			return err
+		}
+
+		res, err := r.storage.Executor(ctx).ExecContext(ctx, r.queries.addNewOrder,
+			order.Number, order.Username, order.UploadedAt, order.Status)
+		if err != nil {
+			return err
+		}
+
+		rowsAffected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rowsAffected < 1 {
+			return ErrOrderNumberUsed
+		}
+
+		// Wakes up order processing once the order is committed
+		return r.storage.Notify(ctx, database.NewOrdersChannel, order.Number)
+	}
+
+	return r.storage.RunInTransaction(ctx, callback)
 }
 
+// errOrderNotUpdated is returned when the order to update doesn't exist or doesn't match the update condition
+var errOrderNotUpdated = errors.New("order is not updated")
+
 func (r *orderServiceRepo) updateOrder(ctx context.Context, order *models.Order, query string, args ...any) error {
-	res, err := r.storage.DB.ExecContext(ctx, query, args...)
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	res, err := r.storage.Executor(ctx).ExecContext(ctx, query, args...)
 
 	if err != nil {
 		return err
@@ -86,14 +132,28 @@ func (r *orderServiceRepo) updateOrder(ctx context.Context, order *models.Order,
 	}
 
 	if rowsAffected < 1 {
-		return fmt.Errorf("order with number '%v' doesn't exist", order.Number)
+		return fmt.Errorf("order with number '%v': %w", order.Number, errOrderNotUpdated)
 	}
 
 	return nil
 }
 
 func (r *orderServiceRepo) UpdateStatus(ctx context.Context, order *models.Order) error {
-	return r.updateOrder(ctx, order, r.queries.updateStatus, order.Status, order.Number)
+	err := r.updateOrder(ctx, order, r.queries.updateStatus, order.Status, order.Number)
+	if !errors.Is(err, errOrderNotUpdated) {
+		return err
+	}
+
+	stored, err := r.GetOrderByNumber(ctx, order.Number)
+	if err != nil {
+		return err
+	}
+
+	if len(stored.Number) == 0 {
+		return fmt.Errorf("order with number '%v' doesn't exist", order.Number)
+	}
+
+	return fmt.Errorf("order '%v' status is '%v': %w", order.Number, stored.Status, ErrOrderFinalized)
 }
 
 func (r *orderServiceRepo) UpdateAccural(ctx context.Context, order *models.Order, accural float64) error {
@@ -101,7 +161,10 @@ func (r *orderServiceRepo) UpdateAccural(ctx context.Context, order *models.Orde
 }
 
 func (r *orderServiceRepo) getOrders(ctx context.Context, query string, args ...any) ([]models.Order, error) {
-	row, err := r.storage.DB.QueryContext(ctx, query, args...)
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	row, err := r.storage.Executor(ctx).QueryContext(ctx, query, args...)
 
 	if err != nil {
 		return nil, err
@@ -137,11 +200,29 @@ func (r *orderServiceRepo) GetAllUnprocessedOrders(ctx context.Context) ([]model
 	return r.getOrders(ctx, r.queries.getAllUnprocessedOders)
 }
 
+func (r *orderServiceRepo) GetProcessedOrdersCount(ctx context.Context, username string) (int, error) {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	var count int
+
+	err := r.storage.Executor(ctx).QueryRowContext(ctx, r.queries.getProcessedCount, username).Scan(&count)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+
+	return count, nil
+}
+
 func NewOrderServiceRepo(storage *database.ServiceStorage) OrderServiceRepo {
 	r := orderServiceRepo{
 		storage: storage,
 		queries: getOrderQueries(),
 	}
 
+	if storage.Dialect() == database.DialectSQLite {
+		r.queries = getSQLiteOrderQueries()
+	}
+
 	return &r
 }
@@ -0,0 +1,125 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/fuzzy-toozy/gophermart/internal/database"
+	"github.com/fuzzy-toozy/gophermart/internal/models"
+)
+
+type memoryOrderServiceRepo struct {
+	store *MemoryStore
+}
+
+func (r *memoryOrderServiceRepo) GetOrderByNumber(ctx context.Context, number string) (*models.Order, error) {
+	order := models.Order{}
+
+	err := r.store.run(ctx, func(ctx context.Context) error {
+		order = r.store.orders[number]
+		return nil
+	})
+
+	return &order, err
+}
+
+func (r *memoryOrderServiceRepo) getOrders(ctx context.Context, match func(order *models.Order) bool) ([]models.Order, error) {
+	result := make([]models.Order, 0)
+
+	err := r.store.run(ctx, func(ctx context.Context) error {
+		for _, order := range r.store.orders {
+			if match(&order) {
+				result = append(result, order)
+			}
+		}
+
+		return nil
+	})
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].UploadedAt.Before(result[j].UploadedAt)
+	})
+
+	return result, err
+}
+
+func (r *memoryOrderServiceRepo) GetAllUserOrders(ctx context.Context, username string) ([]models.Order, error) {
+	return r.getOrders(ctx, func(order *models.Order) bool {
+		return order.Username == username
+	})
+}
+
+func (r *memoryOrderServiceRepo) GetAllUnprocessedOrders(ctx context.Context) ([]models.Order, error) {
+	return r.getOrders(ctx, func(order *models.Order) bool {
+		return order.Status == models.OrderNEW || order.Status == models.OrderPROCESSING
+	})
+}
+
+func (r *memoryOrderServiceRepo) GetProcessedOrdersCount(ctx context.Context, username string) (int, error) {
+	orders, err := r.getOrders(ctx, func(order *models.Order) bool {
+		return order.Username == username && order.Status == models.OrderPROCESSED
+	})
+
+	return len(orders), err
+}
+
+func (r *memoryOrderServiceRepo) AddNewOrder(ctx context.Context, order *models.Order) error {
+	return r.store.run(ctx, func(ctx context.Context) error {
+		if err := r.store.userExists(order.Username); err != nil {
+			return err
+		}
+
+		if _, ok := r.store.orders[order.Number]; ok {
+			return ErrOrderNumberUsed
+		}
+
+		if _, ok := r.store.withdrawals[order.Number]; ok {
+			return ErrOrderNumberUsed
+		}
+
+		memorySet(ctx, r.store, r.store.orders, order.Number, *order)
+
+		// Wakes up order processing once the order is committed
+		return r.store.storage.Notify(ctx, database.NewOrdersChannel, order.Number)
+	})
+}
+
+func (r *memoryOrderServiceRepo) updateOrder(ctx context.Context, order *models.Order, update func(stored *models.Order)) error {
+	return r.store.run(ctx, func(ctx context.Context) error {
+		stored, ok := r.store.orders[order.Number]
+		if !ok {
+			return fmt.Errorf("order with number '%v' doesn't exist", order.Number)
+		}
+
+		update(&stored)
+		memorySet(ctx, r.store, r.store.orders, order.Number, stored)
+
+		return nil
+	})
+}
+
+func (r *memoryOrderServiceRepo) UpdateStatus(ctx context.Context, order *models.Order) error {
+	return r.store.run(ctx, func(ctx context.Context) error {
+		stored, ok := r.store.orders[order.Number]
+		if ok && (stored.Status == models.OrderPROCESSED || stored.Status == models.OrderINVALID) {
+			return fmt.Errorf("order '%v' status is '%v': %w", order.Number, stored.Status, ErrOrderFinalized)
+		}
+
+		return r.updateOrder(ctx, order, func(stored *models.Order) {
+			stored.Status = order.Status
+		})
+	})
+}
+
+func (r *memoryOrderServiceRepo) UpdateAccural(ctx context.Context, order *models.Order, accural float64) error {
+	return r.updateOrder(ctx, order, func(stored *models.Order) {
+		stored.Accrual = accural
+	})
+}
+
+func NewMemoryOrderServiceRepo(store *MemoryStore) OrderServiceRepo {
+	return &memoryOrderServiceRepo{
+		store: store,
+	}
+}
@@ -0,0 +1,12 @@
+package repo
+
+func getSQLiteOrderQueries() orderQueryConfig {
+	c := getOrderQueries()
+
+	// SQLite has no casts to column types. The where clause is required by upsert after select.
+	c.addNewOrder = "INSERT INTO orders(number, username, uploaded_at, status) " +
+		"SELECT $1, $2, $3, $4 " +
+		"WHERE NOT EXISTS (SELECT 1 FROM withdrawals WHERE number = $1) " +
+		"ON CONFLICT (number) DO NOTHING"
+	return c
+}
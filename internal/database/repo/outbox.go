@@ -0,0 +1,184 @@
+package repo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/fuzzy-toozy/gophermart/internal/database"
+	"github.com/fuzzy-toozy/gophermart/internal/models"
+)
+
+type OutboxServiceRepo interface {
+	// AddEvent stores the event in the transaction of ctx, so it exists only if the change it describes is committed
+	AddEvent(ctx context.Context, eventType string, payload []byte, createdAt time.Time) error
+	// PublishPending claims up to limit unpublished events until leaseUntil and passes them to publish in the
+	// order they were added. Publish is called outside of transactions and every published event is marked
+	// right after. It stops on the first failed event, which is released and passed again next time.
+	// Events of expired leases are claimed again.
+	PublishPending(ctx context.Context, now time.Time, leaseUntil time.Time, limit int,
+		publish func(ctx context.Context, event *models.Event) error) (int, error)
+	DeletePublished(ctx context.Context, before time.Time) (int64, error)
+}
+
+type outboxQueryConfig struct {
+	addEvent        string
+	claimPending    string
+	markPublished   string
+	release         string
+	deletePublished string
+}
+
+type outboxServiceRepo struct {
+	storage *database.ServiceStorage
+	queries outboxQueryConfig
+}
+
+func getOutboxQueries() outboxQueryConfig {
+	c := outboxQueryConfig{}
+
+	c.addEvent = "INSERT INTO outbox(event_type, payload, created_at) VALUES ($1, $2, $3)"
+
+	// Other relays skip events being claimed instead of leasing them twice
+	c.claimPending = "UPDATE outbox SET locked_until = $2 WHERE seq IN " +
+		"(SELECT seq FROM outbox WHERE published_at IS NULL AND (locked_until IS NULL OR locked_until <= $1) " +
+		"ORDER BY seq LIMIT $3 FOR UPDATE SKIP LOCKED) " +
+		"RETURNING seq, id, event_type, payload, created_at"
+
+	c.markPublished = "UPDATE outbox SET published_at = $1, locked_until = NULL WHERE seq = $2"
+
+	c.release = "UPDATE outbox SET locked_until = NULL WHERE seq = $1 AND published_at IS NULL"
+
+	c.deletePublished = "DELETE FROM outbox WHERE published_at < $1"
+
+	return c
+}
+
+func (r *outboxServiceRepo) AddEvent(ctx context.Context, eventType string, payload []byte, createdAt time.Time) error {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	_, err := r.storage.Executor(ctx).ExecContext(ctx, r.queries.addEvent, eventType, payload, createdAt)
+	return err
+}
+
+type outboxEvent struct {
+	seq   int64
+	event models.Event
+}
+
+func (r *outboxServiceRepo) claimPending(ctx context.Context, now time.Time, leaseUntil time.Time,
+	limit int) ([]outboxEvent, error) {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	row, err := r.storage.Executor(ctx).QueryContext(ctx, r.queries.claimPending, now, leaseUntil, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer row.Close()
+
+	result := make([]outboxEvent, 0)
+
+	for row.Next() {
+		e := outboxEvent{}
+		var payload []byte
+		err := row.Scan(&e.seq, &e.event.ID, &e.event.Type, &payload, &e.event.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("row scan error: %w", err)
+		}
+
+		e.event.Data = json.RawMessage(payload)
+		result = append(result, e)
+	}
+
+	if err = row.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate claimed outbox events: %w", err)
+	}
+
+	// Returned rows have no order
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].seq < result[j].seq
+	})
+
+	return result, nil
+}
+
+func (r *outboxServiceRepo) markPublished(ctx context.Context, seq int64) error {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	_, err := r.storage.Executor(ctx).ExecContext(ctx, r.queries.markPublished, time.Now(), seq)
+	return err
+}
+
+func (r *outboxServiceRepo) release(ctx context.Context, seq int64) error {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	_, err := r.storage.Executor(ctx).ExecContext(ctx, r.queries.release, seq)
+	return err
+}
+
+// Sinks are not limited by the query timeout, they have their own
+func (r *outboxServiceRepo) PublishPending(ctx context.Context, now time.Time, leaseUntil time.Time, limit int,
+	publish func(ctx context.Context, event *models.Event) error) (int, error) {
+	events, err := r.claimPending(ctx, now, leaseUntil, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to claim pending outbox events: %w", err)
+	}
+
+	return publishClaimed(ctx, events, publish, r.markPublished, r.release)
+}
+
+// publishClaimed publishes claimed events in order. Events left after a failed one are released,
+// so they are published after it next time instead of waiting for the lease to expire.
+func publishClaimed(ctx context.Context, events []outboxEvent, publish func(ctx context.Context, event *models.Event) error,
+	markPublished func(ctx context.Context, seq int64) error, release func(ctx context.Context, seq int64) error) (int, error) {
+	for i := range events {
+		if err := publish(ctx, &events[i].event); err != nil {
+			for _, e := range events[i:] {
+				if rerr := release(ctx, e.seq); rerr != nil {
+					// The lease expires anyway
+					break
+				}
+			}
+
+			return i, err
+		}
+
+		if err := markPublished(ctx, events[i].seq); err != nil {
+			return i, fmt.Errorf("failed to mark outbox event '%v' published: %w", events[i].event.ID, err)
+		}
+	}
+
+	return len(events), nil
+}
+
+func (r *outboxServiceRepo) DeletePublished(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	res, err := r.storage.Executor(ctx).ExecContext(ctx, r.queries.deletePublished, before)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+func NewOutboxServiceRepo(storage *database.ServiceStorage) OutboxServiceRepo {
+	r := outboxServiceRepo{
+		storage: storage,
+		queries: getOutboxQueries(),
+	}
+
+	if storage.Dialect() == database.DialectSQLite {
+		r.queries = getSQLiteOutboxQueries()
+	}
+
+	return &r
+}
@@ -0,0 +1,140 @@
+package repo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/fuzzy-toozy/gophermart/internal/models"
+)
+
+type memoryOutboxServiceRepo struct {
+	store *MemoryStore
+}
+
+func (r *memoryOutboxServiceRepo) AddEvent(ctx context.Context, eventType string, payload []byte, createdAt time.Time) error {
+	return r.store.run(ctx, func(ctx context.Context) error {
+		id, err := newMemoryID()
+		if err != nil {
+			return err
+		}
+
+		// Sequence gaps left by rollbacks don't break the order of events
+		r.store.outboxSeq++
+
+		memoryAppend(ctx, r.store, &r.store.outbox, memoryOutboxEvent{
+			seq:     r.store.outboxSeq,
+			event:   models.Event{ID: id, Type: eventType, CreatedAt: createdAt},
+			payload: append([]byte(nil), payload...),
+		})
+
+		return nil
+	})
+}
+
+func (r *memoryOutboxServiceRepo) claimPending(ctx context.Context, now time.Time, leaseUntil time.Time,
+	limit int) ([]outboxEvent, error) {
+	result := make([]outboxEvent, 0)
+
+	err := r.store.run(ctx, func(ctx context.Context) error {
+		outbox := make([]memoryOutboxEvent, len(r.store.outbox))
+
+		for i, e := range r.store.outbox {
+			if len(result) < limit && e.publishedAt == nil && (e.lockedUntil == nil || !e.lockedUntil.After(now)) {
+				e.lockedUntil = &leaseUntil
+
+				event := e.event
+				event.Data = json.RawMessage(append([]byte(nil), e.payload...))
+				result = append(result, outboxEvent{seq: e.seq, event: event})
+			}
+
+			outbox[i] = e
+		}
+
+		if len(result) > 0 {
+			memoryReplace(ctx, r.store, &r.store.outbox, outbox)
+		}
+
+		return nil
+	})
+
+	return result, err
+}
+
+// update changes the unpublished event with the sequence number
+func (r *memoryOutboxServiceRepo) update(ctx context.Context, seq int64, change func(e *memoryOutboxEvent)) error {
+	return r.store.run(ctx, func(ctx context.Context) error {
+		outbox := make([]memoryOutboxEvent, len(r.store.outbox))
+
+		for i, e := range r.store.outbox {
+			if e.seq == seq && e.publishedAt == nil {
+				change(&e)
+			}
+
+			outbox[i] = e
+		}
+
+		memoryReplace(ctx, r.store, &r.store.outbox, outbox)
+
+		return nil
+	})
+}
+
+func (r *memoryOutboxServiceRepo) markPublished(ctx context.Context, seq int64) error {
+	now := time.Now()
+
+	return r.update(ctx, seq, func(e *memoryOutboxEvent) {
+		e.publishedAt = &now
+		e.lockedUntil = nil
+	})
+}
+
+func (r *memoryOutboxServiceRepo) release(ctx context.Context, seq int64) error {
+	return r.update(ctx, seq, func(e *memoryOutboxEvent) {
+		e.lockedUntil = nil
+	})
+}
+
+// PublishPending doesn't hold the storage lock while sinks are called, so a slow
+// sink doesn't block other operations
+func (r *memoryOutboxServiceRepo) PublishPending(ctx context.Context, now time.Time, leaseUntil time.Time, limit int,
+	publish func(ctx context.Context, event *models.Event) error) (int, error) {
+	events, err := r.claimPending(ctx, now, leaseUntil, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to claim pending outbox events: %w", err)
+	}
+
+	return publishClaimed(ctx, events, publish, r.markPublished, r.release)
+}
+
+func (r *memoryOutboxServiceRepo) DeletePublished(ctx context.Context, before time.Time) (int64, error) {
+	var deleted int64
+
+	err := r.store.run(ctx, func(ctx context.Context) error {
+		outbox := make([]memoryOutboxEvent, 0, len(r.store.outbox))
+
+		for _, e := range r.store.outbox {
+			if e.publishedAt != nil && e.publishedAt.Before(before) {
+				deleted++
+				continue
+			}
+
+			outbox = append(outbox, e)
+		}
+
+		if deleted > 0 {
+			memoryReplace(ctx, r.store, &r.store.outbox, outbox)
+		}
+
+		return nil
+	})
+
+	return deleted, err
+}
+
+func NewMemoryOutboxServiceRepo(store *MemoryStore) OutboxServiceRepo {
+	return &memoryOutboxServiceRepo{
+		store: store,
+	}
+}
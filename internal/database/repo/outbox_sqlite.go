@@ -0,0 +1,13 @@
+package repo
+
+func getSQLiteOutboxQueries() outboxQueryConfig {
+	c := getOutboxQueries()
+
+	// Statements hold the database write lock, so other relays wait instead of skipping events
+	c.claimPending = "UPDATE outbox SET locked_until = $2 WHERE seq IN " +
+		"(SELECT seq FROM outbox WHERE published_at IS NULL AND (locked_until IS NULL OR locked_until <= $1) " +
+		"ORDER BY seq LIMIT $3) " +
+		"RETURNING seq, id, event_type, payload, created_at"
+
+	return c
+}
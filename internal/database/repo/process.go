@@ -2,27 +2,110 @@ package repo
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"math"
+	"time"
 
 	"github.com/fuzzy-toozy/gophermart/internal/database"
+	"github.com/fuzzy-toozy/gophermart/internal/events"
 	"github.com/fuzzy-toozy/gophermart/internal/models"
 )
 
+// Limit caps the sum spent since the given moment
+type Limit struct {
+	Since time.Time
+	Max   float64
+	// Err tells which limit is exceeded, it is wrapped with ErrLimitExceeded
+	Err error
+}
+
+func (l Limit) exceeded() error {
+	if l.Err == nil {
+		return ErrLimitExceeded
+	}
+
+	return fmt.Errorf("%w: %w", ErrLimitExceeded, l.Err)
+}
+
+// ReferralBonuses are paid when the first order of the invited user is processed. Zero bonus is not paid.
+type ReferralBonuses struct {
+	Referee  float64
+	Referrer float64
+}
+
+// ReferralReward tells who invited the order owner if the referral was rewarded by the order.
+// Fraudulent referral is marked rewarded, but bonuses are not paid.
+type ReferralReward struct {
+	Referrer string
+	Fraud    bool
+}
+
 type ProcessServiceRepo interface {
-	ProcessOrder(ctx context.Context, order *models.Order, accural float64) error
-	WithdrawBalance(ctx context.Context, wd *models.Withdraw, username string) error
+	// ProcessOrder credits the order accrual and pays referral bonuses in the same transaction
+	ProcessOrder(ctx context.Context, order *models.Order, accural float64, bonuses ReferralBonuses) (ReferralReward, error)
+	WithdrawBalance(ctx context.Context, wd *models.Withdraw, username string, limits ...Limit) error
+	CompleteWithdrawal(ctx context.Context, number string) (*models.Withdrawal, error)
+	CancelWithdrawal(ctx context.Context, number string) (*models.Withdrawal, error)
+	HoldBalance(ctx context.Context, hold *models.Hold) error
+	CaptureHold(ctx context.Context, id string, username string, order string, limits ...Limit) (*models.Hold, error)
+	ReleaseHold(ctx context.Context, id string, username string) (*models.Hold, error)
+	ExpirePoints(ctx context.Context, username string, cutoff time.Time) (float64, error)
+	GetUsersWithExpirablePoints(ctx context.Context, cutoff time.Time) ([]string, error)
+	TransferBalance(ctx context.Context, transfer *models.Transfer, limits ...Limit) error
+	// RedeemReward exchanges user points for one item of the reward
+	RedeemReward(ctx context.Context, rewardID string, username string) (*models.Redemption, error)
+	// AddPromoCodes stores all promo codes or none of them
+	AddPromoCodes(ctx context.Context, codes []models.PromoCode) error
+	// RedeemPromoCode credits the promo code value to the user
+	RedeemPromoCode(ctx context.Context, code string, username string) (*models.PromoCode, error)
+	// AdjustBalance credits positive and debits negative amount to the user balance
+	AdjustBalance(ctx context.Context, username string, adjustment *models.Adjustment) error
 	GetAllUnprocessedOrders(ctx context.Context) ([]models.Order, error)
+	GetOrderByNumber(ctx context.Context, number string) (*models.Order, error)
 	UpdateOrderStatus(ctx context.Context, order *models.Order) error
 }
 
 type processRepo struct {
-	balanceRepo BalanceServiceRepo
-	ordersRepo  OrderServiceRepo
-	storage     *database.ServiceStorage
+	balanceRepo    BalanceServiceRepo
+	ordersRepo     OrderServiceRepo
+	withdrawalRepo WithdrawalServiceRepo
+	holdRepo       HoldServiceRepo
+	transferRepo   TransferServiceRepo
+	campaignRepo   CampaignServiceRepo
+	referralRepo   ReferralServiceRepo
+	rewardRepo     RewardServiceRepo
+	promoRepo      PromoServiceRepo
+	outboxRepo     OutboxServiceRepo
+	storage        database.Transactor
+	bus            *events.Bus
+}
+
+// addOutboxEvent must be called in the transaction making the change the event describes
+func (r *processRepo) addOutboxEvent(ctx context.Context, eventType string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal '%v' event: %w", eventType, err)
+	}
+
+	err = r.outboxRepo.AddEvent(ctx, eventType, payload, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to add '%v' event to outbox: %w", eventType, err)
+	}
+
+	return nil
+}
+
+// publishBalance notifies the user about committed balance change
+func (r *processRepo) publishBalance(username string, reason string, order string, sum float64) {
+	r.bus.Publish(username, events.EventBalance, models.BalanceEvent{Reason: reason, Order: order, Sum: sum})
 }
 
-func (r *processRepo) ProcessOrder(ctx context.Context, order *models.Order, accural float64) error {
-	callback := func() error {
+func (r *processRepo) ProcessOrder(ctx context.Context, order *models.Order, accural float64,
+	bonuses ReferralBonuses) (ReferralReward, error) {
+	var reward ReferralReward
+
+	callback := func(ctx context.Context) error {
 		err := r.ordersRepo.UpdateStatus(ctx, order)
 		if err != nil {
 			return fmt.Errorf("failed to update order '%v' status: %w", order.Number, err)
@@ -38,39 +121,454 @@ func (r *processRepo) ProcessOrder(ctx context.Context, order *models.Order, acc
 			return fmt.Errorf("failed to add new balance record: %w", err)
 		}
 
+		err = r.addCampaignBonuses(ctx, order, accural)
+		if err != nil {
+			return err
+		}
+
+		reward, err = r.rewardReferral(ctx, order.Username, bonuses)
+		if err != nil {
+			return err
+		}
+
+		processed := *order
+		processed.Accrual = accural
+
+		return r.addOutboxEvent(ctx, models.EventOrderStatusChanged, &processed)
+	}
+
+	err := r.storage.RunInTransaction(ctx, callback)
+	if err != nil {
+		return ReferralReward{}, err
+	}
+
+	r.publishBalance(order.Username, models.BalanceACCRUAL, order.Number, accural)
+
+	if len(reward.Referrer) > 0 && !reward.Fraud {
+		if bonuses.Referee > 0 {
+			r.publishBalance(order.Username, models.BalanceREFERRAL, "", bonuses.Referee)
+		}
+
+		if bonuses.Referrer > 0 {
+			r.publishBalance(reward.Referrer, models.BalanceREFERRAL, "", bonuses.Referrer)
+		}
+	}
+
+	return reward, nil
+}
+
+// addCampaignBonuses credits bonuses of all campaigns active at the moment the order is credited
+func (r *processRepo) addCampaignBonuses(ctx context.Context, order *models.Order, accural float64) error {
+	campaigns, err := r.campaignRepo.GetActiveCampaigns(ctx, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to get active campaigns: %w", err)
+	}
+
+	if len(campaigns) == 0 {
 		return nil
 	}
 
-	return r.storage.RunInTransaction(callback)
+	// User caps must be checked against bonuses of concurrently processed orders
+	err = r.balanceRepo.LockUserBalance(ctx, order.Username)
+	if err != nil {
+		return err
+	}
+
+	// The order is already processed, so it is counted too
+	processed, err := r.ordersRepo.GetProcessedOrdersCount(ctx, order.Username)
+	if err != nil {
+		return fmt.Errorf("failed to get processed orders count: %w", err)
+	}
+
+	for _, campaign := range campaigns {
+		if !campaign.Applies(processed) {
+			continue
+		}
+
+		granted := 0.0
+		if campaign.UserCap > 0 {
+			granted, err = r.balanceRepo.GetCampaignBonus(ctx, order.Username, campaign.ID)
+			if err != nil {
+				return fmt.Errorf("failed to get campaign '%v' bonus: %w", campaign.ID, err)
+			}
+		}
+
+		bonus := campaign.Bonus(accural, granted)
+		if bonus <= 0 {
+			continue
+		}
+
+		err = r.balanceRepo.AddBonusRecord(ctx, order.Username, order.Number, campaign.ID, bonus)
+		if err != nil {
+			return fmt.Errorf("failed to add campaign '%v' bonus record: %w", campaign.ID, err)
+		}
+	}
+
+	return nil
 }
 
-func (r *processRepo) WithdrawBalance(ctx context.Context, wd *models.Withdraw, username string) error {
-	callback := func() error {
-		balance, err := r.balanceRepo.GetBanaceData(ctx, username)
+func (r *processRepo) WithdrawBalance(ctx context.Context, wd *models.Withdraw, username string, limits ...Limit) error {
+	callback := func(ctx context.Context) error {
+		return r.withdrawBalance(ctx, wd, username, limits...)
+	}
+
+	err := r.storage.RunInTransaction(ctx, callback)
+	if err != nil {
+		return err
+	}
+
+	r.publishBalance(username, models.BalanceWITHDRAWAL, wd.Order, wd.Sum)
+
+	return nil
+}
+
+// withdrawBalance must be called in transaction
+func (r *processRepo) withdrawBalance(ctx context.Context, wd *models.Withdraw, username string, limits ...Limit) error {
+	err := r.balanceRepo.LockUserBalance(ctx, username)
+	if err != nil {
+		return err
+	}
+
+	// Orders take the same lock, so the number can't be used by both
+	err = r.storage.LockKey(ctx, wd.Order)
+	if err != nil {
+		return err
+	}
+
+	order, err := r.ordersRepo.GetOrderByNumber(ctx, wd.Order)
+	if err != nil {
+		return fmt.Errorf("failed to get order '%v': %w", wd.Order, err)
+	}
+
+	if len(order.Number) > 0 {
+		return ErrOrderNumberUsed
+	}
+
+	for _, limit := range limits {
+		withdrawn, err := r.withdrawalRepo.GetWithdrawnSum(ctx, username, limit.Since)
+		if err != nil {
+			return fmt.Errorf("failed to get withdrawn sum: %w", err)
+		}
+
+		if withdrawn+wd.Sum > limit.Max {
+			return limit.exceeded()
+		}
+	}
+
+	balance, err := r.balanceRepo.GetBanaceData(ctx, username)
+	if err != nil {
+		return fmt.Errorf("failed to get balance data: %w", err)
+	}
+
+	if wd.Sum > balance.Current {
+		return ErrWithdrawUnavailable
+	}
+
+	withdrawal := models.NewWithdrawal(username, wd)
+
+	err = r.withdrawalRepo.AddNewWithdrawal(ctx, withdrawal)
+	if err != nil {
+		return fmt.Errorf("failed to add new withdrawal: %w", err)
+	}
+
+	err = r.balanceRepo.AddWithdrawRecord(ctx, username, wd.Order, wd.Sum)
+	if err != nil {
+		return fmt.Errorf("failed to add widthdraw record: %w", err)
+	}
+
+	return r.addOutboxEvent(ctx, models.EventWithdrawalCreated,
+		models.WithdrawalEvent{Login: username, Withdrawal: *withdrawal})
+}
+
+func (r *processRepo) finishWithdrawal(ctx context.Context, number string, status string) (*models.Withdrawal, error) {
+	var wd *models.Withdrawal
+
+	callback := func(ctx context.Context) error {
+		err := r.storage.LockKey(ctx, number)
+		if err != nil {
+			return err
+		}
+
+		wd, err = r.withdrawalRepo.GetWithdrawalByNumber(ctx, number)
+		if err != nil {
+			return fmt.Errorf("failed to get withdrawal '%v': %w", number, err)
+		}
+
+		if len(wd.Order) == 0 {
+			return ErrWithdrawNotFound
+		}
+
+		if wd.Status != models.WithdrawalPENDING {
+			return ErrWithdrawNotPending
+		}
+
+		wd.Status = status
+		wd.UpdatedAt = time.Now()
+
+		err = r.withdrawalRepo.UpdateStatus(ctx, wd)
+		if err != nil {
+			return fmt.Errorf("failed to update withdrawal '%v' status: %w", number, err)
+		}
+
+		event := models.EventWithdrawalCompleted
+
+		if status == models.WithdrawalCANCELLED {
+			event = models.EventWithdrawalCancelled
+
+			// The withdraw record is kept for audit and compensated by the refund
+			err = r.balanceRepo.AddRefundRecord(ctx, wd.Username, wd.Order, wd.Sum)
+			if err != nil {
+				return fmt.Errorf("failed to add refund record: %w", err)
+			}
+		}
+
+		return r.addOutboxEvent(ctx, event, models.WithdrawalEvent{Login: wd.Username, Withdrawal: *wd})
+	}
+
+	err := r.storage.RunInTransaction(ctx, callback)
+	if err != nil {
+		return nil, err
+	}
+
+	if status == models.WithdrawalCANCELLED {
+		r.publishBalance(wd.Username, models.BalanceREFUND, wd.Order, wd.Sum)
+	}
+
+	return wd, nil
+}
+
+func (r *processRepo) CompleteWithdrawal(ctx context.Context, number string) (*models.Withdrawal, error) {
+	return r.finishWithdrawal(ctx, number, models.WithdrawalCOMPLETED)
+}
+
+func (r *processRepo) CancelWithdrawal(ctx context.Context, number string) (*models.Withdrawal, error) {
+	return r.finishWithdrawal(ctx, number, models.WithdrawalCANCELLED)
+}
+
+func (r *processRepo) HoldBalance(ctx context.Context, hold *models.Hold) error {
+	callback := func(ctx context.Context) error {
+		err := r.balanceRepo.LockUserBalance(ctx, hold.Username)
+		if err != nil {
+			return err
+		}
+
+		balance, err := r.balanceRepo.GetBanaceData(ctx, hold.Username)
 		if err != nil {
 			return fmt.Errorf("failed to get balance data: %w", err)
 		}
 
-		if wd.Sum > balance.Current {
+		if hold.Sum > balance.Current {
 			return ErrWithdrawUnavailable
 		}
 
-		err = r.balanceRepo.AddWithdrawRecord(ctx, username, wd.Order, wd.Sum)
+		err = r.holdRepo.AddNewHold(ctx, hold)
+		if err != nil {
+			return fmt.Errorf("failed to add new hold: %w", err)
+		}
+
+		return nil
+	}
+
+	err := r.storage.RunInTransaction(ctx, callback)
+	if err != nil {
+		return err
+	}
+
+	r.publishBalance(hold.Username, models.BalanceHOLD, "", hold.Sum)
+
+	return nil
+}
+
+func (r *processRepo) finishHold(ctx context.Context, id string, username string,
+	finish func(ctx context.Context, hold *models.Hold) error) (*models.Hold, error) {
+	var hold *models.Hold
+
+	callback := func(ctx context.Context) error {
+		err := r.balanceRepo.LockUserBalance(ctx, username)
+		if err != nil {
+			return err
+		}
+
+		hold, err = r.holdRepo.GetHoldByID(ctx, id)
 		if err != nil {
-			return fmt.Errorf("failed to add widthdraw record: %w", err)
+			return fmt.Errorf("failed to get hold '%v': %w", id, err)
+		}
+
+		if len(hold.ID) == 0 || hold.Username != username {
+			return ErrHoldNotFound
+		}
+
+		now := time.Now()
+		if !hold.Active(now) {
+			return ErrHoldNotActive
 		}
 
-		order := models.NewOrder(username, wd.Order)
+		hold.UpdatedAt = now
 
-		err = r.ordersRepo.AddNewOrder(ctx, order)
+		return finish(ctx, hold)
+	}
+
+	err := r.storage.RunInTransaction(ctx, callback)
+	if err != nil {
+		return nil, err
+	}
+
+	return hold, nil
+}
+
+// CaptureHold turns the hold into a withdrawal for the order
+func (r *processRepo) CaptureHold(ctx context.Context, id string, username string, order string, limits ...Limit) (*models.Hold, error) {
+	hold, err := r.finishHold(ctx, id, username, func(ctx context.Context, hold *models.Hold) error {
+		hold.Status = models.HoldCAPTURED
+		hold.Order = order
+
+		// The hold must not reserve the balance anymore when the withdrawal is checked
+		err := r.holdRepo.UpdateHold(ctx, hold)
 		if err != nil {
-			return fmt.Errorf("failed to add new withdraw order: %w", err)
+			return fmt.Errorf("failed to update hold '%v': %w", hold.ID, err)
+		}
+
+		return r.withdrawBalance(ctx, &models.Withdraw{Order: order, Sum: hold.Sum}, username, limits...)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	r.publishBalance(username, models.BalanceWITHDRAWAL, order, hold.Sum)
+
+	return hold, nil
+}
+
+func (r *processRepo) ReleaseHold(ctx context.Context, id string, username string) (*models.Hold, error) {
+	hold, err := r.finishHold(ctx, id, username, func(ctx context.Context, hold *models.Hold) error {
+		hold.Status = models.HoldRELEASED
+
+		err := r.holdRepo.UpdateHold(ctx, hold)
+		if err != nil {
+			return fmt.Errorf("failed to update hold '%v': %w", hold.ID, err)
 		}
 
 		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return r.storage.RunInTransaction(callback)
+	r.publishBalance(username, models.BalanceRELEASE, "", hold.Sum)
+
+	return hold, nil
+}
+
+// ExpirePoints writes off points credited to the user before cutoff and not spent yet.
+// Points reserved by active holds are not expired, so the holds can still be captured.
+func (r *processRepo) ExpirePoints(ctx context.Context, username string, cutoff time.Time) (float64, error) {
+	var expired float64
+
+	callback := func(ctx context.Context) error {
+		err := r.balanceRepo.LockUserBalance(ctx, username)
+		if err != nil {
+			return err
+		}
+
+		expired, err = r.balanceRepo.GetExpirablePoints(ctx, username, cutoff)
+		if err != nil {
+			return fmt.Errorf("failed to get expirable points: %w", err)
+		}
+
+		balance, err := r.balanceRepo.GetBanaceData(ctx, username)
+		if err != nil {
+			return fmt.Errorf("failed to get balance: %w", err)
+		}
+
+		// Current balance doesn't include held points
+		expired = math.Max(0, math.Min(expired, balance.Current))
+
+		if expired == 0 {
+			return nil
+		}
+
+		err = r.balanceRepo.AddExpiryRecord(ctx, username, expired)
+		if err != nil {
+			return fmt.Errorf("failed to add expiry record: %w", err)
+		}
+
+		return nil
+	}
+
+	err := r.storage.RunInTransaction(ctx, callback)
+	if err != nil {
+		return 0, err
+	}
+
+	if expired > 0 {
+		r.publishBalance(username, models.BalanceEXPIRY, "", expired)
+	}
+
+	return expired, nil
+}
+
+func (r *processRepo) GetUsersWithExpirablePoints(ctx context.Context, cutoff time.Time) ([]string, error) {
+	return r.balanceRepo.GetUsersWithExpirablePoints(ctx, cutoff)
+}
+
+// TransferBalance moves points from the sender to the recipient
+func (r *processRepo) TransferBalance(ctx context.Context, transfer *models.Transfer, limits ...Limit) error {
+	callback := func(ctx context.Context) error {
+		// Users are always locked in the same order to avoid deadlocks
+		first, second := transfer.Sender, transfer.Recipient
+		if first > second {
+			first, second = second, first
+		}
+
+		for _, username := range []string{first, second} {
+			err := r.balanceRepo.LockUserBalance(ctx, username)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, limit := range limits {
+			sent, err := r.transferRepo.GetSentSum(ctx, transfer.Sender, limit.Since)
+			if err != nil {
+				return fmt.Errorf("failed to get transferred sum: %w", err)
+			}
+
+			if sent+transfer.Sum > limit.Max {
+				return limit.exceeded()
+			}
+		}
+
+		balance, err := r.balanceRepo.GetBanaceData(ctx, transfer.Sender)
+		if err != nil {
+			return fmt.Errorf("failed to get balance data: %w", err)
+		}
+
+		if transfer.Sum > balance.Current {
+			return ErrWithdrawUnavailable
+		}
+
+		err = r.transferRepo.AddNewTransfer(ctx, transfer)
+		if err != nil {
+			return fmt.Errorf("failed to add new transfer: %w", err)
+		}
+
+		err = r.balanceRepo.AddTransferRecords(ctx, transfer)
+		if err != nil {
+			return fmt.Errorf("failed to add transfer records: %w", err)
+		}
+
+		return nil
+	}
+
+	err := r.storage.RunInTransaction(ctx, callback)
+	if err != nil {
+		return err
+	}
+
+	r.publishBalance(transfer.Sender, models.BalanceTRANSFEROUT, "", transfer.Sum)
+	r.publishBalance(transfer.Recipient, models.BalanceTRANSFERIN, "", transfer.Sum)
+
+	return nil
 }
 
 func (r *processRepo) GetAllUnprocessedOrders(ctx context.Context) ([]models.Order, error) {
@@ -82,16 +580,225 @@ func (r *processRepo) GetAllUnprocessedOrders(ctx context.Context) ([]models.Ord
 	return orders, nil
 }
 
+func (r *processRepo) GetOrderByNumber(ctx context.Context, number string) (*models.Order, error) {
+	return r.ordersRepo.GetOrderByNumber(ctx, number)
+}
+
 func (r *processRepo) UpdateOrderStatus(ctx context.Context, order *models.Order) error {
-	return r.ordersRepo.UpdateStatus(ctx, order)
+	return r.storage.RunInTransaction(ctx, func(ctx context.Context) error {
+		err := r.ordersRepo.UpdateStatus(ctx, order)
+		if err != nil {
+			return err
+		}
+
+		return r.addOutboxEvent(ctx, models.EventOrderStatusChanged, order)
+	})
+}
+
+func (r *processRepo) RedeemReward(ctx context.Context, rewardID string, username string) (*models.Redemption, error) {
+	var redemption *models.Redemption
+
+	callback := func(ctx context.Context) error {
+		err := r.balanceRepo.LockUserBalance(ctx, username)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+
+		reward, err := r.rewardRepo.TakeFromStock(ctx, rewardID, now)
+		if err != nil {
+			return err
+		}
+
+		balance, err := r.balanceRepo.GetBanaceData(ctx, username)
+		if err != nil {
+			return fmt.Errorf("failed to get balance data: %w", err)
+		}
+
+		if reward.Cost > balance.Current {
+			return ErrWithdrawUnavailable
+		}
+
+		redemption = &models.Redemption{
+			Username:   username,
+			RewardID:   reward.ID,
+			RewardName: reward.Name,
+			Cost:       reward.Cost,
+			CreatedAt:  now,
+		}
+
+		err = r.rewardRepo.AddNewRedemption(ctx, redemption)
+		if err != nil {
+			return fmt.Errorf("failed to add new redemption: %w", err)
+		}
+
+		err = r.balanceRepo.AddRedemptionRecord(ctx, username, redemption.ID, reward.Cost)
+		if err != nil {
+			return fmt.Errorf("failed to add redemption record: %w", err)
+		}
+
+		return nil
+	}
+
+	err := r.storage.RunInTransaction(ctx, callback)
+	if err != nil {
+		return nil, err
+	}
+
+	r.publishBalance(username, models.BalanceREWARD, "", redemption.Cost)
+
+	return redemption, nil
+}
+
+func (r *processRepo) AddPromoCodes(ctx context.Context, codes []models.PromoCode) error {
+	return r.storage.RunInTransaction(ctx, func(ctx context.Context) error {
+		for i := range codes {
+			err := r.promoRepo.AddNewPromoCode(ctx, &codes[i])
+			if err != nil {
+				return fmt.Errorf("promo code '%v': %w", codes[i].Code, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+func (r *processRepo) RedeemPromoCode(ctx context.Context, code string, username string) (*models.PromoCode, error) {
+	var promo *models.PromoCode
+
+	callback := func(ctx context.Context) error {
+		now := time.Now()
+
+		var err error
+		promo, err = r.promoRepo.UsePromoCode(ctx, code, now)
+		if err != nil {
+			return err
+		}
+
+		// Repeated redemption rolls back the counted use
+		err = r.promoRepo.AddPromoRedemption(ctx, code, username, now)
+		if err != nil {
+			return err
+		}
+
+		err = r.balanceRepo.AddPromoRecord(ctx, username, code, promo.Value)
+		if err != nil {
+			return fmt.Errorf("failed to add promo record: %w", err)
+		}
+
+		return nil
+	}
+
+	err := r.storage.RunInTransaction(ctx, callback)
+	if err != nil {
+		return nil, err
+	}
+
+	r.publishBalance(username, models.BalancePROMO, "", promo.Value)
+
+	return promo, nil
+}
+
+func (r *processRepo) AdjustBalance(ctx context.Context, username string, adjustment *models.Adjustment) error {
+	callback := func(ctx context.Context) error {
+		err := r.balanceRepo.LockUserBalance(ctx, username)
+		if err != nil {
+			return err
+		}
+
+		if adjustment.Amount < 0 {
+			balance, err := r.balanceRepo.GetBanaceData(ctx, username)
+			if err != nil {
+				return fmt.Errorf("failed to get balance data: %w", err)
+			}
+
+			if -adjustment.Amount > balance.Current {
+				return ErrWithdrawUnavailable
+			}
+		}
+
+		err = r.balanceRepo.AddAdjustmentRecord(ctx, username, adjustment.Amount, adjustment.Reason)
+		if err != nil {
+			return fmt.Errorf("failed to add adjustment record: %w", err)
+		}
+
+		return nil
+	}
+
+	err := r.storage.RunInTransaction(ctx, callback)
+	if err != nil {
+		return err
+	}
+
+	r.publishBalance(username, models.BalanceADJUSTMENT, "", adjustment.Amount)
+
+	return nil
+}
+
+// rewardReferral pays referral bonuses to the user and the referrer once.
+// It must be called in the transaction crediting the order.
+func (r *processRepo) rewardReferral(ctx context.Context, referee string, bonuses ReferralBonuses) (ReferralReward, error) {
+	referrer, err := r.referralRepo.MarkRewarded(ctx, referee, time.Now())
+	if err != nil {
+		return ReferralReward{}, fmt.Errorf("failed to mark referral of user '%v' rewarded: %w", referee, err)
+	}
+
+	if len(referrer) == 0 {
+		return ReferralReward{}, nil
+	}
+
+	// Fraudulent referral stays marked, so it is never paid
+	fraud, err := r.referralRepo.IsReferralLoop(ctx, referee)
+	if err != nil {
+		return ReferralReward{}, fmt.Errorf("failed to check referral loop of user '%v': %w", referee, err)
+	}
+
+	if fraud || referrer == referee {
+		return ReferralReward{Referrer: referrer, Fraud: true}, nil
+	}
+
+	if bonuses.Referee > 0 {
+		err = r.balanceRepo.AddReferralRecord(ctx, referee, referee, bonuses.Referee)
+		if err != nil {
+			return ReferralReward{}, fmt.Errorf("failed to add referral record for user '%v': %w", referee, err)
+		}
+	}
+
+	if bonuses.Referrer > 0 {
+		err = r.balanceRepo.AddReferralRecord(ctx, referrer, referee, bonuses.Referrer)
+		if err != nil {
+			return ReferralReward{}, fmt.Errorf("failed to add referral record for user '%v': %w", referrer, err)
+		}
+	}
+
+	return ReferralReward{Referrer: referrer}, nil
 }
 
-func NewProcessRepo(storage *database.ServiceStorage,
+func NewProcessRepo(storage database.Transactor,
 	balanceRepo BalanceServiceRepo,
-	ordersRepo OrderServiceRepo) ProcessServiceRepo {
+	ordersRepo OrderServiceRepo,
+	withdrawalRepo WithdrawalServiceRepo,
+	holdRepo HoldServiceRepo,
+	transferRepo TransferServiceRepo,
+	campaignRepo CampaignServiceRepo,
+	referralRepo ReferralServiceRepo,
+	rewardRepo RewardServiceRepo,
+	promoRepo PromoServiceRepo,
+	outboxRepo OutboxServiceRepo,
+	bus *events.Bus) ProcessServiceRepo {
 	return &processRepo{
-		storage:     storage,
-		balanceRepo: balanceRepo,
-		ordersRepo:  ordersRepo,
+		storage:        storage,
+		balanceRepo:    balanceRepo,
+		ordersRepo:     ordersRepo,
+		withdrawalRepo: withdrawalRepo,
+		holdRepo:       holdRepo,
+		transferRepo:   transferRepo,
+		campaignRepo:   campaignRepo,
+		referralRepo:   referralRepo,
+		rewardRepo:     rewardRepo,
+		promoRepo:      promoRepo,
+		outboxRepo:     outboxRepo,
+		bus:            bus,
 	}
 }
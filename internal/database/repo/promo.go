@@ -0,0 +1,201 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fuzzy-toozy/gophermart/internal/database"
+	"github.com/fuzzy-toozy/gophermart/internal/models"
+)
+
+type PromoServiceRepo interface {
+	GetPromoCode(ctx context.Context, code string) (*models.PromoCode, error)
+	GetAllPromoCodes(ctx context.Context) ([]models.PromoCode, error)
+
+	AddNewPromoCode(ctx context.Context, promo *models.PromoCode) error
+	AddPromoRedemption(ctx context.Context, code string, username string, now time.Time) error
+
+	// UsePromoCode counts one more redemption of the code if it is valid at the moment
+	UsePromoCode(ctx context.Context, code string, now time.Time) (*models.PromoCode, error)
+}
+
+type promoQueryConfig struct {
+	getPromoCode       string
+	getAllPromoCodes   string
+	addNewPromoCode    string
+	addPromoRedemption string
+	usePromoCode       string
+}
+
+type promoServiceRepo struct {
+	storage *database.ServiceStorage
+	queries promoQueryConfig
+}
+
+func getPromoQueries() promoQueryConfig {
+	c := promoQueryConfig{}
+
+	c.getPromoCode = "SELECT code, value, max_redemptions, redemptions, expires_at, created_at " +
+		"FROM promo_codes WHERE code = $1"
+
+	c.getAllPromoCodes = "SELECT code, value, max_redemptions, redemptions, expires_at, created_at " +
+		"FROM promo_codes ORDER BY created_at DESC"
+
+	c.addNewPromoCode = "INSERT INTO promo_codes(code, value, max_redemptions, redemptions, expires_at, created_at) " +
+		"VALUES ($1, $2, $3, $4, $5, $6) ON CONFLICT (code) DO NOTHING"
+
+	c.addPromoRedemption = "INSERT INTO promo_redemptions(code, username, redeemed_at) VALUES ($1, $2, $3) " +
+		"ON CONFLICT (code, username) DO NOTHING"
+
+	// The row lock taken by update makes concurrent redemptions of the last use wait and fail
+	c.usePromoCode = "UPDATE promo_codes SET redemptions = redemptions + 1 " +
+		"WHERE code = $1 AND redemptions < max_redemptions AND (expires_at IS NULL OR expires_at > $2) " +
+		"RETURNING code, value, max_redemptions, redemptions, expires_at, created_at"
+
+	return c
+}
+
+func scanPromoCode(row rowScanner, promo *models.PromoCode) error {
+	var expiresAt sql.NullTime
+	err := row.Scan(&promo.Code, &promo.Value, &promo.MaxRedemptions, &promo.Redemptions, &expiresAt, &promo.CreatedAt)
+	if expiresAt.Valid {
+		promo.ExpiresAt = &expiresAt.Time
+	}
+	return err
+}
+
+func (r *promoServiceRepo) GetPromoCode(ctx context.Context, code string) (*models.PromoCode, error) {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	promo := models.PromoCode{}
+
+	row := r.storage.Executor(ctx).QueryRowContext(ctx, r.queries.getPromoCode, code)
+
+	err := scanPromoCode(row, &promo)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return &promo, err
+	}
+
+	return &promo, nil
+}
+
+func (r *promoServiceRepo) GetAllPromoCodes(ctx context.Context) ([]models.PromoCode, error) {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	row, err := r.storage.Executor(ctx).QueryContext(ctx, r.queries.getAllPromoCodes)
+	if err != nil {
+		return nil, err
+	}
+
+	defer row.Close()
+
+	result := make([]models.PromoCode, 0)
+
+	for row.Next() {
+		promo := models.PromoCode{}
+		err := scanPromoCode(row, &promo)
+		if err != nil {
+			return nil, fmt.Errorf("row scan error: %w", err)
+		}
+
+		result = append(result, promo)
+	}
+
+	if err = row.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate all promo codes: %w", err)
+	}
+
+	return result, nil
+}
+
+func (r *promoServiceRepo) AddNewPromoCode(ctx context.Context, promo *models.PromoCode) error {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	expiresAt := sql.NullTime{}
+	if promo.ExpiresAt != nil {
+		expiresAt = sql.NullTime{Time: *promo.ExpiresAt, Valid: true}
+	}
+
+	res, err := r.storage.Executor(ctx).ExecContext(ctx, r.queries.addNewPromoCode,
+		promo.Code, promo.Value, promo.MaxRedemptions, promo.Redemptions, expiresAt, promo.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected < 1 {
+		return ErrPromoExists
+	}
+
+	return nil
+}
+
+func (r *promoServiceRepo) AddPromoRedemption(ctx context.Context, code string, username string, now time.Time) error {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	res, err := r.storage.Executor(ctx).ExecContext(ctx, r.queries.addPromoRedemption, code, username, now)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected < 1 {
+		return ErrPromoRedeemed
+	}
+
+	return nil
+}
+
+func (r *promoServiceRepo) UsePromoCode(ctx context.Context, code string, now time.Time) (*models.PromoCode, error) {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	promo := models.PromoCode{}
+
+	row := r.storage.Executor(ctx).QueryRowContext(ctx, r.queries.usePromoCode, code, now)
+
+	err := scanPromoCode(row, &promo)
+	if err == nil {
+		return &promo, nil
+	}
+
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	existing, err := r.GetPromoCode(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case len(existing.Code) == 0:
+		return nil, ErrPromoNotFound
+	case existing.ExpiresAt != nil && !existing.ExpiresAt.After(now):
+		return nil, ErrPromoExpired
+	default:
+		return nil, ErrPromoExhausted
+	}
+}
+
+func NewPromoServiceRepo(storage *database.ServiceStorage) PromoServiceRepo {
+	return &promoServiceRepo{
+		storage: storage,
+		queries: getPromoQueries(),
+	}
+}
@@ -0,0 +1,111 @@
+package repo
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/fuzzy-toozy/gophermart/internal/models"
+)
+
+type memoryPromoServiceRepo struct {
+	store *MemoryStore
+}
+
+func (r *memoryPromoServiceRepo) GetPromoCode(ctx context.Context, code string) (*models.PromoCode, error) {
+	promo := models.PromoCode{}
+
+	err := r.store.run(ctx, func(ctx context.Context) error {
+		promo = r.store.promoCodes[code]
+		return nil
+	})
+
+	return &promo, err
+}
+
+func (r *memoryPromoServiceRepo) GetAllPromoCodes(ctx context.Context) ([]models.PromoCode, error) {
+	result := make([]models.PromoCode, 0)
+
+	err := r.store.run(ctx, func(ctx context.Context) error {
+		for _, promo := range r.store.promoCodes {
+			result = append(result, promo)
+		}
+
+		return nil
+	})
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.After(result[j].CreatedAt)
+	})
+
+	return result, err
+}
+
+func (r *memoryPromoServiceRepo) AddNewPromoCode(ctx context.Context, promo *models.PromoCode) error {
+	return r.store.run(ctx, func(ctx context.Context) error {
+		if _, ok := r.store.promoCodes[promo.Code]; ok {
+			return ErrPromoExists
+		}
+
+		memorySet(ctx, r.store, r.store.promoCodes, promo.Code, *promo)
+
+		return nil
+	})
+}
+
+func (r *memoryPromoServiceRepo) AddPromoRedemption(ctx context.Context, code string, username string, now time.Time) error {
+	return r.store.run(ctx, func(ctx context.Context) error {
+		if err := r.store.userExists(username); err != nil {
+			return err
+		}
+
+		if _, ok := r.store.promoCodes[code]; !ok {
+			return ErrPromoNotFound
+		}
+
+		key := memoryPromoRedemption{code: code, username: username}
+		if _, ok := r.store.promoRedemptions[key]; ok {
+			return ErrPromoRedeemed
+		}
+
+		memorySet(ctx, r.store, r.store.promoRedemptions, key, now)
+
+		return nil
+	})
+}
+
+func (r *memoryPromoServiceRepo) UsePromoCode(ctx context.Context, code string, now time.Time) (*models.PromoCode, error) {
+	promo := models.PromoCode{}
+
+	err := r.store.run(ctx, func(ctx context.Context) error {
+		stored, ok := r.store.promoCodes[code]
+
+		switch {
+		case !ok:
+			return ErrPromoNotFound
+		case stored.ExpiresAt != nil && !stored.ExpiresAt.After(now):
+			return ErrPromoExpired
+		case stored.Redemptions >= stored.MaxRedemptions:
+			return ErrPromoExhausted
+		}
+
+		stored.Redemptions++
+		memorySet(ctx, r.store, r.store.promoCodes, code, stored)
+
+		promo = stored
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &promo, nil
+}
+
+func NewMemoryPromoServiceRepo(store *MemoryStore) PromoServiceRepo {
+	return &memoryPromoServiceRepo{
+		store: store,
+	}
+}
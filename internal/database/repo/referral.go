@@ -0,0 +1,81 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/fuzzy-toozy/gophermart/internal/database"
+)
+
+type ReferralServiceRepo interface {
+	// MarkRewarded marks the referral of the user rewarded and returns the referrer.
+	// Empty referrer is returned if the user has no referrer or the referral is already rewarded.
+	MarkRewarded(ctx context.Context, referee string, now time.Time) (string, error)
+	// IsReferralLoop reports whether the user is among own referrers
+	IsReferralLoop(ctx context.Context, referee string) (bool, error)
+}
+
+type referralQueryConfig struct {
+	markRewarded   string
+	isReferralLoop string
+}
+
+type referralServiceRepo struct {
+	storage *database.ServiceStorage
+	queries referralQueryConfig
+}
+
+// Referral chains can't be longer than this in practice
+const maxReferralDepth = 100
+
+func getReferralQueries() referralQueryConfig {
+	c := referralQueryConfig{}
+
+	c.markRewarded = "UPDATE users SET referral_rewarded_at = $2 " +
+		"WHERE username = $1 AND referrer IS NOT NULL AND referral_rewarded_at IS NULL RETURNING referrer"
+
+	c.isReferralLoop = "WITH RECURSIVE chain(username, depth) AS (" +
+		"SELECT referrer, 1 FROM users WHERE username = $1 AND referrer IS NOT NULL " +
+		"UNION ALL SELECT u.referrer, c.depth + 1 FROM users u JOIN chain c ON u.username = c.username " +
+		"WHERE u.referrer IS NOT NULL AND c.depth < $2) " +
+		"SELECT EXISTS (SELECT 1 FROM chain WHERE username = $1)"
+
+	return c
+}
+
+func (r *referralServiceRepo) MarkRewarded(ctx context.Context, referee string, now time.Time) (string, error) {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	var referrer string
+
+	err := r.storage.Executor(ctx).QueryRowContext(ctx, r.queries.markRewarded, referee, now).Scan(&referrer)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", err
+	}
+
+	return referrer, nil
+}
+
+func (r *referralServiceRepo) IsReferralLoop(ctx context.Context, referee string) (bool, error) {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	var loop bool
+
+	err := r.storage.Executor(ctx).QueryRowContext(ctx, r.queries.isReferralLoop, referee, maxReferralDepth).Scan(&loop)
+	if err != nil {
+		return false, err
+	}
+
+	return loop, nil
+}
+
+func NewReferralServiceRepo(storage *database.ServiceStorage) ReferralServiceRepo {
+	return &referralServiceRepo{
+		storage: storage,
+		queries: getReferralQueries(),
+	}
+}
@@ -0,0 +1,57 @@
+package repo
+
+import (
+	"context"
+	"time"
+)
+
+type memoryReferralServiceRepo struct {
+	store *MemoryStore
+}
+
+func (r *memoryReferralServiceRepo) MarkRewarded(ctx context.Context, referee string, now time.Time) (string, error) {
+	var referrer string
+
+	err := r.store.run(ctx, func(ctx context.Context) error {
+		u, ok := r.store.users[referee]
+		if !ok || len(u.user.Referrer) == 0 || u.referralRewardedAt != nil {
+			return nil
+		}
+
+		u.referralRewardedAt = &now
+		memorySet(ctx, r.store, r.store.users, referee, u)
+
+		referrer = u.user.Referrer
+
+		return nil
+	})
+
+	return referrer, err
+}
+
+func (r *memoryReferralServiceRepo) IsReferralLoop(ctx context.Context, referee string) (bool, error) {
+	loop := false
+
+	err := r.store.run(ctx, func(ctx context.Context) error {
+		username := r.store.users[referee].user.Referrer
+
+		for depth := 1; len(username) > 0 && depth <= maxReferralDepth; depth++ {
+			if username == referee {
+				loop = true
+				return nil
+			}
+
+			username = r.store.users[username].user.Referrer
+		}
+
+		return nil
+	})
+
+	return loop, err
+}
+
+func NewMemoryReferralServiceRepo(store *MemoryStore) ReferralServiceRepo {
+	return &memoryReferralServiceRepo{
+		store: store,
+	}
+}
@@ -0,0 +1,102 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/fuzzy-toozy/gophermart/internal/database"
+	"go.uber.org/zap"
+)
+
+// Repositories are the repositories of one storage backend
+type Repositories struct {
+	Storage     database.Storage
+	User        UserServiceRepo
+	Order       OrderServiceRepo
+	Withdrawal  WithdrawalServiceRepo
+	Balance     BalanceServiceRepo
+	Hold        HoldServiceRepo
+	Transfer    TransferServiceRepo
+	Idempotency IdempotencyServiceRepo
+	Tier        TierServiceRepo
+	Referral    ReferralServiceRepo
+	Campaign    CampaignServiceRepo
+	Reward      RewardServiceRepo
+	Promo       PromoServiceRepo
+	Webhook     WebhookServiceRepo
+	Outbox      OutboxServiceRepo
+	Health      HealthServiceRepo
+}
+
+func NewRepositories(storage *database.ServiceStorage) *Repositories {
+	return &Repositories{
+		Storage:     storage,
+		User:        NewUserServiceRepo(*storage),
+		Order:       NewOrderServiceRepo(storage),
+		Withdrawal:  NewWithdrawalServiceRepo(storage),
+		Balance:     NewBalanceServiceRepo(storage),
+		Hold:        NewHoldServiceRepo(storage),
+		Transfer:    NewTransferServiceRepo(storage),
+		Idempotency: NewIdempotencyServiceRepo(storage),
+		Tier:        NewTierServiceRepo(storage),
+		Referral:    NewReferralServiceRepo(storage),
+		Campaign:    NewCampaignServiceRepo(storage),
+		Reward:      NewRewardServiceRepo(storage),
+		Promo:       NewPromoServiceRepo(storage),
+		Webhook:     NewWebhookServiceRepo(storage),
+		Outbox:      NewOutboxServiceRepo(storage),
+		Health:      NewHealthServiceRepo(storage),
+	}
+}
+
+func NewMemoryRepositories(store *MemoryStore) *Repositories {
+	return &Repositories{
+		Storage:     store.storage,
+		User:        NewMemoryUserServiceRepo(store),
+		Order:       NewMemoryOrderServiceRepo(store),
+		Withdrawal:  NewMemoryWithdrawalServiceRepo(store),
+		Balance:     NewMemoryBalanceServiceRepo(store),
+		Hold:        NewMemoryHoldServiceRepo(store),
+		Transfer:    NewMemoryTransferServiceRepo(store),
+		Idempotency: NewMemoryIdempotencyServiceRepo(store),
+		Tier:        NewMemoryTierServiceRepo(store),
+		Referral:    NewMemoryReferralServiceRepo(store),
+		Campaign:    NewMemoryCampaignServiceRepo(store),
+		Reward:      NewMemoryRewardServiceRepo(store),
+		Promo:       NewMemoryPromoServiceRepo(store),
+		Webhook:     NewMemoryWebhookServiceRepo(store),
+		Outbox:      NewMemoryOutboxServiceRepo(store),
+		Health:      NewMemoryHealthServiceRepo(store),
+	}
+}
+
+// OpenRepositories opens the storage selected by the connection string. The database is
+// waited for and migrated if autoMigrate is set.
+func OpenRepositories(ctx context.Context, config database.DBConfig, autoMigrate bool,
+	logger *zap.SugaredLogger) (*Repositories, error) {
+	if database.IsMemoryURI(config.ConnURI) {
+		logger.Warnf("Using in-memory storage, all data is lost on exit")
+		return NewMemoryRepositories(NewMemoryStore(database.NewMemoryStorage())), nil
+	}
+
+	storage, err := database.NewServiceStorage(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to setup repository: %v", err)
+	}
+
+	err = storage.WaitReady(ctx, logger)
+	if err != nil {
+		storage.Close()
+		return nil, err
+	}
+
+	if autoMigrate {
+		err = database.Migrate(config.ConnURI)
+		if err != nil {
+			storage.Close()
+			return nil, fmt.Errorf("failed db migration: %v", err)
+		}
+	}
+
+	return NewRepositories(storage), nil
+}
@@ -0,0 +1,248 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fuzzy-toozy/gophermart/internal/database"
+	"github.com/fuzzy-toozy/gophermart/internal/models"
+)
+
+type RewardServiceRepo interface {
+	GetRewardByID(ctx context.Context, id string) (*models.Reward, error)
+	GetAllRewards(ctx context.Context) ([]models.Reward, error)
+	GetAllUserRedemptions(ctx context.Context, username string) ([]models.Redemption, error)
+
+	AddNewReward(ctx context.Context, reward *models.Reward) error
+	AddNewRedemption(ctx context.Context, redemption *models.Redemption) error
+
+	UpdateReward(ctx context.Context, reward *models.Reward) error
+	// TakeFromStock decrements the reward stock and returns the reward
+	TakeFromStock(ctx context.Context, id string, now time.Time) (*models.Reward, error)
+
+	DeleteReward(ctx context.Context, id string) error
+}
+
+type rewardQueryConfig struct {
+	getRewardByID         string
+	getAllRewards         string
+	getAllUserRedemptions string
+	addNewReward          string
+	addNewRedemption      string
+	updateReward          string
+	takeFromStock         string
+	deleteReward          string
+}
+
+type rewardServiceRepo struct {
+	storage *database.ServiceStorage
+	queries rewardQueryConfig
+}
+
+func getRewardQueries() rewardQueryConfig {
+	c := rewardQueryConfig{}
+
+	c.getRewardByID = "SELECT id, name, cost, stock, created_at, updated_at FROM rewards WHERE id = $1"
+
+	c.getAllRewards = "SELECT id, name, cost, stock, created_at, updated_at FROM rewards ORDER BY cost"
+
+	c.getAllUserRedemptions = "SELECT id, username, coalesce(reward_id::TEXT, ''), reward_name, cost, created_at " +
+		"FROM redemptions WHERE username = $1 ORDER BY created_at DESC"
+
+	c.addNewReward = "INSERT INTO rewards(name, cost, stock, created_at, updated_at) " +
+		"VALUES ($1, $2, $3, $4, $5) RETURNING id"
+
+	c.addNewRedemption = "INSERT INTO redemptions(username, reward_id, reward_name, cost, created_at) " +
+		"VALUES ($1, $2, $3, $4, $5) RETURNING id"
+
+	c.updateReward = "UPDATE rewards SET name = $1, cost = $2, stock = $3, updated_at = $4 WHERE id = $5 RETURNING created_at"
+
+	// Concurrent redemptions can't take more items than there are in stock
+	c.takeFromStock = "UPDATE rewards SET stock = stock - 1, updated_at = $2 WHERE id = $1 AND stock > 0 " +
+		"RETURNING id, name, cost, stock, created_at, updated_at"
+
+	c.deleteReward = "DELETE FROM rewards WHERE id = $1"
+
+	return c
+}
+
+func scanReward(row rowScanner, reward *models.Reward) error {
+	return row.Scan(&reward.ID, &reward.Name, &reward.Cost, &reward.Stock, &reward.CreatedAt, &reward.UpdatedAt)
+}
+
+func (r *rewardServiceRepo) GetRewardByID(ctx context.Context, id string) (*models.Reward, error) {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	reward := models.Reward{}
+
+	row := r.storage.Executor(ctx).QueryRowContext(ctx, r.queries.getRewardByID, id)
+
+	err := scanReward(row, &reward)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return &reward, err
+	}
+
+	return &reward, nil
+}
+
+func (r *rewardServiceRepo) GetAllRewards(ctx context.Context) ([]models.Reward, error) {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	row, err := r.storage.Executor(ctx).QueryContext(ctx, r.queries.getAllRewards)
+	if err != nil {
+		return nil, err
+	}
+
+	defer row.Close()
+
+	result := make([]models.Reward, 0)
+
+	for row.Next() {
+		reward := models.Reward{}
+		err := scanReward(row, &reward)
+		if err != nil {
+			return nil, fmt.Errorf("row scan error: %w", err)
+		}
+
+		result = append(result, reward)
+	}
+
+	if err = row.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate all rewards: %w", err)
+	}
+
+	return result, nil
+}
+
+func (r *rewardServiceRepo) GetAllUserRedemptions(ctx context.Context, username string) ([]models.Redemption, error) {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	row, err := r.storage.Executor(ctx).QueryContext(ctx, r.queries.getAllUserRedemptions, username)
+	if err != nil {
+		return nil, err
+	}
+
+	defer row.Close()
+
+	result := make([]models.Redemption, 0)
+
+	for row.Next() {
+		rd := models.Redemption{}
+		err := row.Scan(&rd.ID, &rd.Username, &rd.RewardID, &rd.RewardName, &rd.Cost, &rd.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("row scan error: %w", err)
+		}
+
+		result = append(result, rd)
+	}
+
+	if err = row.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate all redemptions: %w", err)
+	}
+
+	return result, nil
+}
+
+func (r *rewardServiceRepo) AddNewReward(ctx context.Context, reward *models.Reward) error {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	row := r.storage.Executor(ctx).QueryRowContext(ctx, r.queries.addNewReward,
+		reward.Name, reward.Cost, reward.Stock, reward.CreatedAt, reward.UpdatedAt)
+
+	return row.Scan(&reward.ID)
+}
+
+func (r *rewardServiceRepo) AddNewRedemption(ctx context.Context, redemption *models.Redemption) error {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	row := r.storage.Executor(ctx).QueryRowContext(ctx, r.queries.addNewRedemption,
+		redemption.Username, redemption.RewardID, redemption.RewardName, redemption.Cost, redemption.CreatedAt)
+
+	return row.Scan(&redemption.ID)
+}
+
+func (r *rewardServiceRepo) UpdateReward(ctx context.Context, reward *models.Reward) error {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	row := r.storage.Executor(ctx).QueryRowContext(ctx, r.queries.updateReward,
+		reward.Name, reward.Cost, reward.Stock, reward.UpdatedAt, reward.ID)
+
+	err := row.Scan(&reward.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrRewardNotFound
+	}
+
+	return err
+}
+
+func (r *rewardServiceRepo) TakeFromStock(ctx context.Context, id string, now time.Time) (*models.Reward, error) {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	reward := models.Reward{}
+
+	row := r.storage.Executor(ctx).QueryRowContext(ctx, r.queries.takeFromStock, id, now)
+
+	err := scanReward(row, &reward)
+	if err == nil {
+		return &reward, nil
+	}
+
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	existing, err := r.GetRewardByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(existing.ID) == 0 {
+		return nil, ErrRewardNotFound
+	}
+
+	return nil, ErrRewardOutOfStock
+}
+
+func (r *rewardServiceRepo) DeleteReward(ctx context.Context, id string) error {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	res, err := r.storage.Executor(ctx).ExecContext(ctx, r.queries.deleteReward, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected < 1 {
+		return ErrRewardNotFound
+	}
+
+	return nil
+}
+
+func NewRewardServiceRepo(storage *database.ServiceStorage) RewardServiceRepo {
+	r := rewardServiceRepo{
+		storage: storage,
+		queries: getRewardQueries(),
+	}
+
+	if storage.Dialect() == database.DialectSQLite {
+		r.queries = getSQLiteRewardQueries()
+	}
+
+	return &r
+}
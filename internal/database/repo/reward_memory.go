@@ -0,0 +1,169 @@
+package repo
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/fuzzy-toozy/gophermart/internal/models"
+)
+
+type memoryRewardServiceRepo struct {
+	store *MemoryStore
+}
+
+func (r *memoryRewardServiceRepo) GetRewardByID(ctx context.Context, id string) (*models.Reward, error) {
+	reward := models.Reward{}
+
+	err := r.store.run(ctx, func(ctx context.Context) error {
+		reward = r.store.rewards[id]
+		return nil
+	})
+
+	return &reward, err
+}
+
+func (r *memoryRewardServiceRepo) GetAllRewards(ctx context.Context) ([]models.Reward, error) {
+	result := make([]models.Reward, 0)
+
+	err := r.store.run(ctx, func(ctx context.Context) error {
+		for _, reward := range r.store.rewards {
+			result = append(result, reward)
+		}
+
+		return nil
+	})
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Cost < result[j].Cost
+	})
+
+	return result, err
+}
+
+func (r *memoryRewardServiceRepo) GetAllUserRedemptions(ctx context.Context, username string) ([]models.Redemption, error) {
+	result := make([]models.Redemption, 0)
+
+	err := r.store.run(ctx, func(ctx context.Context) error {
+		for _, rd := range r.store.redemptions {
+			if rd.Username != username {
+				continue
+			}
+
+			// Redemptions outlive deleted rewards
+			if _, ok := r.store.rewards[rd.RewardID]; !ok {
+				rd.RewardID = ""
+			}
+
+			result = append(result, rd)
+		}
+
+		return nil
+	})
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].CreatedAt.After(result[j].CreatedAt)
+	})
+
+	return result, err
+}
+
+func (r *memoryRewardServiceRepo) AddNewReward(ctx context.Context, reward *models.Reward) error {
+	return r.store.run(ctx, func(ctx context.Context) error {
+		id, err := newMemoryID()
+		if err != nil {
+			return err
+		}
+
+		stored := *reward
+		stored.ID = id
+		memorySet(ctx, r.store, r.store.rewards, id, stored)
+
+		reward.ID = id
+
+		return nil
+	})
+}
+
+func (r *memoryRewardServiceRepo) AddNewRedemption(ctx context.Context, redemption *models.Redemption) error {
+	return r.store.run(ctx, func(ctx context.Context) error {
+		if err := r.store.userExists(redemption.Username); err != nil {
+			return err
+		}
+
+		id, err := newMemoryID()
+		if err != nil {
+			return err
+		}
+
+		stored := *redemption
+		stored.ID = id
+		memoryAppend(ctx, r.store, &r.store.redemptions, stored)
+
+		redemption.ID = id
+
+		return nil
+	})
+}
+
+func (r *memoryRewardServiceRepo) UpdateReward(ctx context.Context, reward *models.Reward) error {
+	return r.store.run(ctx, func(ctx context.Context) error {
+		stored, ok := r.store.rewards[reward.ID]
+		if !ok {
+			return ErrRewardNotFound
+		}
+
+		updated := *reward
+		updated.CreatedAt = stored.CreatedAt
+		memorySet(ctx, r.store, r.store.rewards, reward.ID, updated)
+
+		reward.CreatedAt = stored.CreatedAt
+
+		return nil
+	})
+}
+
+func (r *memoryRewardServiceRepo) TakeFromStock(ctx context.Context, id string, now time.Time) (*models.Reward, error) {
+	reward := models.Reward{}
+
+	err := r.store.run(ctx, func(ctx context.Context) error {
+		stored, ok := r.store.rewards[id]
+		if !ok {
+			return ErrRewardNotFound
+		}
+
+		if stored.Stock <= 0 {
+			return ErrRewardOutOfStock
+		}
+
+		stored.Stock--
+		stored.UpdatedAt = now
+		memorySet(ctx, r.store, r.store.rewards, id, stored)
+
+		reward = stored
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &reward, nil
+}
+
+func (r *memoryRewardServiceRepo) DeleteReward(ctx context.Context, id string) error {
+	return r.store.run(ctx, func(ctx context.Context) error {
+		if !memoryDelete(ctx, r.store, r.store.rewards, id) {
+			return ErrRewardNotFound
+		}
+
+		return nil
+	})
+}
+
+func NewMemoryRewardServiceRepo(store *MemoryStore) RewardServiceRepo {
+	return &memoryRewardServiceRepo{
+		store: store,
+	}
+}
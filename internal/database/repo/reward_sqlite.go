@@ -0,0 +1,10 @@
+package repo
+
+func getSQLiteRewardQueries() rewardQueryConfig {
+	c := getRewardQueries()
+
+	c.getAllUserRedemptions = "SELECT id, username, coalesce(reward_id, ''), reward_name, cost, created_at " +
+		"FROM redemptions WHERE username = $1 ORDER BY created_at DESC"
+
+	return c
+}
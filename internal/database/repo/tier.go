@@ -0,0 +1,139 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/fuzzy-toozy/gophermart/internal/database"
+	"github.com/fuzzy-toozy/gophermart/internal/models"
+)
+
+type TierServiceRepo interface {
+	GetUserTier(ctx context.Context, username string) (*models.UserTier, error)
+	// GetAllUserTiers returns tiers of all users with points accrued for orders during their lifetime
+	GetAllUserTiers(ctx context.Context) ([]models.UserTier, error)
+
+	// ChangeTier sets the new user tier and records the change
+	ChangeTier(ctx context.Context, change *models.TierChange) error
+}
+
+type tierQueryConfig struct {
+	getUserTier     string
+	getAllUserTiers string
+	updateTier      string
+	addTierChange   string
+}
+
+type tierServiceRepo struct {
+	storage *database.ServiceStorage
+	queries tierQueryConfig
+}
+
+// Lifetime accrual is the sum of accruals credited to the user wallet
+const lifetimeAccrued = "SELECT u.username, u.tier, coalesce(sum(p.amount), 0) FROM users u " +
+	"LEFT JOIN ledger_accounts a ON a.username = u.username " +
+	"LEFT JOIN ledger_postings p ON p.account = a.code AND EXISTS " +
+	"(SELECT 1 FROM ledger_transactions t WHERE t.id = p.transaction_id AND t.operation = 'ACCRUAL')"
+
+func getTierQueries() tierQueryConfig {
+	c := tierQueryConfig{}
+
+	c.getUserTier = lifetimeAccrued + " WHERE u.username = $1 GROUP BY u.username, u.tier"
+
+	c.getAllUserTiers = lifetimeAccrued + " GROUP BY u.username, u.tier"
+
+	c.updateTier = "UPDATE users SET tier = $1 WHERE username = $2 AND tier = $3"
+
+	c.addTierChange = "INSERT INTO tier_changes(username, from_tier, to_tier, lifetime_accrued, changed_at) " +
+		"VALUES ($1, $2, $3, $4, $5)"
+
+	return c
+}
+
+func (r *tierServiceRepo) GetUserTier(ctx context.Context, username string) (*models.UserTier, error) {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	tier := models.UserTier{}
+
+	row := r.storage.Executor(ctx).QueryRowContext(ctx, r.queries.getUserTier, username)
+
+	err := row.Scan(&tier.Username, &tier.Tier, &tier.LifetimeAccrued)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return &tier, err
+	}
+
+	return &tier, nil
+}
+
+func (r *tierServiceRepo) GetAllUserTiers(ctx context.Context) ([]models.UserTier, error) {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	row, err := r.storage.Executor(ctx).QueryContext(ctx, r.queries.getAllUserTiers)
+	if err != nil {
+		return nil, err
+	}
+
+	defer row.Close()
+
+	result := make([]models.UserTier, 0)
+
+	for row.Next() {
+		tier := models.UserTier{}
+		err := row.Scan(&tier.Username, &tier.Tier, &tier.LifetimeAccrued)
+		if err != nil {
+			return nil, fmt.Errorf("row scan error: %w", err)
+		}
+
+		result = append(result, tier)
+	}
+
+	if err = row.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate all user tiers: %w", err)
+	}
+
+	return result, nil
+}
+
+func (r *tierServiceRepo) ChangeTier(ctx context.Context, change *models.TierChange) error {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	callback := func(ctx context.Context) error {
+		res, err := r.storage.Executor(ctx).ExecContext(ctx, r.queries.updateTier,
+			change.To, change.Username, change.From)
+		if err != nil {
+			return fmt.Errorf("failed to update tier of user '%v': %w", change.Username, err)
+		}
+
+		rowsAffected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		// The tier was changed concurrently
+		if rowsAffected < 1 {
+			return nil
+		}
+
+		_, err = r.storage.Executor(ctx).ExecContext(ctx, r.queries.addTierChange,
+			change.Username, change.From, change.To, change.LifetimeAccrued, change.ChangedAt)
+		if err != nil {
+			return fmt.Errorf("failed to add tier change of user '%v': %w", change.Username, err)
+		}
+
+		return nil
+	}
+
+	return r.storage.RunInTransaction(ctx, callback)
+}
+
+func NewTierServiceRepo(storage *database.ServiceStorage) TierServiceRepo {
+	return &tierServiceRepo{
+		storage: storage,
+		queries: getTierQueries(),
+	}
+}
@@ -0,0 +1,97 @@
+package repo
+
+import (
+	"context"
+	"sort"
+
+	"github.com/fuzzy-toozy/gophermart/internal/models"
+)
+
+type memoryTierServiceRepo struct {
+	store *MemoryStore
+}
+
+// lifetimeAccrued sums accruals credited to wallets of every user
+func (r *memoryTierServiceRepo) lifetimeAccrued() map[string]float64 {
+	accrued := make(map[string]float64)
+
+	for _, t := range r.store.ledger {
+		if t.operation != operationAccrual {
+			continue
+		}
+
+		for _, p := range t.postings {
+			if len(p.username) > 0 {
+				accrued[p.username] += p.amount
+			}
+		}
+	}
+
+	return accrued
+}
+
+func (r *memoryTierServiceRepo) GetUserTier(ctx context.Context, username string) (*models.UserTier, error) {
+	tier := models.UserTier{}
+
+	err := r.store.run(ctx, func(ctx context.Context) error {
+		u, ok := r.store.users[username]
+		if !ok {
+			return nil
+		}
+
+		tier.Username = username
+		tier.Tier = u.tier
+		tier.LifetimeAccrued = r.lifetimeAccrued()[username]
+
+		return nil
+	})
+
+	return &tier, err
+}
+
+func (r *memoryTierServiceRepo) GetAllUserTiers(ctx context.Context) ([]models.UserTier, error) {
+	result := make([]models.UserTier, 0)
+
+	err := r.store.run(ctx, func(ctx context.Context) error {
+		accrued := r.lifetimeAccrued()
+
+		for username, u := range r.store.users {
+			result = append(result, models.UserTier{
+				Username:        username,
+				Tier:            u.tier,
+				LifetimeAccrued: accrued[username],
+			})
+		}
+
+		return nil
+	})
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Username < result[j].Username
+	})
+
+	return result, err
+}
+
+func (r *memoryTierServiceRepo) ChangeTier(ctx context.Context, change *models.TierChange) error {
+	return r.store.run(ctx, func(ctx context.Context) error {
+		u, ok := r.store.users[change.Username]
+
+		// The tier was changed concurrently
+		if !ok || u.tier != change.From {
+			return nil
+		}
+
+		u.tier = change.To
+		memorySet(ctx, r.store, r.store.users, change.Username, u)
+		memoryAppend(ctx, r.store, &r.store.tierChanges, *change)
+
+		return nil
+	})
+}
+
+func NewMemoryTierServiceRepo(store *MemoryStore) TierServiceRepo {
+	return &memoryTierServiceRepo{
+		store: store,
+	}
+}
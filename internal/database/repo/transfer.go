@@ -0,0 +1,112 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fuzzy-toozy/gophermart/internal/database"
+	"github.com/fuzzy-toozy/gophermart/internal/models"
+)
+
+type TransferServiceRepo interface {
+	// GetAllUserTransfers returns transfers sent and received by the user
+	GetAllUserTransfers(ctx context.Context, username string) ([]models.Transfer, error)
+	GetSentSum(ctx context.Context, username string, since time.Time) (float64, error)
+
+	AddNewTransfer(ctx context.Context, transfer *models.Transfer) error
+}
+
+type transferQueryConfig struct {
+	getAllUserTransfers string
+	getSentSum          string
+	addNewTransfer      string
+}
+
+type transferServiceRepo struct {
+	storage *database.ServiceStorage
+	queries transferQueryConfig
+}
+
+func getTransferQueries() transferQueryConfig {
+	c := transferQueryConfig{}
+
+	c.getAllUserTransfers = "SELECT id, sender, recipient, sum, created_at FROM transfers " +
+		"WHERE sender = $1 OR recipient = $1 ORDER BY created_at DESC"
+
+	c.getSentSum = "SELECT coalesce(sum(sum), 0) FROM transfers WHERE sender = $1 AND created_at >= $2"
+
+	c.addNewTransfer = "INSERT INTO transfers(sender, recipient, sum, created_at) VALUES ($1, $2, $3, $4) RETURNING id"
+
+	return c
+}
+
+func (r *transferServiceRepo) GetAllUserTransfers(ctx context.Context, username string) ([]models.Transfer, error) {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	row, err := r.storage.Executor(ctx).QueryContext(ctx, r.queries.getAllUserTransfers, username)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer row.Close()
+
+	result := make([]models.Transfer, 0)
+
+	for row.Next() {
+		transfer := models.Transfer{}
+		err := row.Scan(&transfer.ID, &transfer.Sender, &transfer.Recipient, &transfer.Sum, &transfer.CreatedAt)
+
+		if err != nil {
+			return nil, fmt.Errorf("row scan error: %w", err)
+		}
+
+		transfer.Direction = models.TransferOUT
+		if transfer.Recipient == username {
+			transfer.Direction = models.TransferIN
+		}
+
+		result = append(result, transfer)
+	}
+
+	if err = row.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate all transfers: %w", err)
+	}
+
+	return result, nil
+}
+
+func (r *transferServiceRepo) GetSentSum(ctx context.Context, username string, since time.Time) (float64, error) {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	var sent float64
+
+	err := r.storage.Executor(ctx).QueryRowContext(ctx, r.queries.getSentSum, username, since).Scan(&sent)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+
+	return sent, nil
+}
+
+func (r *transferServiceRepo) AddNewTransfer(ctx context.Context, transfer *models.Transfer) error {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	row := r.storage.Executor(ctx).QueryRowContext(ctx, r.queries.addNewTransfer,
+		transfer.Sender, transfer.Recipient, transfer.Sum, transfer.CreatedAt)
+
+	return row.Scan(&transfer.ID)
+}
+
+func NewTransferServiceRepo(storage *database.ServiceStorage) TransferServiceRepo {
+	return &transferServiceRepo{
+		storage: storage,
+		queries: getTransferQueries(),
+	}
+}
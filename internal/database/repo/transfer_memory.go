@@ -0,0 +1,88 @@
+package repo
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/fuzzy-toozy/gophermart/internal/models"
+)
+
+type memoryTransferServiceRepo struct {
+	store *MemoryStore
+}
+
+func (r *memoryTransferServiceRepo) GetAllUserTransfers(ctx context.Context, username string) ([]models.Transfer, error) {
+	result := make([]models.Transfer, 0)
+
+	err := r.store.run(ctx, func(ctx context.Context) error {
+		for _, transfer := range r.store.transfers {
+			if transfer.Sender != username && transfer.Recipient != username {
+				continue
+			}
+
+			transfer.Direction = models.TransferOUT
+			if transfer.Recipient == username {
+				transfer.Direction = models.TransferIN
+			}
+
+			result = append(result, transfer)
+		}
+
+		return nil
+	})
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].CreatedAt.After(result[j].CreatedAt)
+	})
+
+	return result, err
+}
+
+func (r *memoryTransferServiceRepo) GetSentSum(ctx context.Context, username string, since time.Time) (float64, error) {
+	var sent float64
+
+	err := r.store.run(ctx, func(ctx context.Context) error {
+		for _, transfer := range r.store.transfers {
+			if transfer.Sender == username && !transfer.CreatedAt.Before(since) {
+				sent += transfer.Sum
+			}
+		}
+
+		return nil
+	})
+
+	return sent, err
+}
+
+func (r *memoryTransferServiceRepo) AddNewTransfer(ctx context.Context, transfer *models.Transfer) error {
+	return r.store.run(ctx, func(ctx context.Context) error {
+		if err := r.store.userExists(transfer.Sender); err != nil {
+			return err
+		}
+
+		if err := r.store.userExists(transfer.Recipient); err != nil {
+			return err
+		}
+
+		id, err := newMemoryID()
+		if err != nil {
+			return err
+		}
+
+		stored := *transfer
+		stored.ID = id
+		stored.Direction = ""
+		memoryAppend(ctx, r.store, &r.store.transfers, stored)
+
+		transfer.ID = id
+
+		return nil
+	})
+}
+
+func NewMemoryTransferServiceRepo(store *MemoryStore) TransferServiceRepo {
+	return &memoryTransferServiceRepo{
+		store: store,
+	}
+}
@@ -12,12 +12,17 @@ import (
 
 type UserServiceRepo interface {
 	GetUserByName(ctx context.Context, username string) (models.User, error)
+	GetUserByReferralCode(ctx context.Context, code string) (models.User, error)
+	GetReferralInfo(ctx context.Context, username string) (*models.ReferralInfo, error)
+	// AddUser returns ErrUserExists if the name is taken and ErrReferralCodeExists if the code is
 	AddUser(ctx context.Context, user *models.User) error
 }
 
 type queryConfig struct {
-	addUserQuery string
-	getUserQuery string
+	addUserQuery         string
+	getUserQuery         string
+	getUserByCodeQuery   string
+	getReferralInfoQuery string
 }
 
 type userServiceRepo struct {
@@ -28,18 +33,26 @@ type userServiceRepo struct {
 func getQueries() queryConfig {
 	c := queryConfig{}
 
-	c.addUserQuery = "INSERT INTO users (username, user_password) values ($1, $2)"
-	c.getUserQuery = "SELECT username, user_password FROM users WHERE username = $1"
+	c.addUserQuery = "INSERT INTO users (username, user_password, referral_code, referrer) values ($1, $2, $3, $4) " +
+		"ON CONFLICT DO NOTHING"
+	c.getUserQuery = "SELECT username, user_password, referral_code, coalesce(referrer, '') FROM users WHERE username = $1"
+	c.getUserByCodeQuery = "SELECT username, user_password, referral_code, coalesce(referrer, '') " +
+		"FROM users WHERE referral_code = $1"
+	c.getReferralInfoQuery = "SELECT u.referral_code, count(r.username), count(r.referral_rewarded_at) " +
+		"FROM users u LEFT JOIN users r ON r.referrer = u.username WHERE u.username = $1 GROUP BY u.referral_code"
 
 	return c
 }
 
-func (r *userServiceRepo) GetUserByName(ctx context.Context, username string) (models.User, error) {
-	res := r.storage.DB.QueryRowContext(ctx, r.queries.getUserQuery, username)
+func (r *userServiceRepo) getUser(ctx context.Context, query string, arg string) (models.User, error) {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	res := r.storage.Executor(ctx).QueryRowContext(ctx, query, arg)
 
 	user := models.User{}
 
-	err := res.Scan(&user.Username, &user.Password)
+	err := res.Scan(&user.Username, &user.Password, &user.ReferralCode, &user.Referrer)
 	if err != nil && !errors.Is(err, sql.ErrNoRows) {
 		return user, err
 	}
@@ -47,9 +60,62 @@ func (r *userServiceRepo) GetUserByName(ctx context.Context, username string) (m
 	return user, nil
 }
 
+func (r *userServiceRepo) GetUserByName(ctx context.Context, username string) (models.User, error) {
+	return r.getUser(ctx, r.queries.getUserQuery, username)
+}
+
+func (r *userServiceRepo) GetUserByReferralCode(ctx context.Context, code string) (models.User, error) {
+	return r.getUser(ctx, r.queries.getUserByCodeQuery, code)
+}
+
+func (r *userServiceRepo) GetReferralInfo(ctx context.Context, username string) (*models.ReferralInfo, error) {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	info := models.ReferralInfo{}
+
+	res := r.storage.Executor(ctx).QueryRowContext(ctx, r.queries.getReferralInfoQuery, username)
+
+	err := res.Scan(&info.Code, &info.Invited, &info.Rewarded)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return &info, err
+	}
+
+	return &info, nil
+}
+
 func (r *userServiceRepo) AddUser(ctx context.Context, user *models.User) error {
-	_, err := r.storage.DB.ExecContext(ctx, r.queries.addUserQuery, user.Username, common.EncryptStringMD5(user.Password))
-	return err
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	referrer := sql.NullString{String: user.Referrer, Valid: len(user.Referrer) > 0}
+
+	res, err := r.storage.Executor(ctx).ExecContext(ctx, r.queries.addUserQuery,
+		user.Username, common.EncryptStringMD5(user.Password), user.ReferralCode, referrer)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected > 0 {
+		return nil
+	}
+
+	// Either the name or the referral code is taken
+	existing, err := r.GetUserByName(ctx, user.Username)
+	if err != nil {
+		return err
+	}
+
+	if len(existing.Username) > 0 {
+		return ErrUserExists
+	}
+
+	return ErrReferralCodeExists
 }
 
 func NewUserServiceRepo(storage database.ServiceStorage) UserServiceRepo {
@@ -0,0 +1,106 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/fuzzy-toozy/gophermart/internal/common"
+	"github.com/fuzzy-toozy/gophermart/internal/models"
+)
+
+type memoryUserServiceRepo struct {
+	store *MemoryStore
+}
+
+func (r *memoryUserServiceRepo) GetUserByName(ctx context.Context, username string) (models.User, error) {
+	user := models.User{}
+
+	err := r.store.run(ctx, func(ctx context.Context) error {
+		user = r.store.users[username].user
+		return nil
+	})
+
+	return user, err
+}
+
+func (r *memoryUserServiceRepo) GetUserByReferralCode(ctx context.Context, code string) (models.User, error) {
+	user := models.User{}
+
+	err := r.store.run(ctx, func(ctx context.Context) error {
+		if len(code) == 0 {
+			return nil
+		}
+
+		for _, u := range r.store.users {
+			if u.user.ReferralCode == code {
+				user = u.user
+				break
+			}
+		}
+
+		return nil
+	})
+
+	return user, err
+}
+
+func (r *memoryUserServiceRepo) GetReferralInfo(ctx context.Context, username string) (*models.ReferralInfo, error) {
+	info := models.ReferralInfo{}
+
+	err := r.store.run(ctx, func(ctx context.Context) error {
+		u, ok := r.store.users[username]
+		if !ok {
+			return nil
+		}
+
+		info.Code = u.user.ReferralCode
+
+		for _, referee := range r.store.users {
+			if referee.user.Referrer != username {
+				continue
+			}
+
+			info.Invited++
+			if referee.referralRewardedAt != nil {
+				info.Rewarded++
+			}
+		}
+
+		return nil
+	})
+
+	return &info, err
+}
+
+func (r *memoryUserServiceRepo) AddUser(ctx context.Context, user *models.User) error {
+	return r.store.run(ctx, func(ctx context.Context) error {
+		if _, ok := r.store.users[user.Username]; ok {
+			return ErrUserExists
+		}
+
+		if len(user.Referrer) > 0 {
+			if err := r.store.userExists(user.Referrer); err != nil {
+				return err
+			}
+		}
+
+		for _, u := range r.store.users {
+			if len(user.ReferralCode) > 0 && u.user.ReferralCode == user.ReferralCode {
+				return ErrReferralCodeExists
+			}
+		}
+
+		stored := *user
+		stored.Password = common.EncryptStringMD5(user.Password)
+		stored.ReferrerCode = ""
+
+		memorySet(ctx, r.store, r.store.users, user.Username, memoryUser{user: stored, tier: models.TierBRONZE})
+
+		return nil
+	})
+}
+
+func NewMemoryUserServiceRepo(store *MemoryStore) UserServiceRepo {
+	return &memoryUserServiceRepo{
+		store: store,
+	}
+}
@@ -0,0 +1,297 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fuzzy-toozy/gophermart/internal/database"
+	"github.com/fuzzy-toozy/gophermart/internal/models"
+)
+
+type WebhookServiceRepo interface {
+	GetSubscriptionByID(ctx context.Context, id string) (*models.WebhookSubscription, error)
+	GetAllSubscriptions(ctx context.Context) ([]models.WebhookSubscription, error)
+	// GetDeliveryLog returns delivery attempts of the subscription, latest first
+	GetDeliveryLog(ctx context.Context, subscriptionID string, limit int) ([]models.WebhookAttempt, error)
+
+	AddNewSubscription(ctx context.Context, subscription *models.WebhookSubscription) error
+	// EnqueueEvent adds deliveries of the event to all subscriptions interested in it.
+	// Deliveries already added for the event are not added again.
+	EnqueueEvent(ctx context.Context, event *models.Event, payload []byte) (int64, error)
+
+	// ClaimDueDeliveries returns pending deliveries due at the moment and postpones them
+	// until lease ends, so other dispatchers don't send them at the same time
+	ClaimDueDeliveries(ctx context.Context, now time.Time, lease time.Time, limit int) ([]models.WebhookDelivery, error)
+	// RecordAttempt logs the delivery attempt and sets the delivery status
+	RecordAttempt(ctx context.Context, attempt *models.WebhookAttempt, nextAttemptAt time.Time) error
+
+	DeleteSubscription(ctx context.Context, id string) error
+}
+
+type webhookQueryConfig struct {
+	getSubscriptionByID string
+	getAllSubscriptions string
+	getDeliveryLog      string
+	addNewSubscription  string
+	enqueueEvent        string
+	claimDueDeliveries  string
+	addLogRecord        string
+	updateDelivery      string
+	deleteSubscription  string
+}
+
+type webhookServiceRepo struct {
+	storage *database.ServiceStorage
+	queries webhookQueryConfig
+}
+
+func getWebhookQueries() webhookQueryConfig {
+	c := webhookQueryConfig{}
+
+	c.getSubscriptionByID = "SELECT id, url, secret, events, created_at FROM webhook_subscriptions WHERE id = $1"
+
+	c.getAllSubscriptions = "SELECT id, url, secret, events, created_at FROM webhook_subscriptions ORDER BY created_at"
+
+	c.getDeliveryLog = "SELECT d.id, d.event_id, d.event_type, d.status, l.attempt, l.response_status, l.error, l.attempted_at " +
+		"FROM webhook_delivery_log l JOIN webhook_deliveries d ON d.id = l.delivery_id " +
+		"WHERE d.subscription_id = $1 ORDER BY l.attempted_at DESC LIMIT $2"
+
+	c.addNewSubscription = "INSERT INTO webhook_subscriptions(url, secret, events, created_at) " +
+		"VALUES ($1, $2, $3, $4) RETURNING id"
+
+	c.enqueueEvent = "INSERT INTO webhook_deliveries(subscription_id, event_id, event_type, payload, status, " +
+		"next_attempt_at, created_at, updated_at) " +
+		"SELECT id, $1::uuid, $2::VARCHAR, $3::BYTEA, 'PENDING', $4::TIMESTAMP WITH TIME ZONE, $4, $4 " +
+		"FROM webhook_subscriptions s WHERE (events = '' OR $2 = ANY (string_to_array(events, ','))) " +
+		"AND NOT EXISTS (SELECT 1 FROM webhook_deliveries d WHERE d.subscription_id = s.id AND d.event_id = $1)"
+
+	c.claimDueDeliveries = "UPDATE webhook_deliveries d SET next_attempt_at = $2, updated_at = $1 " +
+		"FROM webhook_subscriptions s WHERE s.id = d.subscription_id AND d.id IN (" +
+		"SELECT id FROM webhook_deliveries WHERE status = 'PENDING' AND next_attempt_at <= $1 " +
+		"ORDER BY next_attempt_at LIMIT $3 FOR UPDATE SKIP LOCKED) " +
+		"RETURNING d.id, d.subscription_id, s.url, s.secret, d.event_id, d.event_type, d.payload, d.attempts"
+
+	c.addLogRecord = "INSERT INTO webhook_delivery_log(delivery_id, attempt, response_status, error, attempted_at) " +
+		"VALUES ($1, $2, $3, $4, $5)"
+
+	c.updateDelivery = "UPDATE webhook_deliveries SET status = $1, attempts = $2, next_attempt_at = $3, updated_at = $4 " +
+		"WHERE id = $5"
+
+	c.deleteSubscription = "DELETE FROM webhook_subscriptions WHERE id = $1"
+
+	return c
+}
+
+func scanSubscription(row rowScanner, subscription *models.WebhookSubscription) error {
+	var events string
+	err := row.Scan(&subscription.ID, &subscription.URL, &subscription.Secret, &events, &subscription.CreatedAt)
+	subscription.Events = make([]string, 0)
+	if len(events) > 0 {
+		subscription.Events = strings.Split(events, ",")
+	}
+	return err
+}
+
+func (r *webhookServiceRepo) GetSubscriptionByID(ctx context.Context, id string) (*models.WebhookSubscription, error) {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	subscription := models.WebhookSubscription{}
+
+	row := r.storage.Executor(ctx).QueryRowContext(ctx, r.queries.getSubscriptionByID, id)
+
+	err := scanSubscription(row, &subscription)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return &subscription, err
+	}
+
+	return &subscription, nil
+}
+
+func (r *webhookServiceRepo) GetAllSubscriptions(ctx context.Context) ([]models.WebhookSubscription, error) {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	row, err := r.storage.Executor(ctx).QueryContext(ctx, r.queries.getAllSubscriptions)
+	if err != nil {
+		return nil, err
+	}
+
+	defer row.Close()
+
+	result := make([]models.WebhookSubscription, 0)
+
+	for row.Next() {
+		subscription := models.WebhookSubscription{}
+		err := scanSubscription(row, &subscription)
+		if err != nil {
+			return nil, fmt.Errorf("row scan error: %w", err)
+		}
+
+		result = append(result, subscription)
+	}
+
+	if err = row.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate all webhook subscriptions: %w", err)
+	}
+
+	return result, nil
+}
+
+func (r *webhookServiceRepo) GetDeliveryLog(ctx context.Context, subscriptionID string, limit int) ([]models.WebhookAttempt, error) {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	row, err := r.storage.Executor(ctx).QueryContext(ctx, r.queries.getDeliveryLog, subscriptionID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer row.Close()
+
+	result := make([]models.WebhookAttempt, 0)
+
+	for row.Next() {
+		a := models.WebhookAttempt{}
+		err := row.Scan(&a.DeliveryID, &a.EventID, &a.EventType, &a.Status, &a.Attempt,
+			&a.ResponseStatus, &a.Error, &a.AttemptedAt)
+		if err != nil {
+			return nil, fmt.Errorf("row scan error: %w", err)
+		}
+
+		result = append(result, a)
+	}
+
+	if err = row.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate webhook delivery log: %w", err)
+	}
+
+	return result, nil
+}
+
+func (r *webhookServiceRepo) AddNewSubscription(ctx context.Context, subscription *models.WebhookSubscription) error {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	row := r.storage.Executor(ctx).QueryRowContext(ctx, r.queries.addNewSubscription,
+		subscription.URL, subscription.Secret, strings.Join(subscription.Events, ","), subscription.CreatedAt)
+
+	return row.Scan(&subscription.ID)
+}
+
+// EnqueueEvent locks the event, so relays passing it concurrently don't queue it twice
+func (r *webhookServiceRepo) EnqueueEvent(ctx context.Context, event *models.Event, payload []byte) (int64, error) {
+	var queued int64
+
+	err := r.storage.RunInTransaction(ctx, func(ctx context.Context) error {
+		ctx, cancel := r.storage.WithTimeout(ctx)
+		defer cancel()
+
+		err := r.storage.LockKey(ctx, event.ID)
+		if err != nil {
+			return err
+		}
+
+		res, err := r.storage.Executor(ctx).ExecContext(ctx, r.queries.enqueueEvent,
+			event.ID, event.Type, payload, event.CreatedAt)
+		if err != nil {
+			return err
+		}
+
+		queued, err = res.RowsAffected()
+		return err
+	})
+
+	return queued, err
+}
+
+func (r *webhookServiceRepo) ClaimDueDeliveries(ctx context.Context, now time.Time, lease time.Time, limit int) ([]models.WebhookDelivery, error) {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	row, err := r.storage.Executor(ctx).QueryContext(ctx, r.queries.claimDueDeliveries, now, lease, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer row.Close()
+
+	result := make([]models.WebhookDelivery, 0)
+
+	for row.Next() {
+		d := models.WebhookDelivery{}
+		err := row.Scan(&d.ID, &d.SubscriptionID, &d.URL, &d.Secret, &d.EventID, &d.EventType, &d.Payload, &d.Attempts)
+		if err != nil {
+			return nil, fmt.Errorf("row scan error: %w", err)
+		}
+
+		result = append(result, d)
+	}
+
+	if err = row.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate due webhook deliveries: %w", err)
+	}
+
+	return result, nil
+}
+
+func (r *webhookServiceRepo) RecordAttempt(ctx context.Context, attempt *models.WebhookAttempt, nextAttemptAt time.Time) error {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	callback := func(ctx context.Context) error {
+		_, err := r.storage.Executor(ctx).ExecContext(ctx, r.queries.addLogRecord, attempt.DeliveryID,
+			attempt.Attempt, attempt.ResponseStatus, attempt.Error, attempt.AttemptedAt)
+		if err != nil {
+			return fmt.Errorf("failed to add delivery log record: %w", err)
+		}
+
+		_, err = r.storage.Executor(ctx).ExecContext(ctx, r.queries.updateDelivery, attempt.Status,
+			attempt.Attempt, nextAttemptAt, attempt.AttemptedAt, attempt.DeliveryID)
+		if err != nil {
+			return fmt.Errorf("failed to update delivery '%v': %w", attempt.DeliveryID, err)
+		}
+
+		return nil
+	}
+
+	return r.storage.RunInTransaction(ctx, callback)
+}
+
+func (r *webhookServiceRepo) DeleteSubscription(ctx context.Context, id string) error {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	res, err := r.storage.Executor(ctx).ExecContext(ctx, r.queries.deleteSubscription, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected < 1 {
+		return ErrSubscriptionNotFound
+	}
+
+	return nil
+}
+
+func NewWebhookServiceRepo(storage *database.ServiceStorage) WebhookServiceRepo {
+	r := webhookServiceRepo{
+		storage: storage,
+		queries: getWebhookQueries(),
+	}
+
+	if storage.Dialect() == database.DialectSQLite {
+		r.queries = getSQLiteWebhookQueries()
+	}
+
+	return &r
+}
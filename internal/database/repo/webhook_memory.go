@@ -0,0 +1,244 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/fuzzy-toozy/gophermart/internal/models"
+)
+
+type memoryWebhookServiceRepo struct {
+	store *MemoryStore
+}
+
+func copySubscription(subscription models.WebhookSubscription) models.WebhookSubscription {
+	subscription.Events = append(make([]string, 0, len(subscription.Events)), subscription.Events...)
+	return subscription
+}
+
+func (r *memoryWebhookServiceRepo) GetSubscriptionByID(ctx context.Context, id string) (*models.WebhookSubscription, error) {
+	subscription := models.WebhookSubscription{}
+
+	err := r.store.run(ctx, func(ctx context.Context) error {
+		if stored, ok := r.store.subscriptions[id]; ok {
+			subscription = copySubscription(stored)
+		}
+
+		return nil
+	})
+
+	return &subscription, err
+}
+
+func (r *memoryWebhookServiceRepo) GetAllSubscriptions(ctx context.Context) ([]models.WebhookSubscription, error) {
+	result := make([]models.WebhookSubscription, 0)
+
+	err := r.store.run(ctx, func(ctx context.Context) error {
+		for _, subscription := range r.store.subscriptions {
+			result = append(result, copySubscription(subscription))
+		}
+
+		return nil
+	})
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+
+	return result, err
+}
+
+func (r *memoryWebhookServiceRepo) GetDeliveryLog(ctx context.Context, subscriptionID string, limit int) ([]models.WebhookAttempt, error) {
+	result := make([]models.WebhookAttempt, 0)
+
+	err := r.store.run(ctx, func(ctx context.Context) error {
+		for _, a := range r.store.deliveryLog {
+			d, ok := r.store.deliveries[a.DeliveryID]
+			if !ok || d.delivery.SubscriptionID != subscriptionID {
+				continue
+			}
+
+			a.EventID = d.delivery.EventID
+			a.EventType = d.delivery.EventType
+			a.Status = d.status
+			result = append(result, a)
+		}
+
+		return nil
+	})
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].AttemptedAt.After(result[j].AttemptedAt)
+	})
+
+	if len(result) > limit {
+		result = result[:limit]
+	}
+
+	return result, err
+}
+
+func (r *memoryWebhookServiceRepo) AddNewSubscription(ctx context.Context, subscription *models.WebhookSubscription) error {
+	return r.store.run(ctx, func(ctx context.Context) error {
+		id, err := newMemoryID()
+		if err != nil {
+			return err
+		}
+
+		stored := copySubscription(*subscription)
+		stored.ID = id
+		memorySet(ctx, r.store, r.store.subscriptions, id, stored)
+
+		subscription.ID = id
+
+		return nil
+	})
+}
+
+func (s *MemoryStore) hasDelivery(subscriptionID string, eventID string) bool {
+	for _, d := range s.deliveries {
+		if d.delivery.SubscriptionID == subscriptionID && d.delivery.EventID == eventID {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *memoryWebhookServiceRepo) EnqueueEvent(ctx context.Context, event *models.Event, payload []byte) (int64, error) {
+	var enqueued int64
+
+	err := r.store.run(ctx, func(ctx context.Context) error {
+		for _, subscription := range r.store.subscriptions {
+			if len(subscription.Events) > 0 && !slices.Contains(subscription.Events, event.Type) {
+				continue
+			}
+
+			if r.store.hasDelivery(subscription.ID, event.ID) {
+				continue
+			}
+
+			id, err := newMemoryID()
+			if err != nil {
+				return err
+			}
+
+			memorySet(ctx, r.store, r.store.deliveries, id, memoryDelivery{
+				delivery: models.WebhookDelivery{
+					ID:             id,
+					SubscriptionID: subscription.ID,
+					EventID:        event.ID,
+					EventType:      event.Type,
+					Payload:        append([]byte(nil), payload...),
+				},
+				status:        models.DeliveryPENDING,
+				nextAttemptAt: event.CreatedAt,
+				createdAt:     event.CreatedAt,
+			})
+
+			enqueued++
+		}
+
+		return nil
+	})
+
+	return enqueued, err
+}
+
+func (r *memoryWebhookServiceRepo) ClaimDueDeliveries(ctx context.Context, now time.Time, lease time.Time, limit int) ([]models.WebhookDelivery, error) {
+	result := make([]models.WebhookDelivery, 0)
+
+	err := r.store.run(ctx, func(ctx context.Context) error {
+		due := make([]memoryDelivery, 0)
+
+		for _, d := range r.store.deliveries {
+			if d.status == models.DeliveryPENDING && !d.nextAttemptAt.After(now) {
+				due = append(due, d)
+			}
+		}
+
+		sort.Slice(due, func(i, j int) bool {
+			return due[i].nextAttemptAt.Before(due[j].nextAttemptAt)
+		})
+
+		if len(due) > limit {
+			due = due[:limit]
+		}
+
+		for _, d := range due {
+			d.nextAttemptAt = lease
+			memorySet(ctx, r.store, r.store.deliveries, d.delivery.ID, d)
+
+			subscription := r.store.subscriptions[d.delivery.SubscriptionID]
+
+			delivery := d.delivery
+			delivery.URL = subscription.URL
+			delivery.Secret = subscription.Secret
+			delivery.Payload = append([]byte(nil), d.delivery.Payload...)
+			result = append(result, delivery)
+		}
+
+		return nil
+	})
+
+	return result, err
+}
+
+func (r *memoryWebhookServiceRepo) RecordAttempt(ctx context.Context, attempt *models.WebhookAttempt, nextAttemptAt time.Time) error {
+	return r.store.run(ctx, func(ctx context.Context) error {
+		d, ok := r.store.deliveries[attempt.DeliveryID]
+		if !ok {
+			return fmt.Errorf("delivery '%v' doesn't exist", attempt.DeliveryID)
+		}
+
+		memoryAppend(ctx, r.store, &r.store.deliveryLog, models.WebhookAttempt{
+			DeliveryID:     attempt.DeliveryID,
+			Attempt:        attempt.Attempt,
+			ResponseStatus: attempt.ResponseStatus,
+			Error:          attempt.Error,
+			AttemptedAt:    attempt.AttemptedAt,
+		})
+
+		d.status = attempt.Status
+		d.delivery.Attempts = attempt.Attempt
+		d.nextAttemptAt = nextAttemptAt
+		memorySet(ctx, r.store, r.store.deliveries, attempt.DeliveryID, d)
+
+		return nil
+	})
+}
+
+func (r *memoryWebhookServiceRepo) DeleteSubscription(ctx context.Context, id string) error {
+	return r.store.run(ctx, func(ctx context.Context) error {
+		if !memoryDelete(ctx, r.store, r.store.subscriptions, id) {
+			return ErrSubscriptionNotFound
+		}
+
+		// Deliveries and their log are deleted with the subscription
+		for deliveryID, d := range r.store.deliveries {
+			if d.delivery.SubscriptionID == id {
+				memoryDelete(ctx, r.store, r.store.deliveries, deliveryID)
+			}
+		}
+
+		log := make([]models.WebhookAttempt, 0, len(r.store.deliveryLog))
+		for _, a := range r.store.deliveryLog {
+			if _, ok := r.store.deliveries[a.DeliveryID]; ok {
+				log = append(log, a)
+			}
+		}
+
+		memoryReplace(ctx, r.store, &r.store.deliveryLog, log)
+
+		return nil
+	})
+}
+
+func NewMemoryWebhookServiceRepo(store *MemoryStore) WebhookServiceRepo {
+	return &memoryWebhookServiceRepo{
+		store: store,
+	}
+}
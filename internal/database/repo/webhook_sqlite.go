@@ -0,0 +1,23 @@
+package repo
+
+func getSQLiteWebhookQueries() webhookQueryConfig {
+	c := getWebhookQueries()
+
+	c.enqueueEvent = "INSERT INTO webhook_deliveries(subscription_id, event_id, event_type, payload, status, " +
+		"next_attempt_at, created_at, updated_at) " +
+		"SELECT id, $1, $2, $3, 'PENDING', $4, $4, $4 " +
+		"FROM webhook_subscriptions s WHERE (events = '' OR instr(',' || events || ',', ',' || $2 || ',') > 0) " +
+		"AND NOT EXISTS (SELECT 1 FROM webhook_deliveries d WHERE d.subscription_id = s.id AND d.event_id = $1)"
+
+	// Returning clause can't refer to the tables joined by update, so subscriptions are selected.
+	// Transactions hold the database write lock, so deliveries can't be claimed twice.
+	c.claimDueDeliveries = "UPDATE webhook_deliveries SET next_attempt_at = $2, updated_at = $1 WHERE id IN (" +
+		"SELECT id FROM webhook_deliveries WHERE status = 'PENDING' AND next_attempt_at <= $1 " +
+		"ORDER BY next_attempt_at LIMIT $3) " +
+		"RETURNING id, subscription_id, " +
+		"(SELECT url FROM webhook_subscriptions s WHERE s.id = subscription_id), " +
+		"(SELECT secret FROM webhook_subscriptions s WHERE s.id = subscription_id), " +
+		"event_id, event_type, payload, attempts"
+
+	return c
+}
@@ -0,0 +1,168 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fuzzy-toozy/gophermart/internal/database"
+	"github.com/fuzzy-toozy/gophermart/internal/models"
+)
+
+type WithdrawalServiceRepo interface {
+	GetWithdrawalByNumber(ctx context.Context, number string) (*models.Withdrawal, error)
+	GetAllUserWithdrawals(ctx context.Context, username string) ([]models.Withdrawal, error)
+	// GetWithdrawnSum returns sum of not cancelled withdrawals made since the given moment
+	GetWithdrawnSum(ctx context.Context, username string, since time.Time) (float64, error)
+
+	AddNewWithdrawal(ctx context.Context, wd *models.Withdrawal) error
+
+	UpdateStatus(ctx context.Context, wd *models.Withdrawal) error
+}
+
+type withdrawalQueryConfig struct {
+	getWithdrawalByNumber string
+	getAllUserWithdrawals string
+	getWithdrawnSum       string
+	addNewWithdrawal      string
+	updateStatus          string
+}
+
+type withdrawalServiceRepo struct {
+	storage *database.ServiceStorage
+	queries withdrawalQueryConfig
+}
+
+func getWithdrawalQueries() withdrawalQueryConfig {
+	c := withdrawalQueryConfig{}
+
+	c.getWithdrawalByNumber = "SELECT number, username, sum, status, processed_at, updated_at " +
+		"FROM withdrawals WHERE number = $1"
+
+	c.getAllUserWithdrawals = "SELECT number, username, sum, status, processed_at, updated_at FROM withdrawals " +
+		"WHERE username = $1 ORDER BY processed_at DESC"
+
+	c.getWithdrawnSum = "SELECT coalesce(sum(sum), 0) FROM withdrawals " +
+		"WHERE username = $1 AND processed_at >= $2 AND status != 'CANCELLED'"
+
+	c.addNewWithdrawal = "INSERT INTO withdrawals(number, username, sum, status, processed_at, updated_at) " +
+		"VALUES ($1, $2, $3, $4, $5, $6) ON CONFLICT (number) DO NOTHING"
+
+	c.updateStatus = "UPDATE withdrawals SET status = $1, updated_at = $2 WHERE number = $3"
+
+	return c
+}
+
+func (r *withdrawalServiceRepo) GetWithdrawalByNumber(ctx context.Context, number string) (*models.Withdrawal, error) {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	wd := models.Withdrawal{}
+
+	row := r.storage.Executor(ctx).QueryRowContext(ctx, r.queries.getWithdrawalByNumber, number)
+
+	err := row.Scan(&wd.Order, &wd.Username, &wd.Sum, &wd.Status, &wd.ProcessedAt, &wd.UpdatedAt)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return &wd, err
+	}
+
+	return &wd, nil
+}
+
+func (r *withdrawalServiceRepo) GetAllUserWithdrawals(ctx context.Context, username string) ([]models.Withdrawal, error) {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	row, err := r.storage.Executor(ctx).QueryContext(ctx, r.queries.getAllUserWithdrawals, username)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer row.Close()
+
+	result := make([]models.Withdrawal, 0)
+
+	for row.Next() {
+		wd := models.Withdrawal{}
+		err := row.Scan(&wd.Order, &wd.Username, &wd.Sum, &wd.Status, &wd.ProcessedAt, &wd.UpdatedAt)
+
+		if err != nil {
+			return nil, fmt.Errorf("row scan error: %w", err)
+		}
+
+		result = append(result, wd)
+	}
+
+	if err = row.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate all withdrawals: %w", err)
+	}
+
+	return result, nil
+}
+
+func (r *withdrawalServiceRepo) GetWithdrawnSum(ctx context.Context, username string, since time.Time) (float64, error) {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	var withdrawn float64
+
+	err := r.storage.Executor(ctx).QueryRowContext(ctx, r.queries.getWithdrawnSum, username, since).Scan(&withdrawn)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+
+	return withdrawn, nil
+}
+
+func (r *withdrawalServiceRepo) AddNewWithdrawal(ctx context.Context, wd *models.Withdrawal) error {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	res, err := r.storage.Executor(ctx).ExecContext(ctx, r.queries.addNewWithdrawal,
+		wd.Order, wd.Username, wd.Sum, wd.Status, wd.ProcessedAt, wd.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected < 1 {
+		return ErrWithdrawExists
+	}
+
+	return nil
+}
+
+func (r *withdrawalServiceRepo) UpdateStatus(ctx context.Context, wd *models.Withdrawal) error {
+	ctx, cancel := r.storage.WithTimeout(ctx)
+	defer cancel()
+
+	res, err := r.storage.Executor(ctx).ExecContext(ctx, r.queries.updateStatus, wd.Status, wd.UpdatedAt, wd.Order)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected < 1 {
+		return ErrWithdrawNotFound
+	}
+
+	return nil
+}
+
+func NewWithdrawalServiceRepo(storage *database.ServiceStorage) WithdrawalServiceRepo {
+	return &withdrawalServiceRepo{
+		storage: storage,
+		queries: getWithdrawalQueries(),
+	}
+}
@@ -0,0 +1,97 @@
+package repo
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/fuzzy-toozy/gophermart/internal/models"
+)
+
+type memoryWithdrawalServiceRepo struct {
+	store *MemoryStore
+}
+
+func (r *memoryWithdrawalServiceRepo) GetWithdrawalByNumber(ctx context.Context, number string) (*models.Withdrawal, error) {
+	wd := models.Withdrawal{}
+
+	err := r.store.run(ctx, func(ctx context.Context) error {
+		wd = r.store.withdrawals[number]
+		return nil
+	})
+
+	return &wd, err
+}
+
+func (r *memoryWithdrawalServiceRepo) GetAllUserWithdrawals(ctx context.Context, username string) ([]models.Withdrawal, error) {
+	result := make([]models.Withdrawal, 0)
+
+	err := r.store.run(ctx, func(ctx context.Context) error {
+		for _, wd := range r.store.withdrawals {
+			if wd.Username == username {
+				result = append(result, wd)
+			}
+		}
+
+		return nil
+	})
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ProcessedAt.After(result[j].ProcessedAt)
+	})
+
+	return result, err
+}
+
+func (r *memoryWithdrawalServiceRepo) GetWithdrawnSum(ctx context.Context, username string, since time.Time) (float64, error) {
+	var sum float64
+
+	err := r.store.run(ctx, func(ctx context.Context) error {
+		for _, wd := range r.store.withdrawals {
+			if wd.Username == username && !wd.ProcessedAt.Before(since) && wd.Status != models.WithdrawalCANCELLED {
+				sum += wd.Sum
+			}
+		}
+
+		return nil
+	})
+
+	return sum, err
+}
+
+func (r *memoryWithdrawalServiceRepo) AddNewWithdrawal(ctx context.Context, wd *models.Withdrawal) error {
+	return r.store.run(ctx, func(ctx context.Context) error {
+		if err := r.store.userExists(wd.Username); err != nil {
+			return err
+		}
+
+		if _, ok := r.store.withdrawals[wd.Order]; ok {
+			return ErrWithdrawExists
+		}
+
+		memorySet(ctx, r.store, r.store.withdrawals, wd.Order, *wd)
+
+		return nil
+	})
+}
+
+func (r *memoryWithdrawalServiceRepo) UpdateStatus(ctx context.Context, wd *models.Withdrawal) error {
+	return r.store.run(ctx, func(ctx context.Context) error {
+		stored, ok := r.store.withdrawals[wd.Order]
+		if !ok {
+			return ErrWithdrawNotFound
+		}
+
+		stored.Status = wd.Status
+		stored.UpdatedAt = wd.UpdatedAt
+		memorySet(ctx, r.store, r.store.withdrawals, wd.Order, stored)
+
+		return nil
+	})
+}
+
+func NewMemoryWithdrawalServiceRepo(store *MemoryStore) WithdrawalServiceRepo {
+	return &memoryWithdrawalServiceRepo{
+		store: store,
+	}
+}
@@ -0,0 +1,140 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fuzzy-toozy/gophermart/internal/common"
+	"github.com/mattn/go-sqlite3"
+)
+
+// SQLiteURIScheme selects the SQLite storage, e.g. sqlite3://gophermart.db
+const SQLiteURIScheme = "sqlite3://"
+
+// sqliteDriverName is the SQLite driver with the functions the schema uses
+const sqliteDriverName = "sqlite3_gophermart"
+
+func init() {
+	sql.Register(sqliteDriverName, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			// Default of the id columns
+			return conn.RegisterFunc("gen_random_uuid", common.NewUUID, false)
+		},
+	})
+}
+
+// Timestamps are stored as text of fixed width in UTC, so they compare correctly as strings
+const sqliteTimeFormat = "2006-01-02 15:04:05.000000000-07:00"
+
+// sqliteBusyTimeout is used when query timeout isn't configured
+const sqliteBusyTimeout = 5 * time.Second
+
+// IsSQLiteURI reports whether the connection string selects the SQLite storage
+func IsSQLiteURI(uri string) bool {
+	return strings.HasPrefix(uri, SQLiteURIScheme)
+}
+
+// Dialect is the SQL flavor of the storage database
+type Dialect int
+
+const (
+	DialectPostgres Dialect = iota
+	DialectSQLite
+)
+
+// sqliteDSN converts the connection string into the SQLite driver data source name.
+// Transactions take the write lock when they begin, so only one of them changes data
+// at a time and LockKey isn't needed.
+func (c *DBConfig) sqliteDSN() string {
+	dsn := strings.TrimPrefix(c.ConnURI, SQLiteURIScheme)
+
+	file, rawQuery, _ := strings.Cut(dsn, "?")
+
+	q, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		q = url.Values{}
+	}
+
+	timeout := sqliteBusyTimeout
+	if c.QueryTimeout > 0 {
+		timeout = c.QueryTimeout
+	}
+
+	defaults := map[string]string{
+		"_foreign_keys": "on",
+		"_journal_mode": "WAL",
+		"_txlock":       "immediate",
+		"_busy_timeout": strconv.FormatInt(timeout.Milliseconds(), 10),
+	}
+
+	for k, v := range defaults {
+		if !q.Has(k) {
+			q.Set(k, v)
+		}
+	}
+
+	return file + "?" + q.Encode()
+}
+
+var sqlitePlaceholder = regexp.MustCompile(`\$(\d+)`)
+
+// sqliteExecutor runs queries written for Postgres. Numbered placeholders are converted
+// to SQLite ones and timestamps to the stored text format.
+type sqliteExecutor struct {
+	e sqlExecutor
+}
+
+func (e sqliteExecutor) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return e.e.ExecContext(ctx, sqliteRebind(query), sqliteArgs(args)...)
+}
+
+func (e sqliteExecutor) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return e.e.QueryContext(ctx, sqliteRebind(query), sqliteArgs(args)...)
+}
+
+func (e sqliteExecutor) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return e.e.QueryRowContext(ctx, sqliteRebind(query), sqliteArgs(args)...)
+}
+
+func sqliteRebind(query string) string {
+	return sqlitePlaceholder.ReplaceAllString(query, "?$1")
+}
+
+func sqliteArgs(args []any) []any {
+	result := make([]any, len(args))
+
+	for i, arg := range args {
+		switch v := arg.(type) {
+		case time.Time:
+			result[i] = v.UTC().Format(sqliteTimeFormat)
+		case *time.Time:
+			if v != nil {
+				result[i] = v.UTC().Format(sqliteTimeFormat)
+			}
+		case sql.NullTime:
+			if v.Valid {
+				result[i] = v.Time.UTC().Format(sqliteTimeFormat)
+			}
+		default:
+			result[i] = arg
+		}
+	}
+
+	return result
+}
+
+// isSQLiteBusy reports whether the query failed waiting for the database lock
+func isSQLiteBusy(err error) bool {
+	var e sqlite3.Error
+	if errors.As(err, &e) {
+		return e.Code == sqlite3.ErrBusy || e.Code == sqlite3.ErrLocked
+	}
+
+	return false
+}
@@ -1,38 +1,152 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"time"
+
+	"go.uber.org/zap"
 )
 
 type queryConfig struct {
 	addUserQuery string
 	getUserQuery string
+	lockKeyQuery string
+	notifyQuery  string
+}
+
+// Executor runs queries of repositories. Errors caused by slow or unreachable database
+// wrap serviceErrs.ErrStorageTimeout and serviceErrs.ErrStorageUnavailable respectively.
+type Executor interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) Row
+}
+
+// Rows is the result of Executor.QueryContext
+type Rows interface {
+	Next() bool
+	Scan(dest ...any) error
+	Err() error
+	Close() error
+}
+
+// Row is the result of Executor.QueryRowContext
+type Row interface {
+	Scan(dest ...any) error
 }
 
+// sqlExecutor is implemented by both sql.DB and sql.Tx
+type sqlExecutor interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// Transactor runs repository operations in transactions of the storage
+type Transactor interface {
+	RunInTransaction(ctx context.Context, callback func(ctx context.Context) error) error
+	LockKey(ctx context.Context, key string) error
+}
+
+// Storage is a backend the repositories keep data in
+type Storage interface {
+	Transactor
+	Listen(ctx context.Context, channel string, logger *zap.SugaredLogger) (<-chan []string, error)
+	Close() error
+}
+
+type txCtxKey struct{}
+
+// pendingNotifyCtxKey keeps notifications sent in the transaction of the local notifier
+type pendingNotifyCtxKey struct{}
+
+const (
+	pingRetryBase = 100 * time.Millisecond
+	pingRetryMax  = 5 * time.Second
+)
+
 type ServiceStorage struct {
 	DB       *sql.DB
 	queries  queryConfig
 	dbConfig DBConfig
+	dialect  Dialect
+	// notifier delivers notifications if the database can't
+	notifier *localNotifier
 }
 
-func (s *ServiceStorage) RunInTransaction(callback func() error) error {
-	tx, err := s.DB.Begin()
+// Dialect returns the SQL flavor repositories must write queries in
+func (s *ServiceStorage) Dialect() Dialect {
+	return s.dialect
+}
+
+// Executor returns the transaction started by RunInTransaction for ctx or DB if there is none
+func (s *ServiceStorage) Executor(ctx context.Context) Executor {
+	var e sqlExecutor = s.DB
+	if tx, ok := ctx.Value(txCtxKey{}).(*sql.Tx); ok {
+		e = tx
+	}
+
+	if s.dialect == DialectSQLite {
+		e = sqliteExecutor{e: e}
+	}
+
+	return classifyingExecutor{e: e}
+}
+
+// RunInTransaction runs callback in a transaction. Queries must use Executor with the context
+// passed to the callback to be part of the transaction. Nested calls join the outer transaction.
+func (s *ServiceStorage) RunInTransaction(ctx context.Context, callback func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txCtxKey{}).(*sql.Tx); ok {
+		return callback(ctx)
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
 
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return fmt.Errorf("failed to begin transaction: %w", classifyError(err))
 	}
 
 	defer tx.Rollback()
 
-	err = callback()
+	var pending []notification
+
+	ctx = context.WithValue(ctx, txCtxKey{}, tx)
+	if s.notifier != nil {
+		ctx = context.WithValue(ctx, pendingNotifyCtxKey{}, &pending)
+	}
+
+	err = callback(ctx)
 	if err != nil {
 		return err
 	}
 
 	err = tx.Commit()
 	if err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
+		return fmt.Errorf("failed to commit transaction: %w", classifyError(err))
+	}
+
+	for _, n := range pending {
+		s.notifier.notify(n.channel, n.payload)
+	}
+
+	return nil
+}
+
+// LockKey takes a lock on the key until the end of the current transaction
+func (s *ServiceStorage) LockKey(ctx context.Context, key string) error {
+	// SQLite transactions hold the database write lock already
+	if s.dialect == DialectSQLite {
+		return nil
+	}
+
+	ctx, cancel := s.WithTimeout(ctx)
+	defer cancel()
+
+	_, err := s.Executor(ctx).ExecContext(ctx, s.queries.lockKeyQuery, key)
+	if err != nil {
+		return fmt.Errorf("failed to lock key '%v': %w", key, err)
 	}
 
 	return nil
@@ -43,6 +157,8 @@ func getQueries() queryConfig {
 
 	c.addUserQuery = "INSERT INTO users (username, user_password) values ($1, $2)"
 	c.getUserQuery = "SELECT username, user_password FROM users WHERE username = $1"
+	c.lockKeyQuery = "SELECT pg_advisory_xact_lock(hashtext($1))"
+	c.notifyQuery = "SELECT pg_notify($1, $2)"
 
 	return c
 }
@@ -50,11 +166,23 @@ func getQueries() queryConfig {
 func NewServiceStorage(dbconfig DBConfig) (*ServiceStorage, error) {
 	r := ServiceStorage{}
 
-	db, err := sql.Open(dbconfig.DriverName, dbconfig.ConnURI)
+	driverName, connURI := dbconfig.DriverName, dbconfig.poolConnURI()
+	if IsSQLiteURI(dbconfig.ConnURI) {
+		driverName, connURI = sqliteDriverName, dbconfig.sqliteDSN()
+		r.dialect = DialectSQLite
+		r.notifier = newLocalNotifier()
+	}
+
+	db, err := sql.Open(driverName, connURI)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
+	db.SetMaxOpenConns(dbconfig.MaxOpenConns)
+	db.SetMaxIdleConns(dbconfig.MaxIdleConns)
+	db.SetConnMaxLifetime(dbconfig.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(dbconfig.ConnMaxIdleTime)
+
 	r.DB = db
 	r.dbConfig = dbconfig
 	r.queries = getQueries()
@@ -62,6 +190,44 @@ func NewServiceStorage(dbconfig DBConfig) (*ServiceStorage, error) {
 	return &r, nil
 }
 
+// WaitReady pings the database with growing delay until it is reachable or the config timeout ends
+func (s *ServiceStorage) WaitReady(ctx context.Context, logger *zap.SugaredLogger) error {
+	if s.dbConfig.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.dbConfig.Timeout)
+		defer cancel()
+	}
+
+	delay := pingRetryBase
+	for {
+		err := s.DB.PingContext(ctx)
+		if err == nil {
+			return nil
+		}
+
+		logger.Warnf("Database is unreachable, retrying in %v: %v", delay, err)
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return fmt.Errorf("database is unreachable: %w", err)
+		}
+
+		delay *= 2
+		if delay > pingRetryMax {
+			delay = pingRetryMax
+		}
+	}
+}
+
+func (s *ServiceStorage) Ping(ctx context.Context) error {
+	return classifyError(s.DB.PingContext(ctx))
+}
+
+func (s *ServiceStorage) Stats() sql.DBStats {
+	return s.DB.Stats()
+}
+
 func (s *ServiceStorage) Close() error {
 	return s.DB.Close()
 }
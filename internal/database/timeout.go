@@ -0,0 +1,144 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	serviceErrs "github.com/fuzzy-toozy/gophermart/internal/errors"
+	"github.com/jackc/pgx"
+)
+
+type queryTimeoutCtxKey struct{}
+
+// WithQueryTimeout overrides the default query timeout for queries run with the returned context.
+// Zero timeout disables the limit.
+func WithQueryTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, queryTimeoutCtxKey{}, timeout)
+}
+
+// WithTimeout limits ctx by the query timeout. Earlier deadline of ctx is kept.
+func (s *ServiceStorage) WithTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	timeout := s.dbConfig.QueryTimeout
+	if t, ok := ctx.Value(queryTimeoutCtxKey{}).(time.Duration); ok {
+		timeout = t
+	}
+
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, timeout)
+}
+
+type sqlStateError interface {
+	SQLState() string
+}
+
+func sqlState(err error) string {
+	var e sqlStateError
+	if errors.As(err, &e) {
+		return e.SQLState()
+	}
+
+	return ""
+}
+
+// isTimeout reports whether the query failed because it took too long
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || isSQLiteBusy(err) {
+		return true
+	}
+
+	switch sqlState(err) {
+	// query_canceled is returned on statement timeout, lock_not_available on lock timeout
+	case "57014", "55P03":
+		return true
+	}
+
+	return false
+}
+
+// isUnavailable reports whether the query failed because the database can't be reached.
+// It must be given errors of the database driver only, so network errors mean the
+// connection to the database is broken.
+func isUnavailable(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, pgx.ErrDeadConn) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	state := sqlState(err)
+	// Connection exceptions, shutdown and too many connections
+	return strings.HasPrefix(state, "08") || strings.HasPrefix(state, "57P") || state == "53300"
+}
+
+// classifyError wraps the database driver error into the storage error it is caused by
+func classifyError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case isTimeout(err):
+		return fmt.Errorf("%w: %w", serviceErrs.ErrStorageTimeout, err)
+	case isUnavailable(err):
+		return fmt.Errorf("%w: %w", serviceErrs.ErrStorageUnavailable, err)
+	}
+
+	return err
+}
+
+// classifyingExecutor classifies errors of the queries it runs
+type classifyingExecutor struct {
+	e sqlExecutor
+}
+
+func (e classifyingExecutor) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	res, err := e.e.ExecContext(ctx, query, args...)
+	return res, classifyError(err)
+}
+
+func (e classifyingExecutor) QueryContext(ctx context.Context, query string, args ...any) (Rows, error) {
+	rows, err := e.e.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, classifyError(err)
+	}
+
+	return classifyingRows{rows}, nil
+}
+
+func (e classifyingExecutor) QueryRowContext(ctx context.Context, query string, args ...any) Row {
+	return classifyingRow{e.e.QueryRowContext(ctx, query, args...)}
+}
+
+type classifyingRows struct {
+	*sql.Rows
+}
+
+func (r classifyingRows) Scan(dest ...any) error {
+	return classifyError(r.Rows.Scan(dest...))
+}
+
+func (r classifyingRows) Err() error {
+	return classifyError(r.Rows.Err())
+}
+
+func (r classifyingRows) Close() error {
+	return classifyError(r.Rows.Close())
+}
+
+type classifyingRow struct {
+	row *sql.Row
+}
+
+func (r classifyingRow) Scan(dest ...any) error {
+	return classifyError(r.row.Scan(dest...))
+}
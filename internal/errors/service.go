@@ -1,6 +1,17 @@
 package errors
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var (
+	// ErrStorageTimeout is wrapped by storage errors caused by slow queries
+	ErrStorageTimeout = errors.New("storage timeout")
+	// ErrStorageUnavailable is wrapped by storage errors caused by unreachable database
+	ErrStorageUnavailable = errors.New("storage unavailable")
+)
 
 type ServiceError interface {
 	Error() string
@@ -28,6 +39,19 @@ func (e *serviceError) String() string {
 	return e.err.Error()
 }
 
+// NewServiceError creates error with the response status. Internal errors wrapping
+// ErrStorageTimeout and ErrStorageUnavailable are reported as 504 and 503 respectively.
 func NewServiceError(status int, format string, a ...any) ServiceError {
-	return &serviceError{fmt.Errorf(format, a...), status}
+	err := fmt.Errorf(format, a...)
+
+	if status == http.StatusInternalServerError {
+		switch {
+		case errors.Is(err, ErrStorageTimeout):
+			status = http.StatusGatewayTimeout
+		case errors.Is(err, ErrStorageUnavailable):
+			status = http.StatusServiceUnavailable
+		}
+	}
+
+	return &serviceError{err, status}
 }
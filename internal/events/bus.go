@@ -0,0 +1,178 @@
+// Package events delivers user events to subscribers inside the process.
+package events
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fuzzy-toozy/gophermart/internal/models"
+)
+
+const (
+	EventOrder   = "order"
+	EventBalance = "balance"
+	// EventResync tells the subscriber missed events can't be replayed and the state must be fetched again
+	EventResync = "resync"
+)
+
+// Subscribers falling behind this number of events are disconnected and have to resume
+const subscriberBufferSize = 64
+
+type userEvent struct {
+	username string
+	seq      uint64
+	event    models.Event
+}
+
+// Subscription receives events of a single user. C is closed when the subscriber
+// is too slow or cancelled.
+type Subscription struct {
+	C        chan models.Event
+	username string
+}
+
+// Bus fans out user events to subscribers and keeps the latest events in a ring buffer,
+// so subscribers can resume after reconnect. Event IDs are "<epoch>-<seq>", epoch is unique
+// for every bus, so IDs given out before restart are never confused with new ones.
+type Bus struct {
+	mu      sync.Mutex
+	epoch   string
+	nextSeq uint64
+	ring    []userEvent
+	start   int
+	subs    map[string]map[*Subscription]struct{}
+}
+
+func NewBus(bufferSize int) *Bus {
+	return &Bus{
+		epoch:   strconv.FormatInt(time.Now().UnixNano(), 36),
+		nextSeq: 1,
+		ring:    make([]userEvent, 0, bufferSize),
+		subs:    make(map[string]map[*Subscription]struct{}),
+	}
+}
+
+func (b *Bus) eventID(seq uint64) string {
+	return b.epoch + "-" + strconv.FormatUint(seq, 10)
+}
+
+// parseID returns sequence number of the event published by this bus
+func (b *Bus) parseID(id string) (uint64, bool) {
+	epoch, seq, ok := strings.Cut(id, "-")
+	if !ok || epoch != b.epoch {
+		return 0, false
+	}
+
+	n, err := strconv.ParseUint(seq, 10, 64)
+	return n, err == nil
+}
+
+// Publish sends the event to all subscribers of the user. Nil bus discards events.
+func (b *Bus) Publish(username string, eventType string, data any) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	e := userEvent{
+		username: username,
+		seq:      b.nextSeq,
+		event: models.Event{
+			ID:        b.eventID(b.nextSeq),
+			Type:      eventType,
+			CreatedAt: time.Now(),
+			Data:      data,
+		},
+	}
+	b.nextSeq++
+
+	if len(b.ring) < cap(b.ring) {
+		b.ring = append(b.ring, e)
+	} else if cap(b.ring) > 0 {
+		b.ring[b.start] = e
+		b.start = (b.start + 1) % cap(b.ring)
+	}
+
+	for s := range b.subs[username] {
+		select {
+		case s.C <- e.event:
+		default:
+			b.remove(s)
+		}
+	}
+}
+
+// Subscribe returns events of the user published after lastEventID and the subscription to
+// the following ones. If the missed events are not buffered anymore or lastEventID was given
+// out before restart, a single resync event is returned instead.
+func (b *Bus) Subscribe(username string, lastEventID string) ([]models.Event, *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	missed := make([]models.Event, 0)
+
+	if len(lastEventID) > 0 {
+		last, ok := b.parseID(lastEventID)
+
+		// Events published after the last one are dropped from the buffer
+		if ok && len(b.ring) > 0 && b.ring[b.start].seq > last+1 {
+			ok = false
+		}
+
+		if ok {
+			for i := 0; i < len(b.ring); i++ {
+				e := b.ring[(b.start+i)%len(b.ring)]
+				if e.username == username && e.seq > last {
+					missed = append(missed, e.event)
+				}
+			}
+		} else {
+			missed = append(missed, models.Event{
+				ID:        b.eventID(b.nextSeq - 1),
+				Type:      EventResync,
+				CreatedAt: time.Now(),
+			})
+		}
+	}
+
+	s := &Subscription{
+		C:        make(chan models.Event, subscriberBufferSize),
+		username: username,
+	}
+
+	if b.subs[username] == nil {
+		b.subs[username] = make(map[*Subscription]struct{})
+	}
+	b.subs[username][s] = struct{}{}
+
+	return missed, s
+}
+
+func (b *Bus) Unsubscribe(s *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.remove(s)
+}
+
+func (b *Bus) remove(s *Subscription) {
+	subs, ok := b.subs[s.username]
+	if !ok {
+		return
+	}
+
+	if _, ok := subs[s]; !ok {
+		return
+	}
+
+	delete(subs, s)
+	close(s.C)
+
+	if len(subs) == 0 {
+		delete(b.subs, s.username)
+	}
+}
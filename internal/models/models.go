@@ -1,28 +1,61 @@
 package models
 
 import (
+	"math"
 	"time"
 )
 
 type User struct {
 	Username string `json:"login" binding:"required"`
 	Password string `json:"password" binding:"required"`
+	// Referral code of the user who invited this one. Used on registration only.
+	ReferrerCode string `json:"referrer_code,omitempty"`
+	ReferralCode string `json:"-"`
+	Referrer     string `json:"-"`
 }
 
 type Balance struct {
 	Current   float64 `json:"current" binding:"required"`
 	Withdrawn float64 `json:"withdrawn" binding:"required"`
+	Held      float64 `json:"held,omitempty"`
+	// Points which expire before ExpiringBefore unless spent
+	ExpiringSoon   float64    `json:"expiring_soon,omitempty"`
+	ExpiringBefore *time.Time `json:"expiring_before,omitempty"`
+	Tier           string     `json:"tier,omitempty"`
+	// Points accrued for orders during the whole user lifetime
+	LifetimeAccrued float64 `json:"lifetime_accrued,omitempty"`
 }
 
 type Withdraw struct {
 	Order string  `json:"order" binding:"required"`
-	Sum   float64 `json:"sum" binding:"required"`
+	Sum   float64 `json:"sum"`
 }
 
-type Withdrawals struct {
+const (
+	WithdrawalPENDING   = "PENDING"
+	WithdrawalCOMPLETED = "COMPLETED"
+	WithdrawalCANCELLED = "CANCELLED"
+)
+
+type Withdrawal struct {
 	Order       string    `json:"order" binding:"required"`
+	Username    string    `json:"-"`
 	Sum         float64   `json:"sum" binding:"required"`
+	Status      string    `json:"status"`
 	ProcessedAt time.Time `json:"processed_at" binding:"required"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func NewWithdrawal(username string, wd *Withdraw) *Withdrawal {
+	now := time.Now()
+	return &Withdrawal{
+		Order:       wd.Order,
+		Username:    username,
+		Sum:         wd.Sum,
+		Status:      WithdrawalPENDING,
+		ProcessedAt: now,
+		UpdatedAt:   now,
+	}
 }
 
 const (
@@ -48,3 +81,324 @@ func NewOrder(username string, number string) *Order {
 		Status:     OrderNEW,
 	}
 }
+
+type IdempotencyRecord struct {
+	Username       string
+	Key            string
+	Fingerprint    string
+	ResponseStatus int
+	ResponseBody   []byte
+	ContentType    string
+	CreatedAt      time.Time
+	ExpiresAt      time.Time
+}
+
+func (r *IdempotencyRecord) Completed() bool {
+	return r.ResponseStatus != 0
+}
+
+func NewIdempotencyRecord(username string, key string, fingerprint string, lifetime time.Duration) *IdempotencyRecord {
+	now := time.Now()
+	return &IdempotencyRecord{
+		Username:    username,
+		Key:         key,
+		Fingerprint: fingerprint,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(lifetime),
+	}
+}
+
+const (
+	HoldACTIVE   = "ACTIVE"
+	HoldCAPTURED = "CAPTURED"
+	HoldRELEASED = "RELEASED"
+	HoldEXPIRED  = "EXPIRED"
+)
+
+type HoldRequest struct {
+	Sum float64 `json:"sum"`
+}
+
+type CaptureRequest struct {
+	Order string `json:"order" binding:"required"`
+}
+
+type Hold struct {
+	ID        string    `json:"id"`
+	Username  string    `json:"-"`
+	Sum       float64   `json:"sum"`
+	Status    string    `json:"status"`
+	Order     string    `json:"order,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func NewHold(username string, sum float64, ttl time.Duration) *Hold {
+	now := time.Now()
+	return &Hold{
+		Username:  username,
+		Sum:       sum,
+		Status:    HoldACTIVE,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+		UpdatedAt: now,
+	}
+}
+
+func (h *Hold) Active(now time.Time) bool {
+	return h.Status == HoldACTIVE && h.ExpiresAt.After(now)
+}
+
+const (
+	TransferIN  = "IN"
+	TransferOUT = "OUT"
+)
+
+type TransferRequest struct {
+	Login string  `json:"login" binding:"required"`
+	Sum   float64 `json:"sum"`
+}
+
+type Transfer struct {
+	ID        string    `json:"id"`
+	Sender    string    `json:"from"`
+	Recipient string    `json:"to"`
+	Sum       float64   `json:"sum"`
+	Direction string    `json:"direction,omitempty"`
+	CreatedAt time.Time `json:"processed_at"`
+}
+
+func NewTransfer(sender string, recipient string, sum float64) *Transfer {
+	return &Transfer{
+		Sender:    sender,
+		Recipient: recipient,
+		Sum:       sum,
+		Direction: TransferOUT,
+		CreatedAt: time.Now(),
+	}
+}
+
+// BalanceDrift describes mismatch of the stored user balance and the balance ledger
+type BalanceDrift struct {
+	Username        string
+	Current         float64
+	LedgerCurrent   float64
+	Withdrawn       float64
+	LedgerWithdrawn float64
+}
+
+// LedgerViolation describes ledger transaction which postings don't balance
+type LedgerViolation struct {
+	TransactionID string
+	Operation     string
+	Postings      int
+	Sum           float64
+}
+
+const (
+	TierBRONZE = "BRONZE"
+	TierSILVER = "SILVER"
+	TierGOLD   = "GOLD"
+)
+
+// UserTier is the current tier of the user and points the tier is based on
+type UserTier struct {
+	Username        string
+	Tier            string
+	LifetimeAccrued float64
+}
+
+type TierChange struct {
+	Username        string
+	From            string
+	To              string
+	LifetimeAccrued float64
+	ChangedAt       time.Time
+}
+
+// Campaign grants bonus points for orders credited during its time window.
+// Bonus is the accrual increased by Multiplier plus FixedBonus.
+type Campaign struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name" binding:"required"`
+	StartsAt   time.Time `json:"starts_at" binding:"required"`
+	EndsAt     time.Time `json:"ends_at" binding:"required"`
+	Multiplier float64   `json:"multiplier"`
+	FixedBonus float64   `json:"fixed_bonus"`
+	// Bonus is granted for the first FirstOrders user orders only. Zero means any order.
+	FirstOrders int `json:"first_orders"`
+	// Max bonus a user can get from the campaign. Zero means no limit.
+	UserCap   float64   `json:"user_cap"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Applies reports if the campaign grants bonus for the user order, processed is the number
+// of user processed orders including this one
+func (c *Campaign) Applies(processed int) bool {
+	return c.FirstOrders == 0 || processed <= c.FirstOrders
+}
+
+// Bonus returns bonus points for the order accrual, granted is the bonus user already got
+// from the campaign. Bonus is rounded to cents and never exceeds the rest of UserCap.
+func (c *Campaign) Bonus(accrual float64, granted float64) float64 {
+	bonus := c.FixedBonus
+	if c.Multiplier > 1 {
+		bonus += accrual * (c.Multiplier - 1)
+	}
+
+	if c.UserCap > 0 {
+		bonus = math.Min(bonus, c.UserCap-granted)
+	}
+
+	return math.Max(math.Round(bonus*100)/100, 0)
+}
+
+type ReferralInfo struct {
+	Code string `json:"code"`
+	// Users registered with the code
+	Invited int `json:"invited"`
+	// Invited users whose first order is processed
+	Rewarded int `json:"rewarded"`
+}
+
+type Reward struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name" binding:"required"`
+	Cost      float64   `json:"cost" binding:"required"`
+	Stock     int       `json:"stock"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type Redemption struct {
+	ID         string    `json:"id"`
+	Username   string    `json:"-"`
+	RewardID   string    `json:"reward_id,omitempty"`
+	RewardName string    `json:"reward_name"`
+	Cost       float64   `json:"cost"`
+	CreatedAt  time.Time `json:"processed_at"`
+}
+
+type PromoCode struct {
+	Code           string     `json:"code"`
+	Value          float64    `json:"value"`
+	MaxRedemptions int        `json:"max_redemptions"`
+	Redemptions    int        `json:"redemptions"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// PromoCodeRequest generates Count codes. Code can be set when a single code is generated.
+type PromoCodeRequest struct {
+	Code           string     `json:"code"`
+	Value          float64    `json:"value" binding:"required"`
+	MaxRedemptions int        `json:"max_redemptions"`
+	ExpiresAt      *time.Time `json:"expires_at"`
+	Count          int        `json:"count"`
+}
+
+type PromoRedeemRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+const (
+	EventOrderStatusChanged  = "order.status_changed"
+	EventWithdrawalCreated   = "withdrawal.created"
+	EventWithdrawalCompleted = "withdrawal.completed"
+	EventWithdrawalCancelled = "withdrawal.cancelled"
+)
+
+const (
+	DeliveryPENDING   = "PENDING"
+	DeliveryDELIVERED = "DELIVERED"
+	DeliveryFAILED    = "FAILED"
+)
+
+type Event struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+// WithdrawalEvent exposes the withdrawal owner hidden in user responses
+type WithdrawalEvent struct {
+	Login string `json:"login"`
+	Withdrawal
+}
+
+const (
+	BalanceACCRUAL     = "ACCRUAL"
+	BalanceWITHDRAWAL  = "WITHDRAWAL"
+	BalanceREFUND      = "REFUND"
+	BalanceHOLD        = "HOLD"
+	BalanceRELEASE     = "RELEASE"
+	BalanceEXPIRY      = "EXPIRY"
+	BalanceTRANSFERIN  = "TRANSFER_IN"
+	BalanceTRANSFEROUT = "TRANSFER_OUT"
+	BalanceREWARD      = "REWARD"
+	BalancePROMO       = "PROMO"
+	BalanceREFERRAL    = "REFERRAL"
+	BalanceADJUSTMENT  = "ADJUSTMENT"
+)
+
+// Adjustment is a manual correction of the user balance made by admin.
+// Positive amount credits the user, negative one debits.
+type Adjustment struct {
+	Amount float64 `json:"amount" binding:"required"`
+	Reason string  `json:"reason" binding:"required"`
+}
+
+// BalanceEvent tells the user about the balance change
+type BalanceEvent struct {
+	Reason string  `json:"reason"`
+	Order  string  `json:"order,omitempty"`
+	Sum    float64 `json:"sum"`
+}
+
+// WebhookSubscription receives events of the listed types. Empty list means all events.
+type WebhookSubscription struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url" binding:"required"`
+	Secret    string    `json:"secret"`
+	Events    []string  `json:"events"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type WebhookDelivery struct {
+	ID             string
+	SubscriptionID string
+	URL            string
+	Secret         string
+	EventID        string
+	EventType      string
+	Payload        []byte
+	Attempts       int
+}
+
+// WebhookAttempt is a delivery log record
+type WebhookAttempt struct {
+	DeliveryID     string    `json:"delivery_id"`
+	EventID        string    `json:"event_id"`
+	EventType      string    `json:"event_type"`
+	Status         string    `json:"status"`
+	Attempt        int       `json:"attempt"`
+	ResponseStatus int       `json:"response_status,omitempty"`
+	Error          string    `json:"error,omitempty"`
+	AttemptedAt    time.Time `json:"attempted_at"`
+}
+
+// PoolStats describes the database connection pool
+type PoolStats struct {
+	MaxOpenConnections int   `json:"max_open_connections"`
+	OpenConnections    int   `json:"open_connections"`
+	InUse              int   `json:"in_use"`
+	Idle               int   `json:"idle"`
+	WaitCount          int64 `json:"wait_count"`
+	WaitDurationMs     int64 `json:"wait_duration_ms"`
+	MaxIdleClosed      int64 `json:"max_idle_closed"`
+	MaxIdleTimeClosed  int64 `json:"max_idle_time_closed"`
+	MaxLifetimeClosed  int64 `json:"max_lifetime_closed"`
+}
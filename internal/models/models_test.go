@@ -0,0 +1,57 @@
+package models
+
+import "testing"
+
+func TestCampaignApplies(t *testing.T) {
+	tests := []struct {
+		name        string
+		firstOrders int
+		processed   int
+		applies     bool
+	}{
+		{name: "any order", firstOrders: 0, processed: 100, applies: true},
+		{name: "first order", firstOrders: 1, processed: 1, applies: true},
+		{name: "second order of first one", firstOrders: 1, processed: 2, applies: false},
+		{name: "last of first orders", firstOrders: 3, processed: 3, applies: true},
+		{name: "after first orders", firstOrders: 3, processed: 4, applies: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Campaign{FirstOrders: tt.firstOrders}
+			if applies := c.Applies(tt.processed); applies != tt.applies {
+				t.Errorf("Expected %v for order %v, got %v", tt.applies, tt.processed, applies)
+			}
+		})
+	}
+}
+
+func TestCampaignBonus(t *testing.T) {
+	tests := []struct {
+		name     string
+		campaign Campaign
+		accrual  float64
+		granted  float64
+		bonus    float64
+	}{
+		{name: "multiplier", campaign: Campaign{Multiplier: 2}, accrual: 100, bonus: 100},
+		{name: "fractional multiplier", campaign: Campaign{Multiplier: 1.5}, accrual: 100, bonus: 50},
+		{name: "multiplier below one is ignored", campaign: Campaign{Multiplier: 0.5, FixedBonus: 10}, accrual: 100, bonus: 10},
+		{name: "fixed bonus", campaign: Campaign{FixedBonus: 25}, accrual: 100, bonus: 25},
+		{name: "multiplier and fixed bonus", campaign: Campaign{Multiplier: 1.1, FixedBonus: 5}, accrual: 100, bonus: 15},
+		{name: "rounded to cents", campaign: Campaign{Multiplier: 1.1}, accrual: 0.333, bonus: 0.03},
+		{name: "below cap", campaign: Campaign{Multiplier: 2, UserCap: 200}, accrual: 100, granted: 50, bonus: 100},
+		{name: "capped", campaign: Campaign{Multiplier: 2, UserCap: 200}, accrual: 100, granted: 150, bonus: 50},
+		{name: "cap reached", campaign: Campaign{Multiplier: 2, UserCap: 200}, accrual: 100, granted: 200, bonus: 0},
+		{name: "cap lowered below granted", campaign: Campaign{Multiplier: 2, UserCap: 100}, accrual: 100, granted: 150, bonus: 0},
+		{name: "granted ignored without cap", campaign: Campaign{Multiplier: 2}, accrual: 100, granted: 1000, bonus: 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if bonus := tt.campaign.Bonus(tt.accrual, tt.granted); bonus != tt.bonus {
+				t.Errorf("Expected bonus %v, got %v", tt.bonus, bonus)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// corsMaxAge is how long browsers may cache preflight responses, in seconds
+const corsMaxAge = "600"
+
+// corsPolicy allows browsers to make cross-origin requests from the allowed origins.
+// Rules are changed on config reload while requests are served.
+type corsPolicy struct {
+	mu      sync.RWMutex
+	origins map[string]bool
+	methods string
+	headers string
+}
+
+func newCORSPolicy(origins []string, methods []string, headers []string) *corsPolicy {
+	p := &corsPolicy{}
+	p.Set(origins, methods, headers)
+
+	return p
+}
+
+// Set replaces the rules. Empty origins disable CORS.
+func (p *corsPolicy) Set(origins []string, methods []string, headers []string) {
+	allowed := make(map[string]bool, len(origins))
+	for _, origin := range origins {
+		allowed[origin] = true
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.origins = allowed
+	p.methods = strings.Join(methods, ", ")
+	p.headers = strings.Join(headers, ", ")
+}
+
+// Handle adds CORS headers to responses to allowed origins and answers preflight requests
+func (p *corsPolicy) Handle(ctx *gin.Context) {
+	origin := ctx.GetHeader("Origin")
+	if len(origin) == 0 {
+		ctx.Next()
+		return
+	}
+
+	p.mu.RLock()
+	anyOrigin, allowed := p.origins["*"], p.origins[origin]
+	methods, headers := p.methods, p.headers
+	p.mu.RUnlock()
+
+	h := ctx.Writer.Header()
+	h.Add("Vary", "Origin")
+
+	switch {
+	case allowed:
+		// Users are authenticated by cookie, so it's sent only to the listed origins
+		h.Set("Access-Control-Allow-Origin", origin)
+		h.Set("Access-Control-Allow-Credentials", "true")
+	case anyOrigin:
+		h.Set("Access-Control-Allow-Origin", "*")
+	default:
+		ctx.Next()
+		return
+	}
+
+	if ctx.Request.Method == http.MethodOptions && len(ctx.GetHeader("Access-Control-Request-Method")) > 0 {
+		h.Set("Access-Control-Allow-Methods", methods)
+		h.Set("Access-Control-Allow-Headers", headers)
+		h.Set("Access-Control-Max-Age", corsMaxAge)
+		ctx.AbortWithStatus(http.StatusNoContent)
+
+		return
+	}
+
+	ctx.Next()
+}
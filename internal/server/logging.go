@@ -14,6 +14,8 @@ import (
 type AppLogger struct {
 	Logger  *zap.SugaredLogger
 	LogFile *os.File
+	// Level of the logger, it can be changed while the app is running
+	Level zap.AtomicLevel
 }
 
 func LogTimeFormat(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
@@ -71,7 +73,7 @@ func LogInit(logLevel string, prefix string, fileName string) (AppLogger, error)
 
 	encoder := zapcore.NewConsoleEncoder(pe)
 
-	level, err := zapcore.ParseLevel(logLevel)
+	level, err := zap.ParseAtomicLevel(logLevel)
 	if err != nil {
 		return AppLogger{}, err
 	}
@@ -95,6 +97,7 @@ func LogInit(logLevel string, prefix string, fileName string) (AppLogger, error)
 	a := AppLogger{
 		LogFile: logFile,
 		Logger:  l.Sugar(),
+		Level:   level,
 	}
 
 	return a, nil
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/fuzzy-toozy/gophermart/internal/config"
+	"go.uber.org/zap/zapcore"
+)
+
+// reloadableOptions are config fields applied on SIGHUP without restart.
+// Config changing other fields is refused as a whole.
+var reloadableOptions = map[string]bool{
+	"LogLevel":           true,
+	"AccrualAddress":     true,
+	"AccrualRateLimit":   true,
+	"ProcessingInteval":  true,
+	"CORSAllowedOrigins": true,
+	"CORSAllowedMethods": true,
+	"CORSAllowedHeaders": true,
+	// Command line isn't changed by reload
+	"Args": true,
+}
+
+// reloadConfig reads config again and applies changes of reloadable options
+func (s *Server) reloadConfig() {
+	s.logger.Infof("Reloading config")
+
+	c, err := config.ParseConfig(os.Args[0], os.Args[1:])
+	if err != nil {
+		s.logger.Errorf("Failed to reload config, keeping current one: %v", err)
+		return
+	}
+
+	c.ServerAddress = strings.TrimPrefix(c.ServerAddress, "http://")
+
+	if names := restartRequired(&s.applied, c); len(names) > 0 {
+		s.logger.Errorf("Failed to reload config, keeping current one: options %v can't be changed without restart",
+			strings.Join(names, ", "))
+		return
+	}
+
+	changed := 0
+
+	if c.LogLevel != s.applied.LogLevel {
+		level, err := zapcore.ParseLevel(c.LogLevel)
+		if err != nil {
+			s.logger.Errorf("Failed to change log level: %v", err)
+		} else {
+			s.logger.Infof("Log level changed from %v to %v", s.applied.LogLevel, c.LogLevel)
+			s.logLevel.SetLevel(level)
+			s.applied.LogLevel = c.LogLevel
+			changed++
+		}
+	}
+
+	if c.AccrualAddress != s.applied.AccrualAddress {
+		s.logger.Infof("Accrual address changed from %v to %v", s.applied.AccrualAddress, c.AccrualAddress)
+		s.accrualService.SetAddress(c.AccrualAddress)
+		s.applied.AccrualAddress = c.AccrualAddress
+		changed++
+	}
+
+	if c.ProcessingInteval != s.applied.ProcessingInteval {
+		s.logger.Infof("Processing interval changed from %v to %v", s.applied.ProcessingInteval, c.ProcessingInteval)
+		// Only the latest interval matters if processor hasn't taken the previous one yet
+		select {
+		case <-s.processInterval:
+		default:
+		}
+		s.processInterval <- c.ProcessingInteval
+		s.applied.ProcessingInteval = c.ProcessingInteval
+		changed++
+	}
+
+	if c.AccrualRateLimit != s.applied.AccrualRateLimit {
+		s.logger.Infof("Accrual rate limit changed from %v to %v", s.applied.AccrualRateLimit, c.AccrualRateLimit)
+		s.accrualService.SetRateLimit(c.AccrualRateLimit)
+		s.applied.AccrualRateLimit = c.AccrualRateLimit
+		changed++
+	}
+
+	if !reflect.DeepEqual(c.CORSAllowedOrigins, s.applied.CORSAllowedOrigins) ||
+		!reflect.DeepEqual(c.CORSAllowedMethods, s.applied.CORSAllowedMethods) ||
+		!reflect.DeepEqual(c.CORSAllowedHeaders, s.applied.CORSAllowedHeaders) {
+		s.logger.Infof("CORS allowed origins changed to %v, methods to %v, headers to %v",
+			c.CORSAllowedOrigins, c.CORSAllowedMethods, c.CORSAllowedHeaders)
+		s.cors.Set(c.CORSAllowedOrigins, c.CORSAllowedMethods, c.CORSAllowedHeaders)
+		s.applied.CORSAllowedOrigins = c.CORSAllowedOrigins
+		s.applied.CORSAllowedMethods = c.CORSAllowedMethods
+		s.applied.CORSAllowedHeaders = c.CORSAllowedHeaders
+		changed++
+	}
+
+	s.logger.Infof("Config reloaded, %v options changed", changed)
+}
+
+// restartRequired returns names of changed config fields which aren't reloadable
+func restartRequired(old *config.Config, new *config.Config) []string {
+	names := make([]string, 0)
+	for _, name := range changedOptions(old, new) {
+		if !reloadableOptions[name] {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// changedOptions returns names of config fields that differ
+func changedOptions(old *config.Config, new *config.Config) []string {
+	oldValue := reflect.ValueOf(old).Elem()
+	newValue := reflect.ValueOf(new).Elem()
+
+	names := make([]string, 0)
+	for i := 0; i < oldValue.NumField(); i++ {
+		if !reflect.DeepEqual(oldValue.Field(i).Interface(), newValue.Field(i).Interface()) {
+			names = append(names, oldValue.Type().Field(i).Name)
+		}
+	}
+
+	return names
+}
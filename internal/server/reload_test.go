@@ -0,0 +1,79 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/fuzzy-toozy/gophermart/internal/config"
+)
+
+func TestReloadableOptionsAreConfigFields(t *testing.T) {
+	configType := reflect.TypeOf(config.Config{})
+
+	for name := range reloadableOptions {
+		if _, ok := configType.FieldByName(name); !ok {
+			t.Errorf("Reloadable option %v is not a config field", name)
+		}
+	}
+}
+
+func TestRestartRequired(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  func(c *config.Config)
+		options []string
+	}{
+		{name: "no changes", change: func(c *config.Config) {}},
+		{name: "log level", change: func(c *config.Config) { c.LogLevel = "debug" }},
+		{name: "accrual", change: func(c *config.Config) {
+			c.AccrualAddress = "http://localhost:9090/api/orders/"
+			c.AccrualRateLimit = 10
+			c.ProcessingInteval = 5 * time.Second
+		}},
+		{name: "cors", change: func(c *config.Config) {
+			c.CORSAllowedOrigins = []string{"https://example.com"}
+			c.CORSAllowedMethods = []string{"GET"}
+			c.CORSAllowedHeaders = []string{"Content-Type"}
+		}},
+		{name: "args", change: func(c *config.Config) { c.Args = []string{"migrate", "up"} }},
+		{name: "address", change: func(c *config.Config) { c.ServerAddress = "localhost:9000" },
+			options: []string{"ServerAddress"}},
+		{name: "secret key", change: func(c *config.Config) { c.SecretKey = []byte("other") },
+			options: []string{"SecretKey"}},
+		{name: "database", change: func(c *config.Config) { c.DatabaseConfig.ConnURI = "memory://" },
+			options: []string{"DatabaseConfig"}},
+		{name: "reloadable and not reloadable", change: func(c *config.Config) {
+			c.LogLevel = "debug"
+			c.WithdrawMax = 100
+			c.OutboxSinks = []string{"file"}
+		}, options: []string{"WithdrawMax", "OutboxSinks"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			old := config.Config{
+				ServerAddress:      "localhost:8080",
+				AccrualAddress:     "http://localhost:8081/api/orders/",
+				LogLevel:           "info",
+				SecretKey:          []byte("secret"),
+				ProcessingInteval:  time.Second,
+				CORSAllowedOrigins: []string{},
+				CORSAllowedMethods: []string{"GET", "POST"},
+				CORSAllowedHeaders: []string{"Content-Type", "Authorization"},
+				OutboxSinks:        []string{"log"},
+				Args:               []string{},
+			}
+			old.DatabaseConfig.ConnURI = "sqlite3://gophermart.db"
+
+			new := old
+			new.SecretKey = append([]byte(nil), old.SecretKey...)
+			tt.change(&new)
+
+			options := restartRequired(&old, &new)
+			if len(options) != len(tt.options) || len(options) > 0 && !reflect.DeepEqual(options, tt.options) {
+				t.Errorf("Expected options %v to require restart, got %v", tt.options, options)
+			}
+		})
+	}
+}
@@ -17,6 +17,7 @@ import (
 
 	"github.com/fuzzy-toozy/gophermart/internal/database"
 	"github.com/fuzzy-toozy/gophermart/internal/database/repo"
+	"github.com/fuzzy-toozy/gophermart/internal/events"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 	"golang.org/x/sync/errgroup"
@@ -24,28 +25,192 @@ import (
 
 type Server struct {
 	logger            *zap.SugaredLogger
+	logLevel          zap.AtomicLevel
 	config            *config.Config
 	stopCtx           context.Context
 	stopFunc          context.CancelFunc
-	serviceStorage    *database.ServiceStorage
+	serviceStorage    database.Storage
 	userConroller     *controllers.UserController
 	balanceController *controllers.BalanceContoller
 	ordersController  *controllers.OrderController
 	processController *controllers.ProcessContoller
+	idempController   *controllers.IdempotencyController
+	adminController   *controllers.AdminController
+	holdController    *controllers.HoldController
+	transController   *controllers.TransferController
+	campController    *controllers.CampaignController
+	rewardController  *controllers.RewardController
+	promoController   *controllers.PromoController
+	webhookController *controllers.WebhookController
+	eventsController  *controllers.EventsController
 	processService    *services.ProcessingService
-	router            *gin.Engine
-	httpServer        *http.Server
+	accrualService    *services.AccrualService
+	// New processing interval is sent to the processor on config reload
+	processInterval  chan time.Duration
+	holdService      *services.HoldService
+	expiryService    *services.ExpiryService
+	reconcileService *services.ReconciliationService
+	tierService      *services.TierService
+	webhookService   *services.WebhookService
+	outboxService    *services.OutboxService
+	healthController *controllers.HealthController
+	router           *gin.Engine
+	httpServer       *http.Server
+	cors             *corsPolicy
+	// Config with reloaded options. It's used by config reload only, so config
+	// isn't changed while jobs read it.
+	applied config.Config
 }
 
+func (s *Server) processOrders(ctx context.Context) {
+	err := s.processService.ProcessOrders(ctx)
+	if err != nil {
+		s.logger.Errorf("Failed to process orders: %v", err)
+	}
+}
+
+// processNewOrders processes the notified orders. All orders are processed
+// if notifications may be lost.
+func (s *Server) processNewOrders(ctx context.Context, numbers []string) {
+	for _, number := range numbers {
+		if number == database.LostNotifications {
+			s.processOrders(ctx)
+			return
+		}
+	}
+
+	for _, number := range numbers {
+		err := s.processService.ProcessOrder(ctx, number)
+		if err != nil {
+			s.logger.Errorf("Failed to process order '%v': %v", number, err)
+		}
+	}
+}
+
+// runProcessor processes orders as soon as they are uploaded. Ticker is kept for
+// orders waiting for accrual and for the case notifications are unavailable.
 func (s *Server) runProcessor(ctx context.Context) {
 	t := time.NewTicker(s.config.ProcessingInteval)
 
+	newOrders, err := s.serviceStorage.Listen(ctx, database.NewOrdersChannel, s.logger)
+	if err != nil {
+		s.logger.Errorf("Failed to listen for new orders, falling back to polling: %v", err)
+	}
+
+	for {
+		select {
+		case numbers := <-newOrders:
+			s.processNewOrders(ctx, numbers)
+		case d := <-s.processInterval:
+			t.Reset(d)
+		case <-t.C:
+			s.processOrders(ctx)
+
+			expired, err := s.holdService.ExpireHolds(ctx)
+			if err != nil {
+				s.logger.Errorf("Failed to expire holds: %v", err)
+			} else if expired > 0 {
+				s.logger.Debugf("Expired %v holds", expired)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *Server) runExpiry(ctx context.Context) {
+	if !s.expiryService.Enabled() {
+		return
+	}
+
+	t := time.NewTicker(s.config.ExpiryInterval)
+
+	for {
+		select {
+		case <-t.C:
+			err := s.expiryService.ExpirePoints(ctx)
+			if err != nil {
+				s.logger.Errorf("Failed to expire points: %v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *Server) runReconciliation(ctx context.Context) {
+	t := time.NewTicker(s.config.ReconcileInterval)
+
+	for {
+		select {
+		case <-t.C:
+			drifts, err := s.reconcileService.Reconcile(ctx)
+			if err != nil {
+				s.logger.Errorf("Failed to reconcile balances: %v", err)
+			} else if drifts > 0 {
+				s.logger.Errorf("Found %v user balances drifted from ledger", drifts)
+			}
+
+			violations, err := s.reconcileService.CheckLedger(ctx)
+			if err != nil {
+				s.logger.Errorf("Failed to check ledger: %v", err)
+			} else if violations > 0 {
+				s.logger.Errorf("Found %v unbalanced ledger transactions", violations)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *Server) runTiers(ctx context.Context) {
+	t := time.NewTicker(s.config.TierInterval)
+
+	for {
+		select {
+		case <-t.C:
+			changed, err := s.tierService.RecalculateTiers(ctx)
+			if err != nil {
+				s.logger.Errorf("Failed to recalculate tiers: %v", err)
+			} else if changed > 0 {
+				s.logger.Debugf("Changed tiers of %v users", changed)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *Server) runWebhooks(ctx context.Context) {
+	t := time.NewTicker(s.config.WebhookInterval)
+
 	for {
 		select {
 		case <-t.C:
-			err := s.processService.ProcessOrders(ctx)
+			delivered, err := s.webhookService.DeliverPending(ctx)
 			if err != nil {
-				s.logger.Errorf("Failed to process orders: %v", err)
+				s.logger.Errorf("Failed to deliver webhooks: %v", err)
+			} else if delivered > 0 {
+				s.logger.Debugf("Delivered %v webhooks", delivered)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *Server) runOutbox(ctx context.Context) {
+	t := time.NewTicker(s.config.OutboxInterval)
+
+	for {
+		select {
+		case <-t.C:
+			published, err := s.outboxService.Relay(ctx)
+			if err != nil {
+				s.logger.Errorf("Failed to relay outbox: %v", err)
+			}
+			if published > 0 {
+				s.logger.Debugf("Relayed %v outbox events", published)
 			}
 		case <-ctx.Done():
 			return
@@ -81,14 +246,57 @@ func (s *Server) Run() {
 		cancel()
 	}()
 
+	go func() {
+		c := make(chan os.Signal, 1)
+		signal.Notify(c, syscall.SIGHUP)
+		defer signal.Stop(c)
+
+		for {
+			select {
+			case <-c:
+				s.reloadConfig()
+			case <-stopCtx.Done():
+				return
+			}
+		}
+	}()
+
 	g, gCtx := errgroup.WithContext(stopCtx)
 
+	// Background jobs process more data than requests, so they have their own query timeout
+	bgCtx := database.WithQueryTimeout(gCtx, s.config.BackgroundQueryTimeout)
+
 	g.Go(func() error {
 		return start()
 	})
 
 	g.Go(func() error {
-		s.runProcessor(gCtx)
+		s.runProcessor(bgCtx)
+		return nil
+	})
+
+	g.Go(func() error {
+		s.runExpiry(bgCtx)
+		return nil
+	})
+
+	g.Go(func() error {
+		s.runReconciliation(bgCtx)
+		return nil
+	})
+
+	g.Go(func() error {
+		s.runTiers(bgCtx)
+		return nil
+	})
+
+	g.Go(func() error {
+		s.runWebhooks(bgCtx)
+		return nil
+	})
+
+	g.Go(func() error {
+		s.runOutbox(bgCtx)
 		return nil
 	})
 
@@ -104,9 +312,18 @@ func (s *Server) Run() {
 	}
 }
 
+// limitBody makes reading request body larger than maxSize fail
+func limitBody(maxSize uint64) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, int64(maxSize))
+		ctx.Next()
+	}
+}
+
 func (s *Server) setupRouting() {
 	s.router.POST("/api/user/register", s.userConroller.Register)
 	s.router.POST("/api/user/login", s.userConroller.Login)
+	s.router.GET("/api/health", s.healthController.Check)
 
 	authGrp := s.router.Group("/api/user")
 	authGrp.Use(s.userConroller.Authenticate)
@@ -114,53 +331,162 @@ func (s *Server) setupRouting() {
 		authGrp.GET("/orders", s.ordersController.GetAllOrders)
 		authGrp.GET("/withdrawals", s.balanceController.GetWithdrawals)
 		authGrp.GET("/balance", s.balanceController.GetBalanceData)
+		authGrp.GET("/balance/holds", s.holdController.GetAllHolds)
+		authGrp.GET("/balance/holds/:id", s.holdController.GetHold)
+		authGrp.GET("/balance/transfers", s.transController.GetAllTransfers)
+		authGrp.GET("/referral", s.userConroller.GetReferralInfo)
+		authGrp.GET("/rewards", s.rewardController.GetAllRewards)
+		authGrp.GET("/rewards/redemptions", s.rewardController.GetAllRedemptions)
+		authGrp.GET("/events", s.eventsController.Stream)
+
+		authGrp.POST("/orders", s.idempController.Handle, s.ordersController.AddNewOrder)
+		authGrp.POST("/balance/withdraw", s.idempController.Handle, s.processController.Withdraw)
+		authGrp.POST("/balance/holds", s.idempController.Handle, s.holdController.PlaceHold)
+		authGrp.POST("/balance/holds/:id/capture", s.idempController.Handle, s.holdController.CaptureHold)
+		authGrp.POST("/balance/holds/:id/release", s.holdController.ReleaseHold)
+		authGrp.POST("/balance/transfer", s.idempController.Handle, s.transController.Transfer)
+		authGrp.POST("/rewards/:id/redeem", s.idempController.Handle, s.rewardController.Redeem)
+		authGrp.POST("/promo", s.idempController.Handle, s.promoController.Redeem)
+	}
 
-		authGrp.POST("/orders", s.ordersController.AddNewOrder)
-		authGrp.POST("/balance/withdraw", s.processController.Withdraw)
+	adminGrp := s.router.Group("/api/admin")
+	adminGrp.Use(s.adminController.Authenticate)
+	{
+		adminGrp.POST("/withdrawals/:number/complete", s.processController.CompleteWithdrawal)
+		adminGrp.POST("/withdrawals/:number/cancel", s.processController.CancelWithdrawal)
+		adminGrp.POST("/users/:login/adjustments", s.processController.AdjustBalance)
+
+		adminGrp.GET("/campaigns", s.campController.GetAllCampaigns)
+		adminGrp.GET("/campaigns/:id", s.campController.GetCampaign)
+		adminGrp.POST("/campaigns", s.campController.AddCampaign)
+		adminGrp.PUT("/campaigns/:id", s.campController.UpdateCampaign)
+		adminGrp.DELETE("/campaigns/:id", s.campController.DeleteCampaign)
+
+		adminGrp.GET("/rewards", s.rewardController.GetAllRewards)
+		adminGrp.GET("/rewards/:id", s.rewardController.GetReward)
+		adminGrp.POST("/rewards", s.rewardController.AddReward)
+		adminGrp.PUT("/rewards/:id", s.rewardController.UpdateReward)
+		adminGrp.DELETE("/rewards/:id", s.rewardController.DeleteReward)
+
+		adminGrp.GET("/promo", s.promoController.GetAllPromoCodes)
+		adminGrp.POST("/promo", s.promoController.GeneratePromoCodes)
+
+		adminGrp.GET("/webhooks", s.webhookController.GetAllSubscriptions)
+		adminGrp.GET("/webhooks/:id/deliveries", s.webhookController.GetDeliveryLog)
+		adminGrp.POST("/webhooks", s.webhookController.AddSubscription)
+		adminGrp.DELETE("/webhooks/:id", s.webhookController.DeleteSubscription)
+
+		adminGrp.GET("/db/stats", s.healthController.GetPoolStats)
 	}
 }
 
 func NewServer(c *config.Config, l AppLogger) (*Server, error) {
-	err := database.Migrate(c.DatabaseConfig.ConnURI)
+	repos, err := repo.OpenRepositories(context.Background(), c.DatabaseConfig, c.AutoMigrate, l.Logger)
 	if err != nil {
-		return nil, fmt.Errorf("failed db migration: %v", err)
+		return nil, err
 	}
 
 	gin.DefaultWriter = io.MultiWriter(l.LogFile, os.Stdout)
 
-	serviceStorage, err := database.NewServiceStorage(c.DatabaseConfig)
-	if err != nil {
-		return nil, fmt.Errorf("failed to setup repository: %v", err)
-	}
-
-	userRepo := repo.NewUserServiceRepo(*serviceStorage)
-	userService := services.NewUserService(userRepo, services.NewTokenService(c.SecretKey, c.TokenLifetime))
+	userService := services.NewUserService(repos.User, services.NewTokenService(c.SecretKey, c.TokenLifetime))
 	userController := controllers.NewUserController(userService, l.Logger)
 
-	orderRepo := repo.NewOrderServiceRepo(serviceStorage)
-	orderService := services.NewOrderService(orderRepo)
+	orderService := services.NewOrderService(repos.Order)
 	orderController := controllers.NewOrderController(orderService, l.Logger)
 
-	balanceRepo := repo.NewBalanceServiceRepo(serviceStorage)
-	balanceService := services.NewBalanceService(balanceRepo)
+	expiryPolicy := services.ExpiryPolicy{
+		LifetimeMonths: c.PointsLifetime,
+		WarningPeriod:  c.PointsExpiryWarning,
+	}
+
+	tierService := services.NewTierService(repos.Tier, services.TierPolicy{
+		SilverThreshold:  c.TierSilverThreshold,
+		GoldThreshold:    c.TierGoldThreshold,
+		SilverMultiplier: c.TierSilverMultiplier,
+		GoldMultiplier:   c.TierGoldMultiplier,
+	}, l.Logger)
+
+	balanceService := services.NewBalanceService(repos.Balance, repos.Withdrawal, repos.Tier, expiryPolicy)
 	balanceController := controllers.NewBalanceController(balanceService, l.Logger)
 
-	processRepo := repo.NewProcessRepo(serviceStorage, balanceRepo, orderRepo)
-	accrualService := services.NewAccrualService(&http.Client{}, c.AccrualAddress, l.Logger)
-	processService := services.NewProcessingService(processRepo, accrualService, l.Logger)
+	eventBus := events.NewBus(c.EventBufferSize)
+
+	outboxSinks, err := services.NewOutboxSinks(services.OutboxSinkConfig{
+		Sinks:   c.OutboxSinks,
+		URL:     c.OutboxURL,
+		File:    c.OutboxFile,
+		Timeout: c.OutboxTimeout,
+	}, l.Logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to setup outbox sinks: %v", err)
+	}
+
+	processRepo := repo.NewProcessRepo(repos.Storage, repos.Balance, repos.Order, repos.Withdrawal, repos.Hold,
+		repos.Transfer, repos.Campaign, repos.Referral, repos.Reward, repos.Promo, repos.Outbox, eventBus)
+	webhookService := services.NewWebhookService(repos.Webhook, services.WebhookPolicy{
+		Timeout:     c.WebhookTimeout,
+		MaxAttempts: c.WebhookMaxAttempts,
+	}, l.Logger)
+
+	// Webhook deliveries are queued for events committed to the outbox
+	outboxSinks = append([]services.OutboxSink{webhookService.Sink()}, outboxSinks...)
+
+	accrualService := services.NewAccrualService(&http.Client{}, c.AccrualAddress, c.AccrualRateLimit, l.Logger)
+	withdrawalLimits := services.WithdrawalLimits{
+		Min:     c.WithdrawMin,
+		Max:     c.WithdrawMax,
+		Daily:   c.WithdrawDaily,
+		Monthly: c.WithdrawMonthly,
+	}
+	processService := services.NewProcessingService(processRepo, accrualService, tierService, withdrawalLimits,
+		services.ReferralPolicy{ReferrerBonus: c.ReferralBonus, RefereeBonus: c.ReferralRefereeBonus}, eventBus, l.Logger)
 	procesController := controllers.NewProcessController(processService, l.Logger)
 
+	expiryService := services.NewExpiryService(processRepo, expiryPolicy, l.Logger)
+
+	holdService := services.NewHoldService(repos.Hold, processRepo, withdrawalLimits, c.HoldTTL)
+	holdController := controllers.NewHoldController(holdService, l.Logger)
+
+	transferService := services.NewTransferService(repos.Transfer, processRepo, c.TransferDailyLimit)
+	transferController := controllers.NewTransferController(transferService, l.Logger)
+
+	idempService := services.NewIdempotencyService(repos.Idempotency, c.IdempotencyKeyTTL, c.IdempotencyLease)
+	idempController := controllers.NewIdempotencyController(idempService, l.Logger)
+
 	c.ServerAddress = strings.TrimPrefix(c.ServerAddress, "http://")
 
+	outboxService := services.NewOutboxService(repos.Outbox, outboxSinks, c.OutboxTimeout, c.OutboxRetention, l.Logger)
+
 	s := Server{
 		config:            c,
+		applied:           *c,
+		cors:              newCORSPolicy(c.CORSAllowedOrigins, c.CORSAllowedMethods, c.CORSAllowedHeaders),
 		logger:            l.Logger,
-		serviceStorage:    serviceStorage,
+		logLevel:          l.Level,
+		serviceStorage:    repos.Storage,
 		userConroller:     userController,
 		ordersController:  orderController,
 		balanceController: balanceController,
 		processController: procesController,
+		idempController:   idempController,
+		adminController:   controllers.NewAdminController(c.AdminKey, l.Logger),
 		processService:    processService,
+		accrualService:    accrualService,
+		processInterval:   make(chan time.Duration, 1),
+		holdController:    holdController,
+		transController:   transferController,
+		campController:    controllers.NewCampaignController(services.NewCampaignService(repos.Campaign), l.Logger),
+		rewardController:  controllers.NewRewardController(services.NewRewardService(repos.Reward, processRepo), l.Logger),
+		promoController:   controllers.NewPromoController(services.NewPromoService(repos.Promo, processRepo), l.Logger),
+		holdService:       holdService,
+		expiryService:     expiryService,
+		reconcileService:  services.NewReconciliationService(repos.Balance, l.Logger),
+		tierService:       tierService,
+		webhookService:    webhookService,
+		webhookController: controllers.NewWebhookController(webhookService, l.Logger),
+		eventsController:  controllers.NewEventsController(eventBus, c.EventsHeartbeat, l.Logger),
+		healthController:  controllers.NewHealthController(services.NewHealthService(repos.Health), l.Logger),
+		outboxService:     outboxService,
 		router:            gin.Default(),
 	}
 
@@ -172,6 +498,10 @@ func NewServer(c *config.Config, l AppLogger) (*Server, error) {
 		Handler:      s.router,
 	}
 
+	// Handlers pass gin context to repositories, so queries are cancelled with the request
+	s.router.ContextWithFallback = true
+	s.router.Use(s.cors.Handle, limitBody(c.MaxBodySize))
+
 	s.setupRouting()
 
 	s.httpServer = &httpServer
@@ -1,18 +1,22 @@
 package services
 
 import (
+	"context"
 	"encoding/json"
 	"io"
 	"net/http"
+	"sync"
 
 	serviceErrs "github.com/fuzzy-toozy/gophermart/internal/errors"
 	"go.uber.org/zap"
 )
 
 type AccrualService struct {
-	client *http.Client
-	addr   string
-	logger *zap.SugaredLogger
+	client  *http.Client
+	addrMu  sync.RWMutex
+	addr    string
+	limiter *rateLimiter
+	logger  *zap.SugaredLogger
 }
 
 type AccrualOrderInfo struct {
@@ -26,19 +30,47 @@ var (
 	ErrAccuralInternal   = "accural service internal error"
 )
 
-func NewAccrualService(client *http.Client, addr string, logger *zap.SugaredLogger) *AccrualService {
+// NewAccrualService creates service requesting accrual system at addr at most rateLimit
+// times per second. Zero rateLimit disables the limit.
+func NewAccrualService(client *http.Client, addr string, rateLimit float64, logger *zap.SugaredLogger) *AccrualService {
 	return &AccrualService{
-		client: client,
-		addr:   addr,
-		logger: logger,
+		client:  client,
+		addr:    addr,
+		limiter: newRateLimiter(rateLimit),
+		logger:  logger,
 	}
 }
 
-func (s *AccrualService) GetOrderInfo(orderNumber string) (*AccrualOrderInfo, error) {
+// Address returns the accrual system address orders are requested from
+func (s *AccrualService) Address() string {
+	s.addrMu.RLock()
+	defer s.addrMu.RUnlock()
+
+	return s.addr
+}
+
+// SetAddress changes the accrual system address, requests in progress are not affected
+func (s *AccrualService) SetAddress(addr string) {
+	s.addrMu.Lock()
+	defer s.addrMu.Unlock()
+
+	s.addr = addr
+}
+
+// SetRateLimit changes the max number of requests per second, zero disables the limit
+func (s *AccrualService) SetRateLimit(rateLimit float64) {
+	s.limiter.SetRate(rateLimit)
+}
+
+func (s *AccrualService) GetOrderInfo(ctx context.Context, orderNumber string) (*AccrualOrderInfo, error) {
+	if err := s.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+
 	client := http.Client{}
-	reqURL := s.addr + orderNumber
+	reqURL := s.Address() + orderNumber
 
-	req, err := http.NewRequest(http.MethodGet, reqURL, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -3,6 +3,7 @@ package services
 import (
 	"context"
 	"net/http"
+	"time"
 
 	"github.com/fuzzy-toozy/gophermart/internal/database/repo"
 	serviceErrs "github.com/fuzzy-toozy/gophermart/internal/errors"
@@ -10,12 +11,19 @@ import (
 )
 
 type BalanceService struct {
-	repo repo.BalanceServiceRepo
+	repo           repo.BalanceServiceRepo
+	withdrawalRepo repo.WithdrawalServiceRepo
+	tierRepo       repo.TierServiceRepo
+	expiryPolicy   ExpiryPolicy
 }
 
-func NewBalanceService(repo repo.BalanceServiceRepo) *BalanceService {
+func NewBalanceService(repo repo.BalanceServiceRepo, withdrawalRepo repo.WithdrawalServiceRepo,
+	tierRepo repo.TierServiceRepo, expiryPolicy ExpiryPolicy) *BalanceService {
 	return &BalanceService{
-		repo: repo,
+		repo:           repo,
+		withdrawalRepo: withdrawalRepo,
+		tierRepo:       tierRepo,
+		expiryPolicy:   expiryPolicy,
 	}
 }
 
@@ -27,11 +35,37 @@ func (s *BalanceService) GetUserBalance(ctx context.Context, username string) (*
 			"failed to get balance from db: %w", err)
 	}
 
+	tier, err := s.tierRepo.GetUserTier(ctx, username)
+	if err != nil {
+		return nil, serviceErrs.NewServiceError(http.StatusInternalServerError,
+			"failed to get tier from db: %w", err)
+	}
+
+	balance.Tier = tier.Tier
+	balance.LifetimeAccrued = tier.LifetimeAccrued
+
+	if !s.expiryPolicy.Enabled() {
+		return balance, nil
+	}
+
+	before := time.Now().Add(s.expiryPolicy.WarningPeriod)
+
+	expiring, err := s.repo.GetExpirablePoints(ctx, username, s.expiryPolicy.Cutoff(before))
+	if err != nil {
+		return nil, serviceErrs.NewServiceError(http.StatusInternalServerError,
+			"failed to get expiring points from db: %w", err)
+	}
+
+	if expiring > 0 {
+		balance.ExpiringSoon = expiring
+		balance.ExpiringBefore = &before
+	}
+
 	return balance, nil
 }
 
-func (s *BalanceService) GetAllUserWithdrawals(ctx context.Context, username string) ([]models.Withdrawals, serviceErrs.ServiceError) {
-	result, err := s.repo.GetWithdrawals(ctx, username)
+func (s *BalanceService) GetAllUserWithdrawals(ctx context.Context, username string) ([]models.Withdrawal, serviceErrs.ServiceError) {
+	result, err := s.withdrawalRepo.GetAllUserWithdrawals(ctx, username)
 	if err != nil {
 		return nil, serviceErrs.NewServiceError(http.StatusInternalServerError,
 			"failed to get withdrawals data: %w", err)
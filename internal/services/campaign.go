@@ -0,0 +1,128 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/fuzzy-toozy/gophermart/internal/database/repo"
+	serviceErrs "github.com/fuzzy-toozy/gophermart/internal/errors"
+	"github.com/fuzzy-toozy/gophermart/internal/models"
+)
+
+type CampaignService struct {
+	repo repo.CampaignServiceRepo
+}
+
+func NewCampaignService(repo repo.CampaignServiceRepo) *CampaignService {
+	return &CampaignService{
+		repo: repo,
+	}
+}
+
+func validateCampaign(campaign *models.Campaign) serviceErrs.ServiceError {
+	if campaign.Multiplier == 0 {
+		campaign.Multiplier = 1
+	}
+
+	switch {
+	case !campaign.StartsAt.Before(campaign.EndsAt):
+		return serviceErrs.NewServiceError(http.StatusBadRequest, "campaign must start before it ends")
+	case campaign.Multiplier < 1:
+		return serviceErrs.NewServiceError(http.StatusBadRequest, "campaign multiplier can't be less than 1")
+	case campaign.FixedBonus < 0 || campaign.FirstOrders < 0 || campaign.UserCap < 0:
+		return serviceErrs.NewServiceError(http.StatusBadRequest, "campaign rules can't be negative")
+	case campaign.Multiplier == 1 && campaign.FixedBonus == 0:
+		return serviceErrs.NewServiceError(http.StatusBadRequest, "campaign grants no bonus")
+	}
+
+	return nil
+}
+
+func (s *CampaignService) AddCampaign(ctx context.Context, campaign *models.Campaign) serviceErrs.ServiceError {
+	if serr := validateCampaign(campaign); serr != nil {
+		return serr
+	}
+
+	campaign.CreatedAt = time.Now()
+	campaign.UpdatedAt = campaign.CreatedAt
+
+	err := s.repo.AddNewCampaign(ctx, campaign)
+	if err != nil {
+		return serviceErrs.NewServiceError(http.StatusInternalServerError, "failed to add campaign: %w", err)
+	}
+
+	return nil
+}
+
+func (s *CampaignService) GetCampaign(ctx context.Context, id string) (*models.Campaign, serviceErrs.ServiceError) {
+	if !IsUUID(id) {
+		return nil, serviceErrs.NewServiceError(http.StatusNotFound, "invalid campaign id '%v'", id)
+	}
+
+	campaign, err := s.repo.GetCampaignByID(ctx, id)
+	if err != nil {
+		return nil, serviceErrs.NewServiceError(http.StatusInternalServerError,
+			"failed to get campaign '%v' from db: %w", id, err)
+	}
+
+	if len(campaign.ID) == 0 {
+		return nil, serviceErrs.NewServiceError(http.StatusNotFound, "campaign '%v' not found", id)
+	}
+
+	return campaign, nil
+}
+
+func (s *CampaignService) GetAllCampaigns(ctx context.Context) ([]models.Campaign, serviceErrs.ServiceError) {
+	campaigns, err := s.repo.GetAllCampaigns(ctx)
+	if err != nil {
+		return nil, serviceErrs.NewServiceError(http.StatusInternalServerError, "failed to get all campaigns: %w", err)
+	}
+
+	if len(campaigns) == 0 {
+		return nil, serviceErrs.NewServiceError(http.StatusNoContent, "no campaigns found")
+	}
+
+	return campaigns, nil
+}
+
+func (s *CampaignService) UpdateCampaign(ctx context.Context, id string, campaign *models.Campaign) serviceErrs.ServiceError {
+	if !IsUUID(id) {
+		return serviceErrs.NewServiceError(http.StatusNotFound, "invalid campaign id '%v'", id)
+	}
+
+	if serr := validateCampaign(campaign); serr != nil {
+		return serr
+	}
+
+	campaign.ID = id
+	campaign.UpdatedAt = time.Now()
+
+	err := s.repo.UpdateCampaign(ctx, campaign)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, repo.ErrCampaignNotFound):
+		return serviceErrs.NewServiceError(http.StatusNotFound, "campaign '%v' not found", id)
+	default:
+		return serviceErrs.NewServiceError(http.StatusInternalServerError, "failed to update campaign '%v': %w", id, err)
+	}
+}
+
+// DeleteCampaign stops the campaign. Bonuses already granted stay in the ledger.
+func (s *CampaignService) DeleteCampaign(ctx context.Context, id string) serviceErrs.ServiceError {
+	if !IsUUID(id) {
+		return serviceErrs.NewServiceError(http.StatusNotFound, "invalid campaign id '%v'", id)
+	}
+
+	err := s.repo.DeleteCampaign(ctx, id)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, repo.ErrCampaignNotFound):
+		return serviceErrs.NewServiceError(http.StatusNotFound, "campaign '%v' not found", id)
+	default:
+		return serviceErrs.NewServiceError(http.StatusInternalServerError, "failed to delete campaign '%v': %w", id, err)
+	}
+}
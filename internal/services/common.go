@@ -1,5 +1,11 @@
 package services
 
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"fmt"
+)
+
 func LuhnCheck(number string) bool {
 	sum := number[len(number)-1] - '0'
 
@@ -16,3 +22,34 @@ func LuhnCheck(number string) bool {
 
 	return sum%10 == 0
 }
+
+func IsUUID(id string) bool {
+	if len(id) != 36 {
+		return false
+	}
+
+	for i, c := range id {
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return false
+			}
+		default:
+			if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F') {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// RandomCode returns a random human readable code. Every 5 bytes make 8 characters.
+func RandomCode(bytes int) (string, error) {
+	b := make([]byte, bytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate random code: %w", err)
+	}
+
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b), nil
+}
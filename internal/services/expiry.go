@@ -0,0 +1,72 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/fuzzy-toozy/gophermart/internal/database/repo"
+	serviceErrs "github.com/fuzzy-toozy/gophermart/internal/errors"
+	"go.uber.org/zap"
+)
+
+// ExpiryPolicy defines when credited points expire. Zero lifetime disables expiration.
+type ExpiryPolicy struct {
+	LifetimeMonths int
+	WarningPeriod  time.Duration
+}
+
+func (p ExpiryPolicy) Enabled() bool {
+	return p.LifetimeMonths > 0
+}
+
+// Cutoff returns the time points credited before are expired at the moment now
+func (p ExpiryPolicy) Cutoff(now time.Time) time.Time {
+	return now.AddDate(0, -p.LifetimeMonths, 0)
+}
+
+type ExpiryService struct {
+	repo   repo.ProcessServiceRepo
+	policy ExpiryPolicy
+	logger *zap.SugaredLogger
+}
+
+func NewExpiryService(repo repo.ProcessServiceRepo, policy ExpiryPolicy, logger *zap.SugaredLogger) *ExpiryService {
+	return &ExpiryService{
+		repo:   repo,
+		policy: policy,
+		logger: logger,
+	}
+}
+
+func (s *ExpiryService) Enabled() bool {
+	return s.policy.Enabled()
+}
+
+func (s *ExpiryService) ExpirePoints(ctx context.Context) serviceErrs.ServiceError {
+	if !s.policy.Enabled() {
+		return nil
+	}
+
+	cutoff := s.policy.Cutoff(time.Now())
+
+	users, err := s.repo.GetUsersWithExpirablePoints(ctx, cutoff)
+	if err != nil {
+		return serviceErrs.NewServiceError(http.StatusInternalServerError,
+			"failed to get users with expirable points: %w", err)
+	}
+
+	for _, username := range users {
+		expired, err := s.repo.ExpirePoints(ctx, username, cutoff)
+		if err != nil {
+			s.logger.Errorf("Failed to expire points for user '%v': %v", username, err)
+			continue
+		}
+
+		if expired > 0 {
+			s.logger.Debugf("Expired %v points of user '%v' credited before %v", expired, username, cutoff)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,39 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/fuzzy-toozy/gophermart/internal/database/repo"
+	serviceErrs "github.com/fuzzy-toozy/gophermart/internal/errors"
+	"github.com/fuzzy-toozy/gophermart/internal/models"
+)
+
+const healthPingTimeout = 2 * time.Second
+
+type HealthService struct {
+	repo repo.HealthServiceRepo
+}
+
+func NewHealthService(repo repo.HealthServiceRepo) *HealthService {
+	return &HealthService{
+		repo: repo,
+	}
+}
+
+// Check reports the service unavailable if the database can't be reached
+func (s *HealthService) Check(ctx context.Context) serviceErrs.ServiceError {
+	ctx, cancel := context.WithTimeout(ctx, healthPingTimeout)
+	defer cancel()
+
+	if err := s.repo.Ping(ctx); err != nil {
+		return serviceErrs.NewServiceError(http.StatusServiceUnavailable, "database is unreachable: %w", err)
+	}
+
+	return nil
+}
+
+func (s *HealthService) GetPoolStats() *models.PoolStats {
+	return s.repo.GetPoolStats()
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/fuzzy-toozy/gophermart/internal/database/repo"
+	serviceErrs "github.com/fuzzy-toozy/gophermart/internal/errors"
+	"github.com/fuzzy-toozy/gophermart/internal/models"
+)
+
+type HoldService struct {
+	repo        repo.HoldServiceRepo
+	processRepo repo.ProcessServiceRepo
+	limits      WithdrawalLimits
+	ttl         time.Duration
+}
+
+func NewHoldService(repo repo.HoldServiceRepo, processRepo repo.ProcessServiceRepo,
+	limits WithdrawalLimits, ttl time.Duration) *HoldService {
+	return &HoldService{
+		repo:        repo,
+		processRepo: processRepo,
+		limits:      limits,
+		ttl:         ttl,
+	}
+}
+
+func holdError(id string, err error) serviceErrs.ServiceError {
+	switch {
+	case errors.Is(err, repo.ErrHoldNotFound):
+		return serviceErrs.NewServiceError(http.StatusNotFound, "hold '%v' not found", id)
+	case errors.Is(err, repo.ErrHoldNotActive):
+		return serviceErrs.NewServiceError(http.StatusConflict, "hold '%v' is not active", id)
+	case errors.Is(err, repo.ErrWithdrawUnavailable):
+		return serviceErrs.NewServiceError(http.StatusPaymentRequired, "not enough funds")
+	case errors.Is(err, repo.ErrLimitExceeded):
+		return limitExceededError(err)
+	case errors.Is(err, repo.ErrWithdrawExists):
+		return serviceErrs.NewServiceError(http.StatusConflict, "withdrawal for the order already exists")
+	case errors.Is(err, repo.ErrOrderNumberUsed):
+		return serviceErrs.NewServiceError(http.StatusUnprocessableEntity, "order number is already uploaded for accrual")
+	default:
+		return serviceErrs.NewServiceError(http.StatusInternalServerError, "failed to process hold '%v': %w", id, err)
+	}
+}
+
+func (s *HoldService) PlaceHold(ctx context.Context, req *models.HoldRequest, username string) (*models.Hold, serviceErrs.ServiceError) {
+	// Held points are withdrawn on capture, so the hold must fit the withdrawal limits
+	if serr := s.limits.CheckSum(req.Sum); serr != nil {
+		return nil, serr
+	}
+
+	hold := models.NewHold(username, req.Sum, s.ttl)
+
+	err := s.processRepo.HoldBalance(ctx, hold)
+	if err != nil {
+		return nil, holdError(hold.ID, err)
+	}
+
+	return hold, nil
+}
+
+func (s *HoldService) GetHold(ctx context.Context, id string, username string) (*models.Hold, serviceErrs.ServiceError) {
+	if !IsUUID(id) {
+		return nil, serviceErrs.NewServiceError(http.StatusNotFound, "invalid hold id '%v'", id)
+	}
+
+	hold, err := s.repo.GetHoldByID(ctx, id)
+	if err != nil {
+		return nil, serviceErrs.NewServiceError(http.StatusInternalServerError,
+			"failed to get hold '%v' from db: %w", id, err)
+	}
+
+	if len(hold.ID) == 0 || hold.Username != username {
+		return nil, serviceErrs.NewServiceError(http.StatusNotFound, "hold '%v' not found", id)
+	}
+
+	return hold, nil
+}
+
+func (s *HoldService) GetAllHolds(ctx context.Context, username string) ([]models.Hold, serviceErrs.ServiceError) {
+	holds, err := s.repo.GetAllUserHolds(ctx, username)
+	if err != nil {
+		return nil, serviceErrs.NewServiceError(http.StatusInternalServerError, "failed to get all holds: %w", err)
+	}
+
+	if len(holds) == 0 {
+		return nil, serviceErrs.NewServiceError(http.StatusNoContent, "no holds found")
+	}
+
+	return holds, nil
+}
+
+func (s *HoldService) CaptureHold(ctx context.Context, id string, req *models.CaptureRequest, username string) (*models.Hold, serviceErrs.ServiceError) {
+	if !IsUUID(id) {
+		return nil, serviceErrs.NewServiceError(http.StatusNotFound, "invalid hold id '%v'", id)
+	}
+
+	if len(req.Order) == 0 || !LuhnCheck(req.Order) {
+		return nil, serviceErrs.NewServiceError(http.StatusUnprocessableEntity, "invalid order number: '%v'", req.Order)
+	}
+
+	hold, err := s.processRepo.CaptureHold(ctx, id, username, req.Order, s.limits.Caps(time.Now())...)
+	if err != nil {
+		return nil, holdError(id, err)
+	}
+
+	return hold, nil
+}
+
+func (s *HoldService) ReleaseHold(ctx context.Context, id string, username string) (*models.Hold, serviceErrs.ServiceError) {
+	if !IsUUID(id) {
+		return nil, serviceErrs.NewServiceError(http.StatusNotFound, "invalid hold id '%v'", id)
+	}
+
+	hold, err := s.processRepo.ReleaseHold(ctx, id, username)
+	if err != nil {
+		return nil, holdError(id, err)
+	}
+
+	return hold, nil
+}
+
+// ExpireHolds returns the balance reserved by holds with passed TTL
+func (s *HoldService) ExpireHolds(ctx context.Context) (int64, serviceErrs.ServiceError) {
+	expired, err := s.repo.ExpireHolds(ctx, time.Now())
+	if err != nil {
+		return 0, serviceErrs.NewServiceError(http.StatusInternalServerError, "failed to expire holds: %w", err)
+	}
+
+	return expired, nil
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/fuzzy-toozy/gophermart/internal/database/repo"
+	"github.com/fuzzy-toozy/gophermart/internal/errors"
+	"github.com/fuzzy-toozy/gophermart/internal/models"
+)
+
+const (
+	maxIdempotencyKeyLen = 255
+)
+
+type IdempotencyService struct {
+	repo     repo.IdempotencyServiceRepo
+	lifetime time.Duration
+	// Pending key is taken over by a retry after the lease, so a request which
+	// failed to complete or release the key doesn't block it for the whole lifetime
+	lease time.Duration
+}
+
+func NewIdempotencyService(repo repo.IdempotencyServiceRepo, lifetime time.Duration, lease time.Duration) *IdempotencyService {
+	return &IdempotencyService{
+		repo:     repo,
+		lifetime: lifetime,
+		lease:    lease,
+	}
+}
+
+func Fingerprint(method string, path string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method + " " + path + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Begin claims the key for the request. If the key was already used for the same request
+// the stored record is returned and its response must be replayed.
+func (s *IdempotencyService) Begin(ctx context.Context, username string, key string, fingerprint string) (*models.IdempotencyRecord, errors.ServiceError) {
+	if len(key) > maxIdempotencyKeyLen {
+		return nil, errors.NewServiceError(http.StatusBadRequest, "idempotency key is longer than %v", maxIdempotencyKeyLen)
+	}
+
+	record := models.NewIdempotencyRecord(username, key, fingerprint, s.lease)
+
+	claimed, err := s.repo.ClaimKey(ctx, record)
+	if err != nil {
+		return nil, errors.NewServiceError(http.StatusInternalServerError,
+			"failed to claim idempotency key '%v': %w", key, err)
+	}
+
+	if claimed {
+		return nil, nil
+	}
+
+	stored, err := s.repo.GetRecord(ctx, username, key)
+	if err != nil {
+		return nil, errors.NewServiceError(http.StatusInternalServerError,
+			"failed to get idempotency key '%v' from db: %w", key, err)
+	}
+
+	if len(stored.Key) == 0 {
+		return nil, errors.NewServiceError(http.StatusConflict, "idempotency key '%v' was released concurrently", key)
+	}
+
+	if stored.Fingerprint != fingerprint {
+		return nil, errors.NewServiceError(http.StatusUnprocessableEntity,
+			"idempotency key '%v' was used for a different request", key)
+	}
+
+	if !stored.Completed() {
+		return nil, errors.NewServiceError(http.StatusConflict, "request with idempotency key '%v' is in progress", key)
+	}
+
+	return stored, nil
+}
+
+func (s *IdempotencyService) Complete(ctx context.Context, username string, key string,
+	status int, contentType string, body []byte) errors.ServiceError {
+	record := models.IdempotencyRecord{
+		Username:       username,
+		Key:            key,
+		ResponseStatus: status,
+		ContentType:    contentType,
+		ResponseBody:   body,
+		ExpiresAt:      time.Now().Add(s.lifetime),
+	}
+
+	err := s.repo.SaveResponse(ctx, &record)
+	if err != nil {
+		return errors.NewServiceError(http.StatusInternalServerError,
+			"failed to save response for idempotency key '%v': %w", key, err)
+	}
+
+	return nil
+}
+
+// KeepLease extends the lease of the pending key until stop is called, so retries don't take
+// over the key while the request is still running. Failed extensions are passed to onError.
+func (s *IdempotencyService) KeepLease(ctx context.Context, username string, key string,
+	onError func(error)) (stop func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+
+		// Lease is extended several times before it expires, so a single failure doesn't lose it
+		ticker := time.NewTicker(s.lease / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				err := s.repo.ExtendLease(ctx, username, key, time.Now().Add(s.lease))
+				if err != nil {
+					onError(errors.NewServiceError(http.StatusInternalServerError,
+						"failed to extend lease of idempotency key '%v': %w", key, err))
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+func (s *IdempotencyService) Release(ctx context.Context, username string, key string) errors.ServiceError {
+	err := s.repo.ReleaseKey(ctx, username, key)
+	if err != nil {
+		return errors.NewServiceError(http.StatusInternalServerError,
+			"failed to release idempotency key '%v': %w", key, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/fuzzy-toozy/gophermart/internal/database/repo"
+	serviceErrs "github.com/fuzzy-toozy/gophermart/internal/errors"
+)
+
+// WithdrawalLimits restricts points spending. Zero value of a limit disables it.
+type WithdrawalLimits struct {
+	Min     float64
+	Max     float64
+	Daily   float64
+	Monthly float64
+}
+
+// Withdrawal limit errors. Sums out of the min and max range are rejected with 422,
+// withdrawals exceeding daily and monthly limits with 403.
+var (
+	ErrWithdrawBelowMin     = errors.New("withdrawal sum is less than minimum")
+	ErrWithdrawAboveMax     = errors.New("withdrawal sum is more than maximum")
+	ErrDailyLimitExceeded   = errors.New("daily withdrawal limit exceeded")
+	ErrMonthlyLimitExceeded = errors.New("monthly withdrawal limit exceeded")
+)
+
+func StartOfMonth(t time.Time) time.Time {
+	y, m, _ := t.UTC().Date()
+	return time.Date(y, m, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// CheckSum validates the sum of a single withdrawal
+func (l WithdrawalLimits) CheckSum(sum float64) serviceErrs.ServiceError {
+	if sum <= 0 {
+		return serviceErrs.NewServiceError(http.StatusBadRequest, "sum must be positive, got %v", sum)
+	}
+
+	if l.Min > 0 && sum < l.Min {
+		return serviceErrs.NewServiceError(http.StatusUnprocessableEntity, "%w: %v < %v", ErrWithdrawBelowMin, sum, l.Min)
+	}
+
+	if l.Max > 0 && sum > l.Max {
+		return serviceErrs.NewServiceError(http.StatusUnprocessableEntity, "%w: %v > %v", ErrWithdrawAboveMax, sum, l.Max)
+	}
+
+	return nil
+}
+
+// Caps returns the periodic limits for a withdrawal made at the moment now
+func (l WithdrawalLimits) Caps(now time.Time) []repo.Limit {
+	caps := make([]repo.Limit, 0, 2)
+
+	if l.Daily > 0 {
+		caps = append(caps, repo.Limit{Since: StartOfDay(now), Max: l.Daily, Err: ErrDailyLimitExceeded})
+	}
+
+	if l.Monthly > 0 {
+		caps = append(caps, repo.Limit{Since: StartOfMonth(now), Max: l.Monthly, Err: ErrMonthlyLimitExceeded})
+	}
+
+	return caps
+}
+
+// limitExceededError reports the periodic limit the withdrawal exceeded
+func limitExceededError(err error) serviceErrs.ServiceError {
+	switch {
+	case errors.Is(err, ErrDailyLimitExceeded):
+		return serviceErrs.NewServiceError(http.StatusForbidden, "%w", ErrDailyLimitExceeded)
+	case errors.Is(err, ErrMonthlyLimitExceeded):
+		return serviceErrs.NewServiceError(http.StatusForbidden, "%w", ErrMonthlyLimitExceeded)
+	default:
+		return serviceErrs.NewServiceError(http.StatusForbidden, "withdrawal limit exceeded")
+	}
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestWithdrawalLimitsCheckSum(t *testing.T) {
+	limits := WithdrawalLimits{Min: 10, Max: 100}
+
+	tests := []struct {
+		name   string
+		limits WithdrawalLimits
+		sum    float64
+		status int
+		err    error
+	}{
+		{name: "within limits", limits: limits, sum: 50},
+		{name: "equal to min", limits: limits, sum: 10},
+		{name: "equal to max", limits: limits, sum: 100},
+		{name: "below min", limits: limits, sum: 9.99, status: http.StatusUnprocessableEntity, err: ErrWithdrawBelowMin},
+		{name: "above max", limits: limits, sum: 100.01, status: http.StatusUnprocessableEntity, err: ErrWithdrawAboveMax},
+		{name: "not positive", limits: limits, sum: 0, status: http.StatusBadRequest},
+		{name: "no limits", sum: 1e9},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serr := tt.limits.CheckSum(tt.sum)
+
+			if tt.status == 0 {
+				if serr != nil {
+					t.Fatalf("Expected no error, got %v", serr)
+				}
+				return
+			}
+
+			if serr == nil {
+				t.Fatalf("Expected error with status %v, got nil", tt.status)
+			}
+
+			if serr.GetStatus() != tt.status {
+				t.Errorf("Expected status %v, got %v", tt.status, serr.GetStatus())
+			}
+
+			if tt.err != nil && !errors.Is(serr, tt.err) {
+				t.Errorf("Expected %v, got %v", tt.err, serr)
+			}
+		})
+	}
+}
+
+func TestWithdrawalLimitsCaps(t *testing.T) {
+	now := time.Date(2024, time.March, 15, 13, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		limits WithdrawalLimits
+		since  []time.Time
+		errs   []error
+	}{
+		{name: "no caps", limits: WithdrawalLimits{Min: 1, Max: 10}},
+		{name: "daily", limits: WithdrawalLimits{Daily: 100},
+			since: []time.Time{time.Date(2024, time.March, 15, 0, 0, 0, 0, time.UTC)},
+			errs:  []error{ErrDailyLimitExceeded}},
+		{name: "monthly", limits: WithdrawalLimits{Monthly: 1000},
+			since: []time.Time{time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)},
+			errs:  []error{ErrMonthlyLimitExceeded}},
+		{name: "daily and monthly", limits: WithdrawalLimits{Daily: 100, Monthly: 1000},
+			since: []time.Time{time.Date(2024, time.March, 15, 0, 0, 0, 0, time.UTC),
+				time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)},
+			errs: []error{ErrDailyLimitExceeded, ErrMonthlyLimitExceeded}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			caps := tt.limits.Caps(now)
+
+			if len(caps) != len(tt.errs) {
+				t.Fatalf("Expected %v caps, got %+v", len(tt.errs), caps)
+			}
+
+			for i, c := range caps {
+				if !c.Since.Equal(tt.since[i]) || c.Err != tt.errs[i] {
+					t.Errorf("Expected cap since %v with %v, got %+v", tt.since[i], tt.errs[i], c)
+				}
+			}
+		})
+	}
+}
+
+func TestLimitExceededError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{name: "daily", err: fmt.Errorf("withdraw: %w", ErrDailyLimitExceeded), want: ErrDailyLimitExceeded},
+		{name: "monthly", err: fmt.Errorf("withdraw: %w", ErrMonthlyLimitExceeded), want: ErrMonthlyLimitExceeded},
+		{name: "unknown", err: errors.New("limit exceeded")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serr := limitExceededError(tt.err)
+
+			if serr.GetStatus() != http.StatusForbidden {
+				t.Errorf("Expected status %v, got %v", http.StatusForbidden, serr.GetStatus())
+			}
+
+			if tt.want != nil && !errors.Is(serr, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, serr)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package services
 
 import (
 	"context"
+	goerrors "errors"
 	"net/http"
 
 	"github.com/fuzzy-toozy/gophermart/internal/database/repo"
@@ -45,6 +46,10 @@ func (s *OrderService) AddNewOrder(ctx context.Context, username string, number
 	order := models.NewOrder(username, number)
 	err := s.repo.AddNewOrder(ctx, order)
 
+	if goerrors.Is(err, repo.ErrOrderNumberUsed) {
+		return errors.NewServiceError(http.StatusConflict, "order number '%v' is already used", number)
+	}
+
 	if err != nil {
 		return errors.NewServiceError(http.StatusInternalServerError,
 			"failed to add new order with number '%v': %w", number, err)
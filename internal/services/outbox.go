@@ -0,0 +1,217 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/fuzzy-toozy/gophermart/internal/database/repo"
+	serviceErrs "github.com/fuzzy-toozy/gophermart/internal/errors"
+	"github.com/fuzzy-toozy/gophermart/internal/models"
+	"go.uber.org/zap"
+)
+
+const (
+	OutboxSinkLog  = "log"
+	OutboxSinkHTTP = "http"
+	OutboxSinkFile = "file"
+	// Webhook sink is always used, it can't be listed in the config
+	OutboxSinkWebhook = "webhook"
+)
+
+// Events are claimed in small parts, so the lease of a part covers sending all of it
+const outboxClaimSize = 10
+
+// OutboxSink receives events relayed from the outbox. The same event may be sent more than once,
+// so consumers deduplicate events by id.
+type OutboxSink interface {
+	Name() string
+	Send(ctx context.Context, event *models.Event, payload []byte) error
+}
+
+// OutboxSinkConfig lists sinks and their targets
+type OutboxSinkConfig struct {
+	Sinks   []string
+	URL     string
+	File    string
+	Timeout time.Duration
+}
+
+type logSink struct {
+	logger *zap.SugaredLogger
+}
+
+func (s *logSink) Name() string {
+	return OutboxSinkLog
+}
+
+func (s *logSink) Send(ctx context.Context, event *models.Event, payload []byte) error {
+	s.logger.Infof("Outbox event '%v' of type '%v': %s", event.ID, event.Type, payload)
+	return nil
+}
+
+type httpSink struct {
+	url    string
+	client *http.Client
+}
+
+func (s *httpSink) Name() string {
+	return OutboxSinkHTTP
+}
+
+func (s *httpSink) Send(ctx context.Context, event *models.Event, payload []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderWebhookEvent, event.Type)
+	req.Header.Set("Idempotency-Key", event.ID)
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, webhookErrorMaxSize))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("unexpected response status %v", res.StatusCode)
+	}
+
+	return nil
+}
+
+// fileSink appends events to the file as JSON lines
+type fileSink struct {
+	path string
+}
+
+func (s *fileSink) Name() string {
+	return OutboxSinkFile
+}
+
+func (s *fileSink) Send(ctx context.Context, event *models.Event, payload []byte) error {
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(append(payload, '\n'))
+	if err == nil {
+		// The event is marked published right after, so it must not be lost in page cache
+		err = f.Sync()
+	}
+
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+func NewOutboxSinks(config OutboxSinkConfig, logger *zap.SugaredLogger) ([]OutboxSink, error) {
+	sinks := make([]OutboxSink, 0, len(config.Sinks))
+
+	for _, name := range config.Sinks {
+		switch name {
+		case OutboxSinkLog:
+			sinks = append(sinks, &logSink{logger: logger})
+		case OutboxSinkHTTP:
+			if len(config.URL) == 0 {
+				return nil, fmt.Errorf("url of '%v' outbox sink is not set", name)
+			}
+			sinks = append(sinks, &httpSink{url: config.URL, client: &http.Client{Timeout: config.Timeout}})
+		case OutboxSinkFile:
+			if len(config.File) == 0 {
+				return nil, fmt.Errorf("file of '%v' outbox sink is not set", name)
+			}
+			sinks = append(sinks, &fileSink{path: config.File})
+		default:
+			return nil, fmt.Errorf("unknown outbox sink '%v'", name)
+		}
+	}
+
+	return sinks, nil
+}
+
+// OutboxService relays events committed to the outbox to the sinks
+type OutboxService struct {
+	repo      repo.OutboxServiceRepo
+	sinks     []OutboxSink
+	timeout   time.Duration
+	retention time.Duration
+	logger    *zap.SugaredLogger
+}
+
+// NewOutboxService creates relay to the sinks, which send an event within timeout
+func NewOutboxService(repo repo.OutboxServiceRepo, sinks []OutboxSink, timeout time.Duration,
+	retention time.Duration, logger *zap.SugaredLogger) *OutboxService {
+	return &OutboxService{
+		repo:      repo,
+		sinks:     sinks,
+		timeout:   timeout,
+		retention: retention,
+		logger:    logger,
+	}
+}
+
+// publish sends the event to every sink. The event is sent again to all of them if any sink fails.
+func (s *OutboxService) publish(ctx context.Context, event *models.Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal outbox event '%v': %w", event.ID, err)
+	}
+
+	for _, sink := range s.sinks {
+		if err := sink.Send(ctx, event, payload); err != nil {
+			return fmt.Errorf("failed to send outbox event '%v' to '%v' sink: %w", event.ID, sink.Name(), err)
+		}
+	}
+
+	return nil
+}
+
+// Relay publishes pending outbox events in order and returns the number of published ones
+func (s *OutboxService) Relay(ctx context.Context) (int, serviceErrs.ServiceError) {
+	published := 0
+
+	for {
+		// Claimed events are published by anyone after lease if the relay dies while sending
+		now := time.Now()
+		lease := time.Duration(outboxClaimSize+1) * s.timeout
+
+		n, err := s.repo.PublishPending(ctx, now, now.Add(lease), outboxClaimSize, s.publish)
+		published += n
+		if err != nil {
+			return published, serviceErrs.NewServiceError(http.StatusInternalServerError,
+				"failed to relay outbox events: %w", err)
+		}
+
+		if n < outboxClaimSize {
+			break
+		}
+	}
+
+	if s.retention > 0 {
+		deleted, err := s.repo.DeletePublished(ctx, time.Now().Add(-s.retention))
+		if err != nil {
+			return published, serviceErrs.NewServiceError(http.StatusInternalServerError,
+				"failed to delete published outbox events: %w", err)
+		}
+
+		if deleted > 0 {
+			s.logger.Debugf("Deleted %v published outbox events", deleted)
+		}
+	}
+
+	return published, nil
+}
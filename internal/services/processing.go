@@ -5,24 +5,36 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/fuzzy-toozy/gophermart/internal/database/repo"
 	serviceErrs "github.com/fuzzy-toozy/gophermart/internal/errors"
+	"github.com/fuzzy-toozy/gophermart/internal/events"
 	"github.com/fuzzy-toozy/gophermart/internal/models"
 	"go.uber.org/zap"
 )
 
 type ProcessingService struct {
-	accural *AccrualService
-	repo    repo.ProcessServiceRepo
-	logger  *zap.SugaredLogger
+	accural  *AccrualService
+	tiers    *TierService
+	repo     repo.ProcessServiceRepo
+	limits   WithdrawalLimits
+	referral ReferralPolicy
+	bus      *events.Bus
+	logger   *zap.SugaredLogger
 }
 
-func NewProcessingService(repo repo.ProcessServiceRepo, accural *AccrualService, logger *zap.SugaredLogger) *ProcessingService {
+func NewProcessingService(repo repo.ProcessServiceRepo, accural *AccrualService, tiers *TierService,
+	limits WithdrawalLimits, referral ReferralPolicy, bus *events.Bus,
+	logger *zap.SugaredLogger) *ProcessingService {
 	return &ProcessingService{
-		repo:    repo,
-		accural: accural,
-		logger:  logger,
+		repo:     repo,
+		accural:  accural,
+		tiers:    tiers,
+		limits:   limits,
+		referral: referral,
+		bus:      bus,
+		logger:   logger,
 	}
 }
 
@@ -32,31 +44,101 @@ func (s *ProcessingService) Withdraw(ctx context.Context, wd *models.Withdraw, u
 			"invalid order number: '%v'", wd.Order)
 	}
 
-	err := s.repo.WithdrawBalance(ctx, wd, username)
+	if serr := s.limits.CheckSum(wd.Sum); serr != nil {
+		return serr
+	}
+
+	err := s.repo.WithdrawBalance(ctx, wd, username, s.limits.Caps(time.Now())...)
 
-	if err != nil && !errors.Is(err, repo.ErrWithdrawUnavailable) {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, repo.ErrWithdrawUnavailable):
+		return serviceErrs.NewServiceError(http.StatusPaymentRequired, "not enough funds")
+	case errors.Is(err, repo.ErrLimitExceeded):
+		return limitExceededError(err)
+	case errors.Is(err, repo.ErrWithdrawExists):
+		return serviceErrs.NewServiceError(http.StatusConflict,
+			"withdrawal for order '%v' already exists", wd.Order)
+	case errors.Is(err, repo.ErrOrderNumberUsed):
+		return serviceErrs.NewServiceError(http.StatusUnprocessableEntity,
+			"order number '%v' is already uploaded for accrual", wd.Order)
+	default:
 		return serviceErrs.NewServiceError(http.StatusInternalServerError,
 			"failed to withdraw balance for order '%v': %w", wd.Order, err)
 	}
+}
 
-	if errors.Is(err, repo.ErrWithdrawUnavailable) {
-		return serviceErrs.NewServiceError(http.StatusPaymentRequired, "not enough funds")
+func (s *ProcessingService) finishWithdrawal(ctx context.Context, number string,
+	finish func(ctx context.Context, number string) (*models.Withdrawal, error)) (*models.Withdrawal, serviceErrs.ServiceError) {
+	wd, err := finish(ctx, number)
+
+	switch {
+	case err == nil:
+		return wd, nil
+	case errors.Is(err, repo.ErrWithdrawNotFound):
+		return nil, serviceErrs.NewServiceError(http.StatusNotFound, "withdrawal '%v' not found", number)
+	case errors.Is(err, repo.ErrWithdrawNotPending):
+		return nil, serviceErrs.NewServiceError(http.StatusConflict, "withdrawal '%v' is not pending", number)
+	default:
+		return nil, serviceErrs.NewServiceError(http.StatusInternalServerError,
+			"failed to update withdrawal '%v': %w", number, err)
 	}
+}
 
-	return nil
+func (s *ProcessingService) CompleteWithdrawal(ctx context.Context, number string) (*models.Withdrawal, serviceErrs.ServiceError) {
+	return s.finishWithdrawal(ctx, number, s.repo.CompleteWithdrawal)
+}
+
+// CancelWithdrawal cancels pending withdrawal and returns its sum to the user balance
+func (s *ProcessingService) CancelWithdrawal(ctx context.Context, number string) (*models.Withdrawal, serviceErrs.ServiceError) {
+	return s.finishWithdrawal(ctx, number, s.repo.CancelWithdrawal)
+}
+
+// AdjustBalance corrects the user balance, debit can't exceed the available balance
+func (s *ProcessingService) AdjustBalance(ctx context.Context, username string,
+	adjustment *models.Adjustment) serviceErrs.ServiceError {
+	if adjustment.Amount == 0 || len(adjustment.Reason) == 0 {
+		return serviceErrs.NewServiceError(http.StatusBadRequest, "adjustment amount and reason must be set")
+	}
+
+	err := s.repo.AdjustBalance(ctx, username, adjustment)
+
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, repo.ErrUserNotFound):
+		return serviceErrs.NewServiceError(http.StatusNotFound, "user '%v' not found", username)
+	case errors.Is(err, repo.ErrWithdrawUnavailable):
+		return serviceErrs.NewServiceError(http.StatusPaymentRequired, "not enough funds")
+	default:
+		return serviceErrs.NewServiceError(http.StatusInternalServerError,
+			"failed to adjust balance of user '%v': %w", username, err)
+	}
 }
 
 func (s *ProcessingService) processOrder(ctx context.Context, order *models.Order, accural float64) error {
-	err := s.repo.ProcessOrder(ctx, order, accural)
+	reward, err := s.repo.ProcessOrder(ctx, order, accural, repo.ReferralBonuses{
+		Referee:  s.referral.RefereeBonus,
+		Referrer: s.referral.ReferrerBonus,
+	})
 	if err != nil {
 		return err
 	}
 
+	switch {
+	case reward.Fraud:
+		s.logger.Warnf("Referral of user '%v' by '%v' is not rewarded: %v", order.Username, reward.Referrer,
+			repo.ErrReferralFraud)
+	case len(reward.Referrer) > 0:
+		s.logger.Debugf("Rewarded referral of user '%v' by '%v'", order.Username, reward.Referrer)
+	}
+
 	return nil
 }
 
 func (s *ProcessingService) processAccural(ctx context.Context, order *models.Order) error {
-	orderInfo, err := s.accural.GetOrderInfo(order.Number)
+	orderInfo, err := s.accural.GetOrderInfo(ctx, order.Number)
 	if err != nil {
 		return fmt.Errorf("failed to get order '%v' info for user '%v' from accural: %w",
 			order.Number, order.Username, err)
@@ -73,17 +155,78 @@ func (s *ProcessingService) processAccural(ctx context.Context, order *models.Or
 			return fmt.Errorf("failed to update order '%v' status to '%v' for user '%v': %w",
 				order.Number, order.Status, order.Username, err)
 		}
+
+		s.publishOrder(order)
 	case models.OrderPROCESSED:
 		order.Status = models.OrderPROCESSED
-		err := s.repo.ProcessOrder(ctx, order, orderInfo.Accrual)
+		accrual, serr := s.tiers.ApplyMultiplier(ctx, order.Username, orderInfo.Accrual)
+		if serr != nil {
+			return fmt.Errorf("failed to apply tier multiplier to order '%v' accrual: %w", order.Number, serr)
+		}
+
+		err := s.processOrder(ctx, order, accrual)
 		if err != nil {
 			return fmt.Errorf("falied to finalize processed order '%v' for user '%v': %w", order.Number, order.Username, err)
 		}
+
+		order.Accrual = accrual
+		s.publishOrder(order)
 	}
 
 	return nil
 }
 
+// publishOrder notifies the order owner about the order status change. Webhook subscribers
+// are notified through the outbox event added with the change.
+func (s *ProcessingService) publishOrder(order *models.Order) {
+	s.bus.Publish(order.Username, events.EventOrder, order)
+}
+
+// processUnprocessedOrder moves the order towards a final status and reports whether it was processed
+func (s *ProcessingService) processUnprocessedOrder(ctx context.Context, order *models.Order) bool {
+	s.logger.Debugf("Received uprocessed order '%v' from user '%v' with status '%v'",
+		order.Number, order.Username, order.Status)
+
+	switch order.Status {
+	case models.OrderNEW:
+		order.Status = models.OrderPROCESSING
+		if err := s.repo.UpdateOrderStatus(ctx, order); err != nil {
+			s.logProcessingError(err, "Failed to update order '%v' status to '%v' for user '%v': %v",
+				order.Number, order.Status, order.Username, err)
+
+			return false
+		}
+		s.publishOrder(order)
+
+		// Accrual is asked right away, so new orders don't wait for the next pass
+		if err := s.processAccural(ctx, order); err != nil {
+			s.logProcessingError(err, "Failed to process accural order: %v", err)
+		}
+
+		return true
+	case models.OrderPROCESSING:
+		if err := s.processAccural(ctx, order); err != nil {
+			s.logProcessingError(err, "Failed to process accural order: %v", err)
+
+			return false
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// logProcessingError logs the error unless the order was finished by another instance meanwhile
+func (s *ProcessingService) logProcessingError(err error, format string, args ...any) {
+	if errors.Is(err, repo.ErrOrderFinalized) {
+		s.logger.Debugf(format, args...)
+		return
+	}
+
+	s.logger.Errorf(format, args...)
+}
+
 func (s *ProcessingService) ProcessOrders(ctx context.Context) serviceErrs.ServiceError {
 	orders, err := s.repo.GetAllUnprocessedOrders(ctx)
 	if err != nil {
@@ -95,25 +238,8 @@ func (s *ProcessingService) ProcessOrders(ctx context.Context) serviceErrs.Servi
 	}
 
 	ordersProcessed := 0
-	for _, order := range orders {
-		s.logger.Debugf("Received uprocessed order '%v' from user '%v' with status '%v'",
-			order.Number, order.Username, order.Status)
-		switch order.Status {
-		case models.OrderNEW:
-			order.Status = models.OrderPROCESSING
-			if err = s.repo.UpdateOrderStatus(ctx, &order); err != nil {
-				s.logger.Errorf("Failed to update order '%v' status to '%v' for user '%v': %w",
-					order.Number, order.Status, order.Username, err)
-
-				continue
-			}
-			ordersProcessed++
-		case models.OrderPROCESSING:
-			if err := s.processAccural(ctx, &order); err != nil {
-				s.logger.Errorf("Failed to process accural order: %v", err)
-
-				continue
-			}
+	for i := range orders {
+		if s.processUnprocessedOrder(ctx, &orders[i]) {
 			ordersProcessed++
 		}
 	}
@@ -124,3 +250,22 @@ func (s *ProcessingService) ProcessOrders(ctx context.Context) serviceErrs.Servi
 
 	return nil
 }
+
+// ProcessOrder processes a single order, e.g. right after it is uploaded.
+// Orders which are already processed or don't exist are skipped.
+func (s *ProcessingService) ProcessOrder(ctx context.Context, number string) serviceErrs.ServiceError {
+	order, err := s.repo.GetOrderByNumber(ctx, number)
+	if err != nil {
+		return serviceErrs.NewServiceError(http.StatusInternalServerError,
+			"failed to get order '%v': %w", number, err)
+	}
+
+	if len(order.Number) == 0 {
+		s.logger.Debugf("Order '%v' to process not found", number)
+		return nil
+	}
+
+	s.processUnprocessedOrder(ctx, order)
+
+	return nil
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fuzzy-toozy/gophermart/internal/database/repo"
+	serviceErrs "github.com/fuzzy-toozy/gophermart/internal/errors"
+	"github.com/fuzzy-toozy/gophermart/internal/models"
+)
+
+const (
+	promoCodeBytes = 10
+	maxPromoCodes  = 1000
+)
+
+type PromoService struct {
+	repo        repo.PromoServiceRepo
+	processRepo repo.ProcessServiceRepo
+}
+
+func NewPromoService(repo repo.PromoServiceRepo, processRepo repo.ProcessServiceRepo) *PromoService {
+	return &PromoService{
+		repo:        repo,
+		processRepo: processRepo,
+	}
+}
+
+func (s *PromoService) GeneratePromoCodes(ctx context.Context, req *models.PromoCodeRequest) ([]models.PromoCode, serviceErrs.ServiceError) {
+	if req.Count == 0 {
+		req.Count = 1
+	}
+
+	if req.MaxRedemptions == 0 {
+		req.MaxRedemptions = 1
+	}
+
+	switch {
+	case req.Value <= 0:
+		return nil, serviceErrs.NewServiceError(http.StatusBadRequest, "promo code value must be positive, got %v", req.Value)
+	case req.MaxRedemptions < 0:
+		return nil, serviceErrs.NewServiceError(http.StatusBadRequest, "max redemptions can't be negative")
+	case req.Count < 0 || req.Count > maxPromoCodes:
+		return nil, serviceErrs.NewServiceError(http.StatusBadRequest, "promo codes count must be from 1 to %v", maxPromoCodes)
+	case len(req.Code) > 0 && req.Count > 1:
+		return nil, serviceErrs.NewServiceError(http.StatusBadRequest, "code can be set for a single promo code only")
+	case req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()):
+		return nil, serviceErrs.NewServiceError(http.StatusBadRequest, "promo code expiration must be in the future")
+	}
+
+	result := make([]models.PromoCode, 0, req.Count)
+
+	for i := 0; i < req.Count; i++ {
+		promo := models.PromoCode{
+			Code:           strings.ToUpper(req.Code),
+			Value:          req.Value,
+			MaxRedemptions: req.MaxRedemptions,
+			ExpiresAt:      req.ExpiresAt,
+			CreatedAt:      time.Now(),
+		}
+
+		if len(promo.Code) == 0 {
+			code, err := RandomCode(promoCodeBytes)
+			if err != nil {
+				return nil, serviceErrs.NewServiceError(http.StatusInternalServerError, "%w", err)
+			}
+			promo.Code = code
+		}
+
+		result = append(result, promo)
+	}
+
+	err := s.processRepo.AddPromoCodes(ctx, result)
+	switch {
+	case errors.Is(err, repo.ErrPromoExists):
+		return nil, serviceErrs.NewServiceError(http.StatusConflict, "%w", err)
+	case err != nil:
+		return nil, serviceErrs.NewServiceError(http.StatusInternalServerError, "failed to add promo codes: %w", err)
+	}
+
+	return result, nil
+}
+
+func (s *PromoService) GetAllPromoCodes(ctx context.Context) ([]models.PromoCode, serviceErrs.ServiceError) {
+	codes, err := s.repo.GetAllPromoCodes(ctx)
+	if err != nil {
+		return nil, serviceErrs.NewServiceError(http.StatusInternalServerError, "failed to get all promo codes: %w", err)
+	}
+
+	if len(codes) == 0 {
+		return nil, serviceErrs.NewServiceError(http.StatusNoContent, "no promo codes found")
+	}
+
+	return codes, nil
+}
+
+func (s *PromoService) Redeem(ctx context.Context, req *models.PromoRedeemRequest, username string) (*models.PromoCode, serviceErrs.ServiceError) {
+	code := strings.ToUpper(req.Code)
+
+	promo, err := s.processRepo.RedeemPromoCode(ctx, code, username)
+
+	switch {
+	case err == nil:
+		return promo, nil
+	case errors.Is(err, repo.ErrPromoNotFound):
+		return nil, serviceErrs.NewServiceError(http.StatusNotFound, "promo code '%v' not found", code)
+	case errors.Is(err, repo.ErrPromoExpired):
+		return nil, serviceErrs.NewServiceError(http.StatusGone, "promo code '%v' is expired", code)
+	case errors.Is(err, repo.ErrPromoExhausted):
+		return nil, serviceErrs.NewServiceError(http.StatusConflict, "promo code '%v' is fully redeemed", code)
+	case errors.Is(err, repo.ErrPromoRedeemed):
+		return nil, serviceErrs.NewServiceError(http.StatusConflict, "promo code '%v' is already redeemed", code)
+	default:
+		return nil, serviceErrs.NewServiceError(http.StatusInternalServerError,
+			"failed to redeem promo code '%v': %w", code, err)
+	}
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// rateLimiter spaces calls evenly, so their rate doesn't exceed the limit
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newRateLimiter(rate float64) *rateLimiter {
+	l := &rateLimiter{}
+	l.SetRate(rate)
+
+	return l
+}
+
+// SetRate changes the limit of calls per second. Zero rate disables the limit.
+func (l *rateLimiter) SetRate(rate float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.interval = 0
+	if rate > 0 {
+		l.interval = time.Duration(float64(time.Second) / rate)
+	}
+}
+
+// Wait blocks until the call is allowed or ctx is done
+func (l *rateLimiter) Wait(ctx context.Context) error {
+	l.mu.Lock()
+	if l.interval == 0 {
+		l.mu.Unlock()
+		return nil
+	}
+
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+
+	at := l.next
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+
+	delay := time.Until(at)
+	if delay <= 0 {
+		return nil
+	}
+
+	t := time.NewTimer(delay)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,54 @@
+package services
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/fuzzy-toozy/gophermart/internal/database/repo"
+	serviceErrs "github.com/fuzzy-toozy/gophermart/internal/errors"
+	"go.uber.org/zap"
+)
+
+// ReconciliationService verifies stored user balances against the balance ledger
+type ReconciliationService struct {
+	repo   repo.BalanceServiceRepo
+	logger *zap.SugaredLogger
+}
+
+func NewReconciliationService(repo repo.BalanceServiceRepo, logger *zap.SugaredLogger) *ReconciliationService {
+	return &ReconciliationService{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+// Reconcile reports every user balance drifted from the ledger and returns the number of them
+func (s *ReconciliationService) Reconcile(ctx context.Context) (int, serviceErrs.ServiceError) {
+	drifts, err := s.repo.GetBalanceDrifts(ctx)
+	if err != nil {
+		return 0, serviceErrs.NewServiceError(http.StatusInternalServerError, "failed to get balance drifts: %w", err)
+	}
+
+	for _, d := range drifts {
+		s.logger.Errorf("Balance of user '%v' drifted from ledger: current %v, ledger current %v, withdrawn %v, ledger withdrawn %v",
+			d.Username, d.Current, d.LedgerCurrent, d.Withdrawn, d.LedgerWithdrawn)
+	}
+
+	return len(drifts), nil
+}
+
+// CheckLedger reports every ledger transaction which postings don't sum up to zero
+// and returns the number of them
+func (s *ReconciliationService) CheckLedger(ctx context.Context) (int, serviceErrs.ServiceError) {
+	violations, err := s.repo.GetLedgerViolations(ctx)
+	if err != nil {
+		return 0, serviceErrs.NewServiceError(http.StatusInternalServerError, "failed to get ledger violations: %w", err)
+	}
+
+	for _, v := range violations {
+		s.logger.Errorf("Ledger transaction '%v' (%v) is unbalanced: %v postings sum up to %v",
+			v.TransactionID, v.Operation, v.Postings, v.Sum)
+	}
+
+	return len(violations), nil
+}
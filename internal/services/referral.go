@@ -0,0 +1,15 @@
+package services
+
+// ReferralPolicy defines bonuses paid when the first order of an invited user is processed.
+// Zero bonus is not paid.
+type ReferralPolicy struct {
+	ReferrerBonus float64
+	RefereeBonus  float64
+}
+
+const referralCodeBytes = 5
+
+// NewReferralCode returns a random code the user can share to invite others
+func NewReferralCode() (string, error) {
+	return RandomCode(referralCodeBytes)
+}
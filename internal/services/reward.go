@@ -0,0 +1,156 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/fuzzy-toozy/gophermart/internal/database/repo"
+	serviceErrs "github.com/fuzzy-toozy/gophermart/internal/errors"
+	"github.com/fuzzy-toozy/gophermart/internal/models"
+)
+
+type RewardService struct {
+	repo        repo.RewardServiceRepo
+	processRepo repo.ProcessServiceRepo
+}
+
+func NewRewardService(repo repo.RewardServiceRepo, processRepo repo.ProcessServiceRepo) *RewardService {
+	return &RewardService{
+		repo:        repo,
+		processRepo: processRepo,
+	}
+}
+
+func rewardError(id string, err error) serviceErrs.ServiceError {
+	switch {
+	case errors.Is(err, repo.ErrRewardNotFound):
+		return serviceErrs.NewServiceError(http.StatusNotFound, "reward '%v' not found", id)
+	case errors.Is(err, repo.ErrRewardOutOfStock):
+		return serviceErrs.NewServiceError(http.StatusConflict, "reward '%v' is out of stock", id)
+	case errors.Is(err, repo.ErrWithdrawUnavailable):
+		return serviceErrs.NewServiceError(http.StatusPaymentRequired, "not enough funds")
+	default:
+		return serviceErrs.NewServiceError(http.StatusInternalServerError, "failed to process reward '%v': %w", id, err)
+	}
+}
+
+func validateReward(reward *models.Reward) serviceErrs.ServiceError {
+	if reward.Cost <= 0 {
+		return serviceErrs.NewServiceError(http.StatusBadRequest, "reward cost must be positive, got %v", reward.Cost)
+	}
+
+	if reward.Stock < 0 {
+		return serviceErrs.NewServiceError(http.StatusBadRequest, "reward stock can't be negative, got %v", reward.Stock)
+	}
+
+	return nil
+}
+
+func (s *RewardService) AddReward(ctx context.Context, reward *models.Reward) serviceErrs.ServiceError {
+	if serr := validateReward(reward); serr != nil {
+		return serr
+	}
+
+	reward.CreatedAt = time.Now()
+	reward.UpdatedAt = reward.CreatedAt
+
+	err := s.repo.AddNewReward(ctx, reward)
+	if err != nil {
+		return serviceErrs.NewServiceError(http.StatusInternalServerError, "failed to add reward: %w", err)
+	}
+
+	return nil
+}
+
+func (s *RewardService) GetReward(ctx context.Context, id string) (*models.Reward, serviceErrs.ServiceError) {
+	if !IsUUID(id) {
+		return nil, serviceErrs.NewServiceError(http.StatusNotFound, "invalid reward id '%v'", id)
+	}
+
+	reward, err := s.repo.GetRewardByID(ctx, id)
+	if err != nil {
+		return nil, serviceErrs.NewServiceError(http.StatusInternalServerError,
+			"failed to get reward '%v' from db: %w", id, err)
+	}
+
+	if len(reward.ID) == 0 {
+		return nil, serviceErrs.NewServiceError(http.StatusNotFound, "reward '%v' not found", id)
+	}
+
+	return reward, nil
+}
+
+func (s *RewardService) GetAllRewards(ctx context.Context) ([]models.Reward, serviceErrs.ServiceError) {
+	rewards, err := s.repo.GetAllRewards(ctx)
+	if err != nil {
+		return nil, serviceErrs.NewServiceError(http.StatusInternalServerError, "failed to get all rewards: %w", err)
+	}
+
+	if len(rewards) == 0 {
+		return nil, serviceErrs.NewServiceError(http.StatusNoContent, "no rewards found")
+	}
+
+	return rewards, nil
+}
+
+func (s *RewardService) UpdateReward(ctx context.Context, id string, reward *models.Reward) serviceErrs.ServiceError {
+	if !IsUUID(id) {
+		return serviceErrs.NewServiceError(http.StatusNotFound, "invalid reward id '%v'", id)
+	}
+
+	if serr := validateReward(reward); serr != nil {
+		return serr
+	}
+
+	reward.ID = id
+	reward.UpdatedAt = time.Now()
+
+	err := s.repo.UpdateReward(ctx, reward)
+	if err != nil {
+		return rewardError(id, err)
+	}
+
+	return nil
+}
+
+// DeleteReward removes the reward from the catalog. Redemption history keeps its name and cost.
+func (s *RewardService) DeleteReward(ctx context.Context, id string) serviceErrs.ServiceError {
+	if !IsUUID(id) {
+		return serviceErrs.NewServiceError(http.StatusNotFound, "invalid reward id '%v'", id)
+	}
+
+	err := s.repo.DeleteReward(ctx, id)
+	if err != nil {
+		return rewardError(id, err)
+	}
+
+	return nil
+}
+
+func (s *RewardService) Redeem(ctx context.Context, id string, username string) (*models.Redemption, serviceErrs.ServiceError) {
+	if !IsUUID(id) {
+		return nil, serviceErrs.NewServiceError(http.StatusNotFound, "invalid reward id '%v'", id)
+	}
+
+	redemption, err := s.processRepo.RedeemReward(ctx, id, username)
+	if err != nil {
+		return nil, rewardError(id, err)
+	}
+
+	return redemption, nil
+}
+
+func (s *RewardService) GetAllRedemptions(ctx context.Context, username string) ([]models.Redemption, serviceErrs.ServiceError) {
+	redemptions, err := s.repo.GetAllUserRedemptions(ctx, username)
+	if err != nil {
+		return nil, serviceErrs.NewServiceError(http.StatusInternalServerError, "failed to get all redemptions: %w", err)
+	}
+
+	if len(redemptions) == 0 {
+		return nil, serviceErrs.NewServiceError(http.StatusNoContent, "no redemptions found")
+	}
+
+	return redemptions, nil
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/fuzzy-toozy/gophermart/internal/database/repo"
+	serviceErrs "github.com/fuzzy-toozy/gophermart/internal/errors"
+	"github.com/fuzzy-toozy/gophermart/internal/models"
+	"go.uber.org/zap"
+)
+
+// TierPolicy defines lifetime accrual thresholds of tiers and their accrual multipliers.
+// Users start in the bronze tier which has no multiplier.
+type TierPolicy struct {
+	SilverThreshold  float64
+	GoldThreshold    float64
+	SilverMultiplier float64
+	GoldMultiplier   float64
+}
+
+// TierFor returns the tier user reaches with the given lifetime accrual
+func (p TierPolicy) TierFor(lifetimeAccrued float64) string {
+	switch {
+	case lifetimeAccrued >= p.GoldThreshold:
+		return models.TierGOLD
+	case lifetimeAccrued >= p.SilverThreshold:
+		return models.TierSILVER
+	default:
+		return models.TierBRONZE
+	}
+}
+
+func (p TierPolicy) Multiplier(tier string) float64 {
+	switch tier {
+	case models.TierGOLD:
+		return p.GoldMultiplier
+	case models.TierSILVER:
+		return p.SilverMultiplier
+	default:
+		return 1
+	}
+}
+
+type TierService struct {
+	repo   repo.TierServiceRepo
+	policy TierPolicy
+	logger *zap.SugaredLogger
+}
+
+func NewTierService(repo repo.TierServiceRepo, policy TierPolicy, logger *zap.SugaredLogger) *TierService {
+	return &TierService{
+		repo:   repo,
+		policy: policy,
+		logger: logger,
+	}
+}
+
+func (s *TierService) GetUserTier(ctx context.Context, username string) (*models.UserTier, serviceErrs.ServiceError) {
+	tier, err := s.repo.GetUserTier(ctx, username)
+	if err != nil {
+		return nil, serviceErrs.NewServiceError(http.StatusInternalServerError,
+			"failed to get tier of user '%v': %w", username, err)
+	}
+
+	return tier, nil
+}
+
+// ApplyMultiplier returns accrual increased according to the user tier
+func (s *TierService) ApplyMultiplier(ctx context.Context, username string, accrual float64) (float64, serviceErrs.ServiceError) {
+	tier, serr := s.GetUserTier(ctx, username)
+	if serr != nil {
+		return 0, serr
+	}
+
+	return math.Round(accrual*s.policy.Multiplier(tier.Tier)*100) / 100, nil
+}
+
+// RecalculateTiers moves users to tiers matching their lifetime accrual and returns the number of changes
+func (s *TierService) RecalculateTiers(ctx context.Context) (int, serviceErrs.ServiceError) {
+	tiers, err := s.repo.GetAllUserTiers(ctx)
+	if err != nil {
+		return 0, serviceErrs.NewServiceError(http.StatusInternalServerError, "failed to get user tiers: %w", err)
+	}
+
+	changed := 0
+	for _, tier := range tiers {
+		newTier := s.policy.TierFor(tier.LifetimeAccrued)
+		if newTier == tier.Tier {
+			continue
+		}
+
+		change := models.TierChange{
+			Username:        tier.Username,
+			From:            tier.Tier,
+			To:              newTier,
+			LifetimeAccrued: tier.LifetimeAccrued,
+			ChangedAt:       time.Now(),
+		}
+
+		if err := s.repo.ChangeTier(ctx, &change); err != nil {
+			s.logger.Errorf("Failed to change tier of user '%v' to '%v': %v", tier.Username, newTier, err)
+			continue
+		}
+
+		s.logger.Debugf("User '%v' moved from tier '%v' to '%v'", tier.Username, tier.Tier, newTier)
+		changed++
+	}
+
+	return changed, nil
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/fuzzy-toozy/gophermart/internal/models"
+)
+
+var testTierPolicy = TierPolicy{
+	SilverThreshold:  1000,
+	GoldThreshold:    5000,
+	SilverMultiplier: 1.1,
+	GoldMultiplier:   1.25,
+}
+
+func TestTierPolicyTierFor(t *testing.T) {
+	tests := []struct {
+		name    string
+		accrued float64
+		tier    string
+	}{
+		{name: "no accrual", accrued: 0, tier: models.TierBRONZE},
+		{name: "below silver", accrued: 999.99, tier: models.TierBRONZE},
+		{name: "silver threshold", accrued: 1000, tier: models.TierSILVER},
+		{name: "below gold", accrued: 4999.99, tier: models.TierSILVER},
+		{name: "gold threshold", accrued: 5000, tier: models.TierGOLD},
+		{name: "above gold", accrued: 1e6, tier: models.TierGOLD},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tier := testTierPolicy.TierFor(tt.accrued); tier != tt.tier {
+				t.Errorf("Expected tier %v for %v, got %v", tt.tier, tt.accrued, tier)
+			}
+		})
+	}
+}
+
+func TestTierPolicyMultiplier(t *testing.T) {
+	tests := []struct {
+		tier       string
+		multiplier float64
+	}{
+		{tier: models.TierBRONZE, multiplier: 1},
+		{tier: models.TierSILVER, multiplier: 1.1},
+		{tier: models.TierGOLD, multiplier: 1.25},
+		{tier: "UNKNOWN", multiplier: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.tier, func(t *testing.T) {
+			if m := testTierPolicy.Multiplier(tt.tier); m != tt.multiplier {
+				t.Errorf("Expected multiplier %v for tier %v, got %v", tt.multiplier, tt.tier, m)
+			}
+		})
+	}
+}
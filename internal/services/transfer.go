@@ -0,0 +1,78 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/fuzzy-toozy/gophermart/internal/database/repo"
+	serviceErrs "github.com/fuzzy-toozy/gophermart/internal/errors"
+	"github.com/fuzzy-toozy/gophermart/internal/models"
+)
+
+type TransferService struct {
+	repo        repo.TransferServiceRepo
+	processRepo repo.ProcessServiceRepo
+	// Max sum user can send during a day. Zero means no limit.
+	dailyLimit float64
+}
+
+func NewTransferService(repo repo.TransferServiceRepo, processRepo repo.ProcessServiceRepo, dailyLimit float64) *TransferService {
+	return &TransferService{
+		repo:        repo,
+		processRepo: processRepo,
+		dailyLimit:  dailyLimit,
+	}
+}
+
+func StartOfDay(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func (s *TransferService) Transfer(ctx context.Context, req *models.TransferRequest, username string) (*models.Transfer, serviceErrs.ServiceError) {
+	if req.Sum <= 0 {
+		return nil, serviceErrs.NewServiceError(http.StatusBadRequest, "transfer sum must be positive, got %v", req.Sum)
+	}
+
+	if req.Login == username {
+		return nil, serviceErrs.NewServiceError(http.StatusUnprocessableEntity, "user can't transfer points to own account")
+	}
+
+	transfer := models.NewTransfer(username, req.Login, req.Sum)
+
+	limits := make([]repo.Limit, 0, 1)
+	if s.dailyLimit > 0 {
+		limits = append(limits, repo.Limit{Since: StartOfDay(transfer.CreatedAt), Max: s.dailyLimit})
+	}
+
+	err := s.processRepo.TransferBalance(ctx, transfer, limits...)
+
+	switch {
+	case err == nil:
+		return transfer, nil
+	case errors.Is(err, repo.ErrUserNotFound):
+		return nil, serviceErrs.NewServiceError(http.StatusNotFound, "recipient '%v' not found", req.Login)
+	case errors.Is(err, repo.ErrLimitExceeded):
+		return nil, serviceErrs.NewServiceError(http.StatusForbidden, "daily transfer limit %v exceeded", s.dailyLimit)
+	case errors.Is(err, repo.ErrWithdrawUnavailable):
+		return nil, serviceErrs.NewServiceError(http.StatusPaymentRequired, "not enough funds")
+	default:
+		return nil, serviceErrs.NewServiceError(http.StatusInternalServerError,
+			"failed to transfer points to user '%v': %w", req.Login, err)
+	}
+}
+
+func (s *TransferService) GetAllTransfers(ctx context.Context, username string) ([]models.Transfer, serviceErrs.ServiceError) {
+	transfers, err := s.repo.GetAllUserTransfers(ctx, username)
+	if err != nil {
+		return nil, serviceErrs.NewServiceError(http.StatusInternalServerError, "failed to get all transfers: %w", err)
+	}
+
+	if len(transfers) == 0 {
+		return nil, serviceErrs.NewServiceError(http.StatusNoContent, "no transfers found")
+	}
+
+	return transfers, nil
+}
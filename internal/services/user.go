@@ -2,6 +2,7 @@ package services
 
 import (
 	"context"
+	goerrors "errors"
 	"net/http"
 	"time"
 
@@ -11,6 +12,9 @@ import (
 	"github.com/fuzzy-toozy/gophermart/internal/models"
 )
 
+// Referral code is generated again if the random one is already used
+const maxReferralCodeAttempts = 3
+
 type UserService struct {
 	repo repo.UserServiceRepo
 	auth TokenService
@@ -52,17 +56,56 @@ func (s *UserService) Register(ctx context.Context, user *models.User) (token st
 		return "", errors.NewServiceError(http.StatusConflict, "user with name '%v' already exists", user.Username)
 	}
 
+	if len(user.ReferrerCode) > 0 {
+		referrer, err := s.repo.GetUserByReferralCode(ctx, user.ReferrerCode)
+		if err != nil {
+			return "", errors.NewServiceError(http.StatusInternalServerError,
+				"failed to get referrer by code '%v' from db: %w", user.ReferrerCode, err)
+		}
+
+		if len(referrer.Username) == 0 {
+			return "", errors.NewServiceError(http.StatusBadRequest, "unknown referral code '%v'", user.ReferrerCode)
+		}
+
+		user.Referrer = referrer.Username
+	}
+
 	token, err = s.auth.Generate(user)
 	if err != nil {
-		return "", errors.NewServiceError(http.StatusBadRequest, "failed to generate jwt token for user '%v': %w", user.Username, err)
+		return "", errors.NewServiceError(http.StatusInternalServerError,
+			"failed to generate jwt token for user '%v': %w", user.Username, err)
+	}
+
+	for attempt := 1; ; attempt++ {
+		user.ReferralCode, err = NewReferralCode()
+		if err != nil {
+			return "", errors.NewServiceError(http.StatusInternalServerError, "%w", err)
+		}
+
+		err = s.repo.AddUser(ctx, user)
+		if !goerrors.Is(err, repo.ErrReferralCodeExists) || attempt == maxReferralCodeAttempts {
+			break
+		}
 	}
 
-	err = s.repo.AddUser(ctx, user)
+	switch {
+	case err == nil:
+		return token, nil
+	case goerrors.Is(err, repo.ErrUserExists):
+		return "", errors.NewServiceError(http.StatusConflict, "user with name '%v' already exists", user.Username)
+	default:
+		return "", errors.NewServiceError(http.StatusInternalServerError, "failed to add new user to db: %w", err)
+	}
+}
+
+func (s *UserService) GetReferralInfo(ctx context.Context, username string) (*models.ReferralInfo, errors.ServiceError) {
+	info, err := s.repo.GetReferralInfo(ctx, username)
 	if err != nil {
-		return "", errors.NewServiceError(http.StatusBadRequest, "failed to add new user to db: %v", err)
+		return nil, errors.NewServiceError(http.StatusInternalServerError,
+			"failed to get referral info of user '%v' from db: %w", username, err)
 	}
 
-	return token, nil
+	return info, nil
 }
 
 func (s *UserService) Login(ctx context.Context, user *models.User) (token string, serr errors.ServiceError) {
@@ -0,0 +1,311 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/fuzzy-toozy/gophermart/internal/database/repo"
+	serviceErrs "github.com/fuzzy-toozy/gophermart/internal/errors"
+	"github.com/fuzzy-toozy/gophermart/internal/models"
+	"go.uber.org/zap"
+)
+
+const (
+	HeaderWebhookEvent     = "X-Gophermart-Event"
+	HeaderWebhookDelivery  = "X-Gophermart-Delivery"
+	HeaderWebhookTimestamp = "X-Gophermart-Timestamp"
+	// Signature is hex encoded HMAC-SHA256 of "<timestamp>.<body>" with the subscription secret
+	HeaderWebhookSignature = "X-Gophermart-Signature"
+)
+
+const (
+	webhookSecretBytes = 20
+	webhookBatchSize   = 100
+	// Deliveries are claimed in small parts, so the lease of a part covers sending all of it
+	webhookClaimSize    = 10
+	webhookLogLimit     = 100
+	webhookRetryBase    = 10 * time.Second
+	webhookRetryMax     = time.Hour
+	webhookErrorMaxSize = 512
+)
+
+var webhookEvents = map[string]bool{
+	models.EventOrderStatusChanged:  true,
+	models.EventWithdrawalCreated:   true,
+	models.EventWithdrawalCompleted: true,
+	models.EventWithdrawalCancelled: true,
+}
+
+// WebhookPolicy defines how webhook deliveries are sent and retried
+type WebhookPolicy struct {
+	Timeout     time.Duration
+	MaxAttempts int
+}
+
+type WebhookService struct {
+	repo   repo.WebhookServiceRepo
+	client *http.Client
+	policy WebhookPolicy
+	logger *zap.SugaredLogger
+}
+
+func NewWebhookService(repo repo.WebhookServiceRepo, policy WebhookPolicy, logger *zap.SugaredLogger) *WebhookService {
+	return &WebhookService{
+		repo:   repo,
+		client: &http.Client{Timeout: policy.Timeout},
+		policy: policy,
+		logger: logger,
+	}
+}
+
+func SignWebhook(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// retryDelay doubles the delay after every failed attempt
+func retryDelay(attempt int) time.Duration {
+	delay := webhookRetryBase
+	for i := 1; i < attempt && delay < webhookRetryMax; i++ {
+		delay *= 2
+	}
+
+	if delay > webhookRetryMax {
+		return webhookRetryMax
+	}
+
+	return delay
+}
+
+func (s *WebhookService) AddSubscription(ctx context.Context, subscription *models.WebhookSubscription) serviceErrs.ServiceError {
+	u, err := url.Parse(subscription.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+		return serviceErrs.NewServiceError(http.StatusBadRequest, "invalid webhook url '%v'", subscription.URL)
+	}
+
+	for _, event := range subscription.Events {
+		if !webhookEvents[event] {
+			return serviceErrs.NewServiceError(http.StatusBadRequest, "unknown event type '%v'", event)
+		}
+	}
+
+	if subscription.Events == nil {
+		subscription.Events = make([]string, 0)
+	}
+
+	if len(subscription.Secret) == 0 {
+		subscription.Secret, err = RandomCode(webhookSecretBytes)
+		if err != nil {
+			return serviceErrs.NewServiceError(http.StatusInternalServerError, "%w", err)
+		}
+	}
+
+	subscription.CreatedAt = time.Now()
+
+	err = s.repo.AddNewSubscription(ctx, subscription)
+	if err != nil {
+		return serviceErrs.NewServiceError(http.StatusInternalServerError, "failed to add webhook subscription: %w", err)
+	}
+
+	return nil
+}
+
+func (s *WebhookService) GetAllSubscriptions(ctx context.Context) ([]models.WebhookSubscription, serviceErrs.ServiceError) {
+	subscriptions, err := s.repo.GetAllSubscriptions(ctx)
+	if err != nil {
+		return nil, serviceErrs.NewServiceError(http.StatusInternalServerError,
+			"failed to get all webhook subscriptions: %w", err)
+	}
+
+	if len(subscriptions) == 0 {
+		return nil, serviceErrs.NewServiceError(http.StatusNoContent, "no webhook subscriptions found")
+	}
+
+	return subscriptions, nil
+}
+
+func (s *WebhookService) DeleteSubscription(ctx context.Context, id string) serviceErrs.ServiceError {
+	if !IsUUID(id) {
+		return serviceErrs.NewServiceError(http.StatusNotFound, "invalid webhook subscription id '%v'", id)
+	}
+
+	err := s.repo.DeleteSubscription(ctx, id)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, repo.ErrSubscriptionNotFound):
+		return serviceErrs.NewServiceError(http.StatusNotFound, "webhook subscription '%v' not found", id)
+	default:
+		return serviceErrs.NewServiceError(http.StatusInternalServerError,
+			"failed to delete webhook subscription '%v': %w", id, err)
+	}
+}
+
+func (s *WebhookService) GetDeliveryLog(ctx context.Context, id string) ([]models.WebhookAttempt, serviceErrs.ServiceError) {
+	if !IsUUID(id) {
+		return nil, serviceErrs.NewServiceError(http.StatusNotFound, "invalid webhook subscription id '%v'", id)
+	}
+
+	subscription, err := s.repo.GetSubscriptionByID(ctx, id)
+	if err != nil {
+		return nil, serviceErrs.NewServiceError(http.StatusInternalServerError,
+			"failed to get webhook subscription '%v': %w", id, err)
+	}
+
+	if len(subscription.ID) == 0 {
+		return nil, serviceErrs.NewServiceError(http.StatusNotFound, "webhook subscription '%v' not found", id)
+	}
+
+	attempts, err := s.repo.GetDeliveryLog(ctx, id, webhookLogLimit)
+	if err != nil {
+		return nil, serviceErrs.NewServiceError(http.StatusInternalServerError,
+			"failed to get delivery log of webhook subscription '%v': %w", id, err)
+	}
+
+	if len(attempts) == 0 {
+		return nil, serviceErrs.NewServiceError(http.StatusNoContent, "no webhook deliveries found")
+	}
+
+	return attempts, nil
+}
+
+// webhookSink queues deliveries of outbox events. Events passed again are not queued twice,
+// so deliveries are added once for every committed change.
+type webhookSink struct {
+	repo   repo.WebhookServiceRepo
+	logger *zap.SugaredLogger
+}
+
+func (s *webhookSink) Name() string {
+	return OutboxSinkWebhook
+}
+
+func (s *webhookSink) Send(ctx context.Context, event *models.Event, payload []byte) error {
+	queued, err := s.repo.EnqueueEvent(ctx, event, payload)
+	if err != nil {
+		return err
+	}
+
+	if queued > 0 {
+		s.logger.Debugf("Queued %v deliveries of '%v' event '%v'", queued, event.Type, event.ID)
+	}
+
+	return nil
+}
+
+// Sink returns the outbox sink queueing webhook deliveries of the events
+func (s *WebhookService) Sink() OutboxSink {
+	return &webhookSink{
+		repo:   s.repo,
+		logger: s.logger,
+	}
+}
+
+func (s *WebhookService) send(ctx context.Context, d *models.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderWebhookEvent, d.EventType)
+	req.Header.Set(HeaderWebhookDelivery, d.ID)
+	req.Header.Set(HeaderWebhookTimestamp, timestamp)
+	req.Header.Set(HeaderWebhookSignature, SignWebhook(d.Secret, timestamp, d.Payload))
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+
+	defer res.Body.Close()
+
+	// Drain the body, so the connection can be reused
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, webhookErrorMaxSize))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("unexpected response status %v", res.StatusCode)
+	}
+
+	return res.StatusCode, nil
+}
+
+// DeliverPending sends deliveries due at the moment and returns the number of successful ones
+func (s *WebhookService) DeliverPending(ctx context.Context) (int, serviceErrs.ServiceError) {
+	delivered := 0
+
+	for claimed := 0; claimed < webhookBatchSize && ctx.Err() == nil; {
+		now := time.Now()
+
+		// Claimed deliveries are retried by anyone after lease if the dispatcher dies while sending.
+		// Every delivery is sent within the timeout, one more timeout is left for recording attempts.
+		lease := time.Duration(webhookClaimSize+1) * s.policy.Timeout
+
+		deliveries, err := s.repo.ClaimDueDeliveries(ctx, now, now.Add(lease), webhookClaimSize)
+		if err != nil {
+			return delivered, serviceErrs.NewServiceError(http.StatusInternalServerError,
+				"failed to claim webhook deliveries: %w", err)
+		}
+
+		if len(deliveries) == 0 {
+			break
+		}
+
+		claimed += len(deliveries)
+
+		for i := range deliveries {
+			if s.deliver(ctx, &deliveries[i]) {
+				delivered++
+			}
+		}
+	}
+
+	return delivered, nil
+}
+
+// deliver makes a delivery attempt and records its result
+func (s *WebhookService) deliver(ctx context.Context, d *models.WebhookDelivery) bool {
+	status, err := s.send(ctx, d)
+
+	attempt := models.WebhookAttempt{
+		DeliveryID:     d.ID,
+		Attempt:        d.Attempts + 1,
+		ResponseStatus: status,
+		Status:         models.DeliveryDELIVERED,
+		AttemptedAt:    time.Now(),
+	}
+
+	nextAttemptAt := attempt.AttemptedAt
+	if err != nil {
+		attempt.Error = err.Error()
+		attempt.Status = models.DeliveryPENDING
+		nextAttemptAt = attempt.AttemptedAt.Add(retryDelay(attempt.Attempt))
+
+		if attempt.Attempt >= s.policy.MaxAttempts {
+			attempt.Status = models.DeliveryFAILED
+		}
+
+		s.logger.Debugf("Webhook delivery '%v' attempt %v failed: %v", d.ID, attempt.Attempt, err)
+	}
+
+	if err := s.repo.RecordAttempt(ctx, &attempt, nextAttemptAt); err != nil {
+		s.logger.Errorf("Failed to record webhook delivery '%v' attempt: %v", d.ID, err)
+	}
+
+	return err == nil
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestSignWebhook(t *testing.T) {
+	body := []byte(`{"event":"order.status_changed","order":"12345678903"}`)
+
+	// Signatures are computed with openssl dgst -sha256 -hmac over "<timestamp>.<body>"
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		body      []byte
+		signature string
+	}{
+		{name: "event", secret: "whsec_test", timestamp: "1700000000", body: body,
+			signature: "e90a7b02b900b7b138216f332783d98f194f2b2f1f1aa03d2fd89d167ab99e44"},
+		{name: "empty body", secret: "whsec_test", timestamp: "1700000000",
+			signature: "5967f3c560522fa40cf2876ebc3c3a08551dd6959aaade3b413460591895bdcc"},
+		{name: "other timestamp", secret: "whsec_test", timestamp: "1700000001", body: body,
+			signature: "00390f9ab80154fb03bc85e3687206dfe6d3b1437712d0a7594a8749a4694cd3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if signature := SignWebhook(tt.secret, tt.timestamp, tt.body); signature != tt.signature {
+				t.Errorf("Expected signature %v, got %v", tt.signature, signature)
+			}
+		})
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempt int
+		delay   time.Duration
+	}{
+		{attempt: 0, delay: 10 * time.Second},
+		{attempt: 1, delay: 10 * time.Second},
+		{attempt: 2, delay: 20 * time.Second},
+		{attempt: 3, delay: 40 * time.Second},
+		{attempt: 4, delay: 80 * time.Second},
+		{attempt: 9, delay: 2560 * time.Second},
+		{attempt: 10, delay: time.Hour},
+		{attempt: 1000, delay: time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.attempt), func(t *testing.T) {
+			if delay := retryDelay(tt.attempt); delay != tt.delay {
+				t.Errorf("Expected delay %v after attempt %v, got %v", tt.delay, tt.attempt, delay)
+			}
+		})
+	}
+}
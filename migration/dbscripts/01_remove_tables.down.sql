@@ -1,9 +1,9 @@
 BEGIN;
 
-DROP TABLE IF EXISTS users;
-DROP TABLE IF EXISTS orders;
 DROP TABLE IF EXISTS balances;
+DROP TABLE IF EXISTS orders;
+DROP TABLE IF EXISTS users;
 
 DROP TYPE IF EXISTS ORDER_STATUS;
 
-COMMIT
\ No newline at end of file
+COMMIT;
@@ -0,0 +1,16 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS idempotency_keys
+(
+    username        VARCHAR NOT NULL REFERENCES users (username),
+    idempotency_key VARCHAR NOT NULL,
+    fingerprint     VARCHAR NOT NULL,
+    response_status INTEGER,
+    response_body   BYTEA,
+    content_type    VARCHAR,
+    created_at      TIMESTAMP WITH TIME ZONE NOT NULL,
+    expires_at      TIMESTAMP WITH TIME ZONE NOT NULL,
+    CONSTRAINT idempotency_keys_pk PRIMARY KEY (username, idempotency_key)
+);
+
+COMMIT;
@@ -0,0 +1,29 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS withdrawals
+(
+    number       VARCHAR PRIMARY KEY,
+    username     VARCHAR NOT NULL REFERENCES users (username),
+    sum          FLOAT NOT NULL,
+    processed_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+CREATE INDEX IF NOT EXISTS withdrawals_username_idx
+    on withdrawals (username);
+
+INSERT INTO withdrawals (number, username, sum, processed_at)
+SELECT order_number, username, outcome, processed_at
+FROM balances
+WHERE outcome != 0
+ORDER BY processed_at
+ON CONFLICT (number) DO NOTHING;
+
+-- Withdrawals used to be inserted into orders as well. Such orders were created
+-- together with the withdrawal and have never got an accrual.
+DELETE FROM orders o
+USING withdrawals w
+WHERE o.number = w.number
+  AND o.username = w.username
+  AND o.status != 'PROCESSED'
+  AND abs(extract(EPOCH FROM o.uploaded_at - w.processed_at)) < 1;
+
+COMMIT;
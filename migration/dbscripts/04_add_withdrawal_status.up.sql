@@ -0,0 +1,31 @@
+BEGIN;
+
+DO $$
+BEGIN
+IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'withdrawal_status') THEN
+        CREATE TYPE WITHDRAWAL_STATUS AS enum ('PENDING', 'COMPLETED', 'CANCELLED');
+END IF;
+IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'balance_operation') THEN
+        CREATE TYPE BALANCE_OPERATION AS enum ('ACCRUAL', 'WITHDRAWAL', 'REFUND');
+END IF;
+END$$;
+
+-- Withdrawals made before the lifecycle was introduced are final
+ALTER TABLE withdrawals
+    ADD COLUMN IF NOT EXISTS status WITHDRAWAL_STATUS NOT NULL DEFAULT 'COMPLETED',
+    ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE withdrawals
+    ALTER COLUMN status SET DEFAULT 'PENDING';
+UPDATE withdrawals SET updated_at = processed_at WHERE updated_at IS NULL;
+ALTER TABLE withdrawals
+    ALTER COLUMN updated_at SET NOT NULL;
+
+ALTER TABLE balances
+    ADD COLUMN IF NOT EXISTS operation BALANCE_OPERATION;
+UPDATE balances SET operation = CASE WHEN outcome != 0 THEN 'WITHDRAWAL'::BALANCE_OPERATION
+                                     ELSE 'ACCRUAL'::BALANCE_OPERATION END
+WHERE operation IS NULL;
+ALTER TABLE balances
+    ALTER COLUMN operation SET NOT NULL;
+
+COMMIT;
@@ -0,0 +1,10 @@
+BEGIN;
+
+ALTER TABLE balances DROP COLUMN IF EXISTS operation;
+ALTER TABLE withdrawals DROP COLUMN IF EXISTS status;
+ALTER TABLE withdrawals DROP COLUMN IF EXISTS updated_at;
+
+DROP TYPE IF EXISTS BALANCE_OPERATION;
+DROP TYPE IF EXISTS WITHDRAWAL_STATUS;
+
+COMMIT;
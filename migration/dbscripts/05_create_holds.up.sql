@@ -0,0 +1,27 @@
+BEGIN;
+
+DO $$
+BEGIN
+IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'hold_status') THEN
+        CREATE TYPE HOLD_STATUS AS enum ('ACTIVE', 'CAPTURED', 'RELEASED', 'EXPIRED');
+END IF;
+END$$;
+
+CREATE TABLE IF NOT EXISTS holds
+(
+    id           uuid NOT NULL DEFAULT uuid_generate_v1mc(),
+    username     VARCHAR NOT NULL REFERENCES users (username),
+    sum          FLOAT NOT NULL,
+    status       HOLD_STATUS NOT NULL,
+    order_number VARCHAR,
+    created_at   TIMESTAMP WITH TIME ZONE NOT NULL,
+    expires_at   TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated_at   TIMESTAMP WITH TIME ZONE NOT NULL,
+    CONSTRAINT holds_pk PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS holds_username_status_idx
+    on holds (username, status);
+CREATE INDEX IF NOT EXISTS holds_status_expires_at_idx
+    on holds (status, expires_at);
+
+COMMIT;
@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP TABLE IF EXISTS holds;
+
+DROP TYPE IF EXISTS HOLD_STATUS;
+
+COMMIT;
@@ -0,0 +1,12 @@
+ALTER TYPE BALANCE_OPERATION ADD VALUE IF NOT EXISTS 'EXPIRY';
+
+BEGIN;
+
+-- Expiry records don't belong to any order
+ALTER TABLE balances
+    ALTER COLUMN order_number DROP NOT NULL;
+
+CREATE INDEX IF NOT EXISTS balances_username_processed_at_idx
+    on balances (username, processed_at);
+
+COMMIT;
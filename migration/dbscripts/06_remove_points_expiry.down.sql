@@ -0,0 +1,10 @@
+BEGIN;
+
+DROP INDEX IF EXISTS balances_username_processed_at_idx;
+
+DELETE FROM balances WHERE operation = 'EXPIRY';
+UPDATE balances SET order_number = '' WHERE order_number IS NULL;
+ALTER TABLE balances
+    ALTER COLUMN order_number SET NOT NULL;
+
+COMMIT;
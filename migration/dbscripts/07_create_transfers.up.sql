@@ -0,0 +1,25 @@
+ALTER TYPE BALANCE_OPERATION ADD VALUE IF NOT EXISTS 'TRANSFER_IN';
+ALTER TYPE BALANCE_OPERATION ADD VALUE IF NOT EXISTS 'TRANSFER_OUT';
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS transfers
+(
+    id         uuid NOT NULL DEFAULT uuid_generate_v1mc(),
+    sender     VARCHAR NOT NULL REFERENCES users (username),
+    recipient  VARCHAR NOT NULL REFERENCES users (username),
+    sum        FLOAT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    CONSTRAINT transfers_pk PRIMARY KEY (id),
+    CONSTRAINT transfers_not_self CHECK (sender != recipient)
+);
+CREATE INDEX IF NOT EXISTS transfers_sender_created_at_idx
+    on transfers (sender, created_at);
+CREATE INDEX IF NOT EXISTS transfers_recipient_created_at_idx
+    on transfers (recipient, created_at);
+
+-- Links the record to the entity it was made for, e.g. transfer id
+ALTER TABLE balances
+    ADD COLUMN IF NOT EXISTS reference VARCHAR;
+
+COMMIT;
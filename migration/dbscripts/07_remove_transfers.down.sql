@@ -0,0 +1,8 @@
+BEGIN;
+
+DELETE FROM balances WHERE operation IN ('TRANSFER_IN', 'TRANSFER_OUT');
+ALTER TABLE balances DROP COLUMN IF EXISTS reference;
+
+DROP TABLE IF EXISTS transfers;
+
+COMMIT;
@@ -0,0 +1,21 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS user_balances
+(
+    username   VARCHAR PRIMARY KEY REFERENCES users (username),
+    current    FLOAT DEFAULT 0 NOT NULL,
+    withdrawn  FLOAT DEFAULT 0 NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+INSERT INTO user_balances (username, current, withdrawn, updated_at)
+SELECT username,
+       sum(income) - sum(outcome),
+       coalesce(sum(outcome) FILTER (WHERE operation = 'WITHDRAWAL'), 0) -
+       coalesce(sum(income) FILTER (WHERE operation = 'REFUND'), 0),
+       max(processed_at)
+FROM balances
+GROUP BY username
+ON CONFLICT (username) DO NOTHING;
+
+COMMIT;
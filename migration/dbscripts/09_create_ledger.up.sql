@@ -0,0 +1,94 @@
+BEGIN;
+
+DO $$
+BEGIN
+IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'ledger_account_type') THEN
+        CREATE TYPE LEDGER_ACCOUNT_TYPE AS enum ('USER_WALLET', 'ACCRUAL_SOURCE', 'REDEMPTION_SINK', 'EXPIRY', 'ADJUSTMENT');
+END IF;
+IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'ledger_operation') THEN
+        CREATE TYPE LEDGER_OPERATION AS enum ('ACCRUAL', 'WITHDRAWAL', 'REFUND', 'EXPIRY', 'TRANSFER', 'ADJUSTMENT');
+END IF;
+END$$;
+
+CREATE TABLE IF NOT EXISTS ledger_accounts
+(
+    code     VARCHAR PRIMARY KEY,
+    type     LEDGER_ACCOUNT_TYPE NOT NULL,
+    username VARCHAR UNIQUE REFERENCES users (username),
+    CONSTRAINT ledger_accounts_wallet_owner CHECK ((type = 'USER_WALLET') = (username IS NOT NULL))
+);
+
+INSERT INTO ledger_accounts (code, type)
+VALUES ('accrual', 'ACCRUAL_SOURCE'),
+       ('redemption', 'REDEMPTION_SINK'),
+       ('expiry', 'EXPIRY'),
+       ('adjustment', 'ADJUSTMENT')
+ON CONFLICT (code) DO NOTHING;
+
+INSERT INTO ledger_accounts (code, type, username)
+SELECT 'wallet:' || username, 'USER_WALLET', username
+FROM users
+ON CONFLICT (code) DO NOTHING;
+
+-- Every transaction consists of postings with zero sum
+CREATE TABLE IF NOT EXISTS ledger_transactions
+(
+    id           uuid NOT NULL DEFAULT uuid_generate_v1mc(),
+    operation    LEDGER_OPERATION NOT NULL,
+    order_number VARCHAR,
+    reference    VARCHAR,
+    created_at   TIMESTAMP WITH TIME ZONE NOT NULL,
+    CONSTRAINT ledger_transactions_pk PRIMARY KEY (id)
+);
+
+CREATE TABLE IF NOT EXISTS ledger_postings
+(
+    id             BIGSERIAL PRIMARY KEY,
+    transaction_id uuid NOT NULL REFERENCES ledger_transactions (id),
+    account        VARCHAR NOT NULL REFERENCES ledger_accounts (code),
+    amount         FLOAT NOT NULL,
+    created_at     TIMESTAMP WITH TIME ZONE NOT NULL
+);
+CREATE INDEX IF NOT EXISTS ledger_postings_transaction_id_idx
+    on ledger_postings (transaction_id);
+CREATE INDEX IF NOT EXISTS ledger_postings_account_created_at_idx
+    on ledger_postings (account, created_at);
+
+-- Single entry records get the counter account by operation
+INSERT INTO ledger_transactions (id, operation, order_number, reference, created_at)
+SELECT id, operation::TEXT::LEDGER_OPERATION, order_number, reference, processed_at
+FROM balances
+WHERE operation NOT IN ('TRANSFER_IN', 'TRANSFER_OUT');
+
+INSERT INTO ledger_postings (transaction_id, account, amount, created_at)
+SELECT id, 'wallet:' || username, income - outcome, processed_at
+FROM balances
+WHERE operation NOT IN ('TRANSFER_IN', 'TRANSFER_OUT')
+UNION ALL
+SELECT id,
+       CASE operation
+           WHEN 'ACCRUAL' THEN 'accrual'
+           WHEN 'EXPIRY' THEN 'expiry'
+           ELSE 'redemption'
+           END,
+       outcome - income,
+       processed_at
+FROM balances
+WHERE operation NOT IN ('TRANSFER_IN', 'TRANSFER_OUT');
+
+-- Both sides of a transfer become a single transaction
+INSERT INTO ledger_transactions (id, operation, reference, created_at)
+SELECT id, 'TRANSFER', id::TEXT, created_at
+FROM transfers;
+
+INSERT INTO ledger_postings (transaction_id, account, amount, created_at)
+SELECT id, 'wallet:' || sender, -sum, created_at
+FROM transfers
+UNION ALL
+SELECT id, 'wallet:' || recipient, sum, created_at
+FROM transfers;
+
+DROP TABLE IF EXISTS balances;
+DROP TYPE IF EXISTS BALANCE_OPERATION;
+
+COMMIT;
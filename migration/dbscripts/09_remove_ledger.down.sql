@@ -0,0 +1,52 @@
+BEGIN;
+
+DO $$
+BEGIN
+IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'balance_operation') THEN
+        CREATE TYPE BALANCE_OPERATION AS enum ('ACCRUAL', 'WITHDRAWAL', 'REFUND', 'EXPIRY', 'TRANSFER_IN', 'TRANSFER_OUT');
+END IF;
+END$$;
+
+CREATE TABLE IF NOT EXISTS balances
+(
+    id           uuid NOT NULL DEFAULT uuid_generate_v1mc(),
+    username     VARCHAR NOT NULL REFERENCES users (username),
+    processed_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    income       FLOAT DEFAULT 0 NOT NULL,
+    outcome      FLOAT DEFAULT 0 NOT NULL,
+    order_number VARCHAR,
+    operation    BALANCE_OPERATION NOT NULL,
+    reference    VARCHAR,
+    CONSTRAINT balances_pk PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS balances_username_processed_at_idx
+    on balances (username, processed_at);
+
+-- Adjustments have no single entry counterpart, so they become accruals and expiries
+INSERT INTO balances (username, processed_at, income, outcome, order_number, operation, reference)
+SELECT a.username,
+       t.created_at,
+       greatest(p.amount, 0),
+       greatest(-p.amount, 0),
+       t.order_number,
+       CASE
+           WHEN t.operation = 'TRANSFER' AND p.amount > 0 THEN 'TRANSFER_IN'
+           WHEN t.operation = 'TRANSFER' THEN 'TRANSFER_OUT'
+           WHEN t.operation = 'ADJUSTMENT' AND p.amount > 0 THEN 'ACCRUAL'
+           WHEN t.operation = 'ADJUSTMENT' THEN 'EXPIRY'
+           ELSE t.operation::TEXT
+           END::BALANCE_OPERATION,
+       t.reference
+FROM ledger_postings p
+         JOIN ledger_transactions t ON t.id = p.transaction_id
+         JOIN ledger_accounts a ON a.code = p.account
+WHERE a.type = 'USER_WALLET';
+
+DROP TABLE IF EXISTS ledger_postings;
+DROP TABLE IF EXISTS ledger_transactions;
+DROP TABLE IF EXISTS ledger_accounts;
+
+DROP TYPE IF EXISTS LEDGER_OPERATION;
+DROP TYPE IF EXISTS LEDGER_ACCOUNT_TYPE;
+
+COMMIT;
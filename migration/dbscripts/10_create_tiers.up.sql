@@ -0,0 +1,26 @@
+BEGIN;
+
+DO $$
+BEGIN
+IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'user_tier') THEN
+        CREATE TYPE USER_TIER AS enum ('BRONZE', 'SILVER', 'GOLD');
+END IF;
+END$$;
+
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS tier USER_TIER DEFAULT 'BRONZE' NOT NULL;
+
+CREATE TABLE IF NOT EXISTS tier_changes
+(
+    id               uuid NOT NULL DEFAULT uuid_generate_v1mc(),
+    username         VARCHAR NOT NULL REFERENCES users (username),
+    from_tier        USER_TIER NOT NULL,
+    to_tier          USER_TIER NOT NULL,
+    lifetime_accrued FLOAT NOT NULL,
+    changed_at       TIMESTAMP WITH TIME ZONE NOT NULL,
+    CONSTRAINT tier_changes_pk PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS tier_changes_username_changed_at_idx
+    on tier_changes (username, changed_at);
+
+COMMIT;
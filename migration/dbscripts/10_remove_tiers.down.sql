@@ -0,0 +1,10 @@
+BEGIN;
+
+DROP TABLE IF EXISTS tier_changes;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS tier;
+
+DROP TYPE IF EXISTS USER_TIER;
+
+COMMIT;
@@ -0,0 +1,31 @@
+ALTER TYPE LEDGER_OPERATION ADD VALUE IF NOT EXISTS 'BONUS';
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS campaigns
+(
+    id           uuid NOT NULL DEFAULT uuid_generate_v1mc(),
+    name         VARCHAR NOT NULL,
+    starts_at    TIMESTAMP WITH TIME ZONE NOT NULL,
+    ends_at      TIMESTAMP WITH TIME ZONE NOT NULL,
+    multiplier   FLOAT DEFAULT 1 NOT NULL,
+    fixed_bonus  FLOAT DEFAULT 0 NOT NULL,
+    first_orders INTEGER DEFAULT 0 NOT NULL,
+    user_cap     FLOAT DEFAULT 0 NOT NULL,
+    created_at   TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated_at   TIMESTAMP WITH TIME ZONE NOT NULL,
+    CONSTRAINT campaigns_pk PRIMARY KEY (id),
+    CONSTRAINT campaigns_window CHECK (starts_at < ends_at)
+);
+CREATE INDEX IF NOT EXISTS campaigns_starts_at_ends_at_idx
+    on campaigns (starts_at, ends_at);
+
+-- Campaign bonuses are paid from their own source account
+INSERT INTO ledger_accounts (code, type)
+VALUES ('campaigns', 'ACCRUAL_SOURCE')
+ON CONFLICT (code) DO NOTHING;
+
+CREATE INDEX IF NOT EXISTS ledger_transactions_reference_idx
+    on ledger_transactions (reference);
+
+COMMIT;
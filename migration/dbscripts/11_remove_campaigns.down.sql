@@ -0,0 +1,12 @@
+BEGIN;
+
+DROP INDEX IF EXISTS ledger_transactions_reference_idx;
+
+-- Enum values can't be dropped, so bonuses become regular accruals
+UPDATE ledger_transactions SET operation = 'ACCRUAL' WHERE operation = 'BONUS';
+UPDATE ledger_postings SET account = 'accrual' WHERE account = 'campaigns';
+DELETE FROM ledger_accounts WHERE code = 'campaigns';
+
+DROP TABLE IF EXISTS campaigns;
+
+COMMIT;
@@ -0,0 +1,30 @@
+ALTER TYPE LEDGER_OPERATION ADD VALUE IF NOT EXISTS 'REFERRAL';
+
+BEGIN;
+
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS referral_code        VARCHAR UNIQUE,
+    ADD COLUMN IF NOT EXISTS referrer             VARCHAR REFERENCES users (username),
+    ADD COLUMN IF NOT EXISTS referral_rewarded_at TIMESTAMP WITH TIME ZONE;
+
+ALTER TABLE users
+    DROP CONSTRAINT IF EXISTS users_no_self_referral;
+ALTER TABLE users
+    ADD CONSTRAINT users_no_self_referral CHECK (referrer != username);
+
+CREATE INDEX IF NOT EXISTS users_referrer_idx
+    on users (referrer);
+
+UPDATE users
+SET referral_code = upper(substr(md5(random()::TEXT || username), 1, 10))
+WHERE referral_code IS NULL;
+
+ALTER TABLE users
+    ALTER COLUMN referral_code SET NOT NULL;
+
+-- Referral bonuses are paid from their own source account
+INSERT INTO ledger_accounts (code, type)
+VALUES ('referrals', 'ACCRUAL_SOURCE')
+ON CONFLICT (code) DO NOTHING;
+
+COMMIT;
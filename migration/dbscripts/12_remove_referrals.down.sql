@@ -0,0 +1,16 @@
+BEGIN;
+
+-- Enum values can't be dropped, so referral bonuses become regular accruals
+UPDATE ledger_transactions SET operation = 'ACCRUAL' WHERE operation = 'REFERRAL';
+UPDATE ledger_postings SET account = 'accrual' WHERE account = 'referrals';
+DELETE FROM ledger_accounts WHERE code = 'referrals';
+
+DROP INDEX IF EXISTS users_referrer_idx;
+
+ALTER TABLE users
+    DROP CONSTRAINT IF EXISTS users_no_self_referral,
+    DROP COLUMN IF EXISTS referral_rewarded_at,
+    DROP COLUMN IF EXISTS referrer,
+    DROP COLUMN IF EXISTS referral_code;
+
+COMMIT;
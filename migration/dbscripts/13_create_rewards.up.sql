@@ -0,0 +1,32 @@
+ALTER TYPE LEDGER_OPERATION ADD VALUE IF NOT EXISTS 'REWARD';
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS rewards
+(
+    id         uuid NOT NULL DEFAULT uuid_generate_v1mc(),
+    name       VARCHAR NOT NULL,
+    cost       FLOAT NOT NULL,
+    stock      INTEGER NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    CONSTRAINT rewards_pk PRIMARY KEY (id),
+    CONSTRAINT rewards_cost_positive CHECK (cost > 0),
+    CONSTRAINT rewards_stock_not_negative CHECK (stock >= 0)
+);
+
+-- Redemptions keep the reward name and cost, so the history survives catalog changes
+CREATE TABLE IF NOT EXISTS redemptions
+(
+    id          uuid NOT NULL DEFAULT uuid_generate_v1mc(),
+    username    VARCHAR NOT NULL REFERENCES users (username),
+    reward_id   uuid REFERENCES rewards (id) ON DELETE SET NULL,
+    reward_name VARCHAR NOT NULL,
+    cost        FLOAT NOT NULL,
+    created_at  TIMESTAMP WITH TIME ZONE NOT NULL,
+    CONSTRAINT redemptions_pk PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS redemptions_username_created_at_idx
+    on redemptions (username, created_at);
+
+COMMIT;
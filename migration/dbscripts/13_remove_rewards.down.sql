@@ -0,0 +1,9 @@
+BEGIN;
+
+-- Enum values can't be dropped, so redemptions become regular withdrawals
+UPDATE ledger_transactions SET operation = 'WITHDRAWAL' WHERE operation = 'REWARD';
+
+DROP TABLE IF EXISTS redemptions;
+DROP TABLE IF EXISTS rewards;
+
+COMMIT;
@@ -0,0 +1,31 @@
+ALTER TYPE LEDGER_OPERATION ADD VALUE IF NOT EXISTS 'PROMO';
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS promo_codes
+(
+    code            VARCHAR PRIMARY KEY,
+    value           FLOAT NOT NULL,
+    max_redemptions INTEGER DEFAULT 1 NOT NULL,
+    redemptions     INTEGER DEFAULT 0 NOT NULL,
+    expires_at      TIMESTAMP WITH TIME ZONE,
+    created_at      TIMESTAMP WITH TIME ZONE NOT NULL,
+    CONSTRAINT promo_codes_value_positive CHECK (value > 0),
+    CONSTRAINT promo_codes_redemptions_limit CHECK (redemptions <= max_redemptions)
+);
+
+-- Every user can redeem a code once
+CREATE TABLE IF NOT EXISTS promo_redemptions
+(
+    code        VARCHAR NOT NULL REFERENCES promo_codes (code),
+    username    VARCHAR NOT NULL REFERENCES users (username),
+    redeemed_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    CONSTRAINT promo_redemptions_pk PRIMARY KEY (code, username)
+);
+
+-- Promo grants are paid from their own source account
+INSERT INTO ledger_accounts (code, type)
+VALUES ('promotions', 'ACCRUAL_SOURCE')
+ON CONFLICT (code) DO NOTHING;
+
+COMMIT;
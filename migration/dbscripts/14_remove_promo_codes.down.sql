@@ -0,0 +1,11 @@
+BEGIN;
+
+-- Enum values can't be dropped, so promo grants become regular accruals
+UPDATE ledger_transactions SET operation = 'ACCRUAL' WHERE operation = 'PROMO';
+UPDATE ledger_postings SET account = 'accrual' WHERE account = 'promotions';
+DELETE FROM ledger_accounts WHERE code = 'promotions';
+
+DROP TABLE IF EXISTS promo_redemptions;
+DROP TABLE IF EXISTS promo_codes;
+
+COMMIT;
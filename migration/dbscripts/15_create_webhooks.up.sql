@@ -0,0 +1,50 @@
+BEGIN;
+
+DO $$
+BEGIN
+IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'webhook_delivery_status') THEN
+        CREATE TYPE WEBHOOK_DELIVERY_STATUS AS enum ('PENDING', 'DELIVERED', 'FAILED');
+END IF;
+END$$;
+
+-- Empty events list subscribes to all events
+CREATE TABLE IF NOT EXISTS webhook_subscriptions
+(
+    id         uuid NOT NULL DEFAULT uuid_generate_v1mc(),
+    url        VARCHAR NOT NULL,
+    secret     VARCHAR NOT NULL,
+    events     VARCHAR DEFAULT '' NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    CONSTRAINT webhook_subscriptions_pk PRIMARY KEY (id)
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries
+(
+    id              uuid NOT NULL DEFAULT uuid_generate_v1mc(),
+    subscription_id uuid NOT NULL REFERENCES webhook_subscriptions (id) ON DELETE CASCADE,
+    event_id        uuid NOT NULL,
+    event_type      VARCHAR NOT NULL,
+    payload         BYTEA NOT NULL,
+    status          WEBHOOK_DELIVERY_STATUS NOT NULL,
+    attempts        INTEGER DEFAULT 0 NOT NULL,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at      TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated_at      TIMESTAMP WITH TIME ZONE NOT NULL,
+    CONSTRAINT webhook_deliveries_pk PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS webhook_deliveries_status_next_attempt_at_idx
+    on webhook_deliveries (status, next_attempt_at);
+
+CREATE TABLE IF NOT EXISTS webhook_delivery_log
+(
+    id              BIGSERIAL PRIMARY KEY,
+    delivery_id     uuid NOT NULL REFERENCES webhook_deliveries (id) ON DELETE CASCADE,
+    attempt         INTEGER NOT NULL,
+    response_status INTEGER DEFAULT 0 NOT NULL,
+    error           VARCHAR DEFAULT '' NOT NULL,
+    attempted_at    TIMESTAMP WITH TIME ZONE NOT NULL
+);
+CREATE INDEX IF NOT EXISTS webhook_delivery_log_delivery_id_idx
+    on webhook_delivery_log (delivery_id);
+
+COMMIT;
@@ -0,0 +1,9 @@
+BEGIN;
+
+DROP TABLE IF EXISTS webhook_delivery_log;
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhook_subscriptions;
+
+DROP TYPE IF EXISTS WEBHOOK_DELIVERY_STATUS;
+
+COMMIT;
@@ -0,0 +1,20 @@
+BEGIN;
+
+-- Events written in the same transaction as the changes they describe.
+-- id is the dedup id consumers use, seq keeps the publishing order.
+CREATE TABLE IF NOT EXISTS outbox
+(
+    seq          BIGSERIAL PRIMARY KEY,
+    id           uuid NOT NULL DEFAULT uuid_generate_v1mc(),
+    event_type   VARCHAR NOT NULL,
+    payload      BYTEA NOT NULL,
+    created_at   TIMESTAMP WITH TIME ZONE NOT NULL,
+    published_at TIMESTAMP WITH TIME ZONE,
+    CONSTRAINT outbox_id_unique UNIQUE (id)
+);
+CREATE INDEX IF NOT EXISTS outbox_unpublished_idx
+    on outbox (seq) WHERE published_at IS NULL;
+CREATE INDEX IF NOT EXISTS outbox_published_at_idx
+    on outbox (published_at) WHERE published_at IS NOT NULL;
+
+COMMIT;
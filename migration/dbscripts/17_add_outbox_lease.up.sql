@@ -0,0 +1,7 @@
+BEGIN;
+
+-- Relays lease the events they publish instead of locking them in a transaction,
+-- so sinks are called without holding a connection. Events of an expired lease are claimed again.
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS locked_until TIMESTAMP WITH TIME ZONE;
+
+COMMIT;
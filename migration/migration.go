@@ -0,0 +1,18 @@
+// Package migration embeds database migration scripts into the binary
+package migration
+
+import "embed"
+
+// Scripts holds numbered up and down scripts in dbscripts directory
+//
+//go:embed dbscripts/*.sql
+var Scripts embed.FS
+
+const ScriptsDir = "dbscripts"
+
+// SQLiteScripts holds numbered up and down scripts of SQLite database in sqlitescripts directory
+//
+//go:embed sqlitescripts/*.sql
+var SQLiteScripts embed.FS
+
+const SQLiteScriptsDir = "sqlitescripts"
@@ -0,0 +1,287 @@
+-- SQLite schema matches the Postgres one after all its migrations. Enums are replaced
+-- with checks, UUIDs are random version 4 ones stored as text and timestamps are
+-- stored as UTC text of fixed width, so they compare as strings.
+-- gen_random_uuid is registered by the application on every connection, so rows with
+-- generated ids can be inserted only by the application.
+
+CREATE TABLE IF NOT EXISTS users
+(
+    id                   TEXT NOT NULL DEFAULT (gen_random_uuid()),
+    username             TEXT NOT NULL UNIQUE,
+    user_password        TEXT NOT NULL,
+    tier                 TEXT DEFAULT 'BRONZE' NOT NULL,
+    referral_code        TEXT NOT NULL UNIQUE,
+    referrer             TEXT REFERENCES users (username),
+    referral_rewarded_at TIMESTAMP,
+    CONSTRAINT users_pk PRIMARY KEY (id),
+    CONSTRAINT users_tier CHECK (tier IN ('BRONZE', 'SILVER', 'GOLD')),
+    CONSTRAINT users_no_self_referral CHECK (referrer != username)
+);
+CREATE INDEX IF NOT EXISTS users_referrer_idx
+    on users (referrer);
+
+CREATE TABLE IF NOT EXISTS orders
+(
+    number      TEXT PRIMARY KEY,
+    username    TEXT NOT NULL REFERENCES users (username),
+    uploaded_at TIMESTAMP NOT NULL,
+    status      TEXT NOT NULL,
+    accrual     REAL DEFAULT 0,
+    CONSTRAINT orders_status CHECK (status IN ('NEW', 'PROCESSED', 'PROCESSING', 'INVALID'))
+);
+CREATE INDEX IF NOT EXISTS orders_username_idx
+    on orders (username);
+
+CREATE TABLE IF NOT EXISTS idempotency_keys
+(
+    username        TEXT NOT NULL REFERENCES users (username),
+    idempotency_key TEXT NOT NULL,
+    fingerprint     TEXT NOT NULL,
+    response_status INTEGER,
+    response_body   BLOB,
+    content_type    TEXT,
+    created_at      TIMESTAMP NOT NULL,
+    expires_at      TIMESTAMP NOT NULL,
+    CONSTRAINT idempotency_keys_pk PRIMARY KEY (username, idempotency_key)
+);
+
+CREATE TABLE IF NOT EXISTS withdrawals
+(
+    number       TEXT PRIMARY KEY,
+    username     TEXT NOT NULL REFERENCES users (username),
+    sum          REAL NOT NULL,
+    status       TEXT DEFAULT 'PENDING' NOT NULL,
+    processed_at TIMESTAMP NOT NULL,
+    updated_at   TIMESTAMP NOT NULL,
+    CONSTRAINT withdrawals_status CHECK (status IN ('PENDING', 'COMPLETED', 'CANCELLED'))
+);
+CREATE INDEX IF NOT EXISTS withdrawals_username_idx
+    on withdrawals (username);
+
+CREATE TABLE IF NOT EXISTS holds
+(
+    id           TEXT NOT NULL DEFAULT (gen_random_uuid()),
+    username     TEXT NOT NULL REFERENCES users (username),
+    sum          REAL NOT NULL,
+    status       TEXT NOT NULL,
+    order_number TEXT,
+    created_at   TIMESTAMP NOT NULL,
+    expires_at   TIMESTAMP NOT NULL,
+    updated_at   TIMESTAMP NOT NULL,
+    CONSTRAINT holds_pk PRIMARY KEY (id),
+    CONSTRAINT holds_status CHECK (status IN ('ACTIVE', 'CAPTURED', 'RELEASED', 'EXPIRED'))
+);
+CREATE INDEX IF NOT EXISTS holds_username_status_idx
+    on holds (username, status);
+CREATE INDEX IF NOT EXISTS holds_status_expires_at_idx
+    on holds (status, expires_at);
+
+CREATE TABLE IF NOT EXISTS transfers
+(
+    id         TEXT NOT NULL DEFAULT (gen_random_uuid()),
+    sender     TEXT NOT NULL REFERENCES users (username),
+    recipient  TEXT NOT NULL REFERENCES users (username),
+    sum        REAL NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    CONSTRAINT transfers_pk PRIMARY KEY (id),
+    CONSTRAINT transfers_not_self CHECK (sender != recipient)
+);
+CREATE INDEX IF NOT EXISTS transfers_sender_created_at_idx
+    on transfers (sender, created_at);
+CREATE INDEX IF NOT EXISTS transfers_recipient_created_at_idx
+    on transfers (recipient, created_at);
+
+CREATE TABLE IF NOT EXISTS user_balances
+(
+    username   TEXT PRIMARY KEY REFERENCES users (username),
+    current    REAL DEFAULT 0 NOT NULL,
+    withdrawn  REAL DEFAULT 0 NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS ledger_accounts
+(
+    code     TEXT PRIMARY KEY,
+    type     TEXT NOT NULL,
+    username TEXT UNIQUE REFERENCES users (username),
+    CONSTRAINT ledger_accounts_type CHECK
+        (type IN ('USER_WALLET', 'ACCRUAL_SOURCE', 'REDEMPTION_SINK', 'EXPIRY', 'ADJUSTMENT')),
+    CONSTRAINT ledger_accounts_wallet_owner CHECK ((type = 'USER_WALLET') = (username IS NOT NULL))
+);
+
+INSERT INTO ledger_accounts (code, type)
+VALUES ('accrual', 'ACCRUAL_SOURCE'),
+       ('redemption', 'REDEMPTION_SINK'),
+       ('expiry', 'EXPIRY'),
+       ('adjustment', 'ADJUSTMENT'),
+       ('campaigns', 'ACCRUAL_SOURCE'),
+       ('referrals', 'ACCRUAL_SOURCE'),
+       ('promotions', 'ACCRUAL_SOURCE')
+ON CONFLICT (code) DO NOTHING;
+
+-- Every transaction consists of postings with zero sum
+CREATE TABLE IF NOT EXISTS ledger_transactions
+(
+    id           TEXT NOT NULL DEFAULT (gen_random_uuid()),
+    operation    TEXT NOT NULL,
+    order_number TEXT,
+    reference    TEXT,
+    created_at   TIMESTAMP NOT NULL,
+    CONSTRAINT ledger_transactions_pk PRIMARY KEY (id),
+    CONSTRAINT ledger_transactions_operation CHECK (operation IN
+        ('ACCRUAL', 'WITHDRAWAL', 'REFUND', 'EXPIRY', 'TRANSFER', 'ADJUSTMENT', 'BONUS', 'REFERRAL', 'REWARD', 'PROMO'))
+);
+CREATE INDEX IF NOT EXISTS ledger_transactions_reference_idx
+    on ledger_transactions (reference);
+
+CREATE TABLE IF NOT EXISTS ledger_postings
+(
+    id             INTEGER PRIMARY KEY AUTOINCREMENT,
+    transaction_id TEXT NOT NULL REFERENCES ledger_transactions (id),
+    account        TEXT NOT NULL REFERENCES ledger_accounts (code),
+    amount         REAL NOT NULL,
+    created_at     TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS ledger_postings_transaction_id_idx
+    on ledger_postings (transaction_id);
+CREATE INDEX IF NOT EXISTS ledger_postings_account_created_at_idx
+    on ledger_postings (account, created_at);
+
+CREATE TABLE IF NOT EXISTS tier_changes
+(
+    id               TEXT NOT NULL DEFAULT (gen_random_uuid()),
+    username         TEXT NOT NULL REFERENCES users (username),
+    from_tier        TEXT NOT NULL,
+    to_tier          TEXT NOT NULL,
+    lifetime_accrued REAL NOT NULL,
+    changed_at       TIMESTAMP NOT NULL,
+    CONSTRAINT tier_changes_pk PRIMARY KEY (id),
+    CONSTRAINT tier_changes_tiers CHECK
+        (from_tier IN ('BRONZE', 'SILVER', 'GOLD') AND to_tier IN ('BRONZE', 'SILVER', 'GOLD'))
+);
+CREATE INDEX IF NOT EXISTS tier_changes_username_changed_at_idx
+    on tier_changes (username, changed_at);
+
+CREATE TABLE IF NOT EXISTS campaigns
+(
+    id           TEXT NOT NULL DEFAULT (gen_random_uuid()),
+    name         TEXT NOT NULL,
+    starts_at    TIMESTAMP NOT NULL,
+    ends_at      TIMESTAMP NOT NULL,
+    multiplier   REAL DEFAULT 1 NOT NULL,
+    fixed_bonus  REAL DEFAULT 0 NOT NULL,
+    first_orders INTEGER DEFAULT 0 NOT NULL,
+    user_cap     REAL DEFAULT 0 NOT NULL,
+    created_at   TIMESTAMP NOT NULL,
+    updated_at   TIMESTAMP NOT NULL,
+    CONSTRAINT campaigns_pk PRIMARY KEY (id),
+    CONSTRAINT campaigns_window CHECK (starts_at < ends_at)
+);
+CREATE INDEX IF NOT EXISTS campaigns_starts_at_ends_at_idx
+    on campaigns (starts_at, ends_at);
+
+CREATE TABLE IF NOT EXISTS rewards
+(
+    id         TEXT NOT NULL DEFAULT (gen_random_uuid()),
+    name       TEXT NOT NULL,
+    cost       REAL NOT NULL,
+    stock      INTEGER NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    CONSTRAINT rewards_pk PRIMARY KEY (id),
+    CONSTRAINT rewards_cost_positive CHECK (cost > 0),
+    CONSTRAINT rewards_stock_not_negative CHECK (stock >= 0)
+);
+
+-- Redemptions keep the reward name and cost, so the history survives catalog changes
+CREATE TABLE IF NOT EXISTS redemptions
+(
+    id          TEXT NOT NULL DEFAULT (gen_random_uuid()),
+    username    TEXT NOT NULL REFERENCES users (username),
+    reward_id   TEXT REFERENCES rewards (id) ON DELETE SET NULL,
+    reward_name TEXT NOT NULL,
+    cost        REAL NOT NULL,
+    created_at  TIMESTAMP NOT NULL,
+    CONSTRAINT redemptions_pk PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS redemptions_username_created_at_idx
+    on redemptions (username, created_at);
+
+CREATE TABLE IF NOT EXISTS promo_codes
+(
+    code            TEXT PRIMARY KEY,
+    value           REAL NOT NULL,
+    max_redemptions INTEGER DEFAULT 1 NOT NULL,
+    redemptions     INTEGER DEFAULT 0 NOT NULL,
+    expires_at      TIMESTAMP,
+    created_at      TIMESTAMP NOT NULL,
+    CONSTRAINT promo_codes_value_positive CHECK (value > 0),
+    CONSTRAINT promo_codes_redemptions_limit CHECK (redemptions <= max_redemptions)
+);
+
+-- Every user can redeem a code once
+CREATE TABLE IF NOT EXISTS promo_redemptions
+(
+    code        TEXT NOT NULL REFERENCES promo_codes (code),
+    username    TEXT NOT NULL REFERENCES users (username),
+    redeemed_at TIMESTAMP NOT NULL,
+    CONSTRAINT promo_redemptions_pk PRIMARY KEY (code, username)
+);
+
+-- Empty events list subscribes to all events
+CREATE TABLE IF NOT EXISTS webhook_subscriptions
+(
+    id         TEXT NOT NULL DEFAULT (gen_random_uuid()),
+    url        TEXT NOT NULL,
+    secret     TEXT NOT NULL,
+    events     TEXT DEFAULT '' NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    CONSTRAINT webhook_subscriptions_pk PRIMARY KEY (id)
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries
+(
+    id              TEXT NOT NULL DEFAULT (gen_random_uuid()),
+    subscription_id TEXT NOT NULL REFERENCES webhook_subscriptions (id) ON DELETE CASCADE,
+    event_id        TEXT NOT NULL,
+    event_type      TEXT NOT NULL,
+    payload         BLOB NOT NULL,
+    status          TEXT NOT NULL,
+    attempts        INTEGER DEFAULT 0 NOT NULL,
+    next_attempt_at TIMESTAMP NOT NULL,
+    created_at      TIMESTAMP NOT NULL,
+    updated_at      TIMESTAMP NOT NULL,
+    CONSTRAINT webhook_deliveries_pk PRIMARY KEY (id),
+    CONSTRAINT webhook_deliveries_status CHECK (status IN ('PENDING', 'DELIVERED', 'FAILED'))
+);
+CREATE INDEX IF NOT EXISTS webhook_deliveries_status_next_attempt_at_idx
+    on webhook_deliveries (status, next_attempt_at);
+
+CREATE TABLE IF NOT EXISTS webhook_delivery_log
+(
+    id              INTEGER PRIMARY KEY AUTOINCREMENT,
+    delivery_id     TEXT NOT NULL REFERENCES webhook_deliveries (id) ON DELETE CASCADE,
+    attempt         INTEGER NOT NULL,
+    response_status INTEGER DEFAULT 0 NOT NULL,
+    error           TEXT DEFAULT '' NOT NULL,
+    attempted_at    TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS webhook_delivery_log_delivery_id_idx
+    on webhook_delivery_log (delivery_id);
+
+-- Events written in the same transaction as the changes they describe.
+-- id is the dedup id consumers use, seq keeps the publishing order.
+CREATE TABLE IF NOT EXISTS outbox
+(
+    seq          INTEGER PRIMARY KEY AUTOINCREMENT,
+    id           TEXT NOT NULL DEFAULT (gen_random_uuid()),
+    event_type   TEXT NOT NULL,
+    payload      BLOB NOT NULL,
+    created_at   TIMESTAMP NOT NULL,
+    published_at TIMESTAMP,
+    CONSTRAINT outbox_id_unique UNIQUE (id)
+);
+CREATE INDEX IF NOT EXISTS outbox_unpublished_idx
+    on outbox (seq) WHERE published_at IS NULL;
+CREATE INDEX IF NOT EXISTS outbox_published_at_idx
+    on outbox (published_at) WHERE published_at IS NOT NULL;
@@ -0,0 +1,20 @@
+DROP TABLE IF EXISTS outbox;
+DROP TABLE IF EXISTS webhook_delivery_log;
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhook_subscriptions;
+DROP TABLE IF EXISTS promo_redemptions;
+DROP TABLE IF EXISTS promo_codes;
+DROP TABLE IF EXISTS redemptions;
+DROP TABLE IF EXISTS rewards;
+DROP TABLE IF EXISTS campaigns;
+DROP TABLE IF EXISTS tier_changes;
+DROP TABLE IF EXISTS ledger_postings;
+DROP TABLE IF EXISTS ledger_transactions;
+DROP TABLE IF EXISTS ledger_accounts;
+DROP TABLE IF EXISTS user_balances;
+DROP TABLE IF EXISTS transfers;
+DROP TABLE IF EXISTS holds;
+DROP TABLE IF EXISTS withdrawals;
+DROP TABLE IF EXISTS idempotency_keys;
+DROP TABLE IF EXISTS orders;
+DROP TABLE IF EXISTS users;
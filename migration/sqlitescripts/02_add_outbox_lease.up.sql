@@ -0,0 +1,2 @@
+-- Relays lease the events they publish, see Postgres migration 17
+ALTER TABLE outbox ADD COLUMN locked_until TIMESTAMP;